@@ -0,0 +1,169 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"dns-api-go/internal/audit"
+	"dns-api-go/internal/models"
+	"dns-api-go/logger"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// memoryAuditEvents is the number of events kept in memory when no audit log is configured
+const memoryAuditEvents = 1000
+
+type auditContextKey struct{}
+
+// audited wraps the handler of a route that changes entities, so that an audit event of the action is recorded
+// with the result of the response. The handler describes the changed entity through auditEvent.
+func (s *server) audited(action string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		event := &audit.Event{Action: action}
+		recorder := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+		h(recorder, r.WithContext(context.WithValue(r.Context(), auditContextKey{}, event)))
+
+		event.Status = recorder.status
+		if recorder.status >= http.StatusBadRequest {
+			event.Error = strings.TrimSpace(recorder.body.String())
+		}
+		s.recordAudit(r, *event)
+	}
+}
+
+// auditEvent returns the audit event of a request for its handler to describe the changed entity.
+// Requests of routes that are not audited get an event that is discarded.
+func auditEvent(r *http.Request) *audit.Event {
+	if event, ok := r.Context().Value(auditContextKey{}).(*audit.Event); ok {
+		return event
+	}
+	return &audit.Event{}
+}
+
+// describeEntity sets the entity of an audit event, the properties of the entity are the state before the change
+// or after the change
+func describeEntity(event *audit.Event, entity *models.Entity, before bool) {
+	event.EntityType = entity.Type
+	event.EntityID = entity.ID
+	if event.Name == "" {
+		event.Name = entity.Name
+	}
+	if before {
+		event.Before = entity.Properties
+	} else {
+		event.After = entity.Properties
+	}
+}
+
+// recordAudit completes an audit event with the caller and the account of the request and sends it to the sink.
+// Failures of the sink are logged, they do not fail the request. Successful changes are published to the webhooks.
+func (s *server) recordAudit(r *http.Request, event audit.Event) {
+	if s.audit == nil {
+		return
+	}
+	event.Time = time.Now().UTC()
+	event.Caller = requestSubject(r)
+	event.Account = mux.Vars(r)["account"]
+	event.Method = r.Method
+	event.Path = r.URL.Path
+	event.Result = audit.Success
+	if event.Status >= http.StatusBadRequest {
+		event.Result = audit.Failure
+	}
+
+	if err := s.audit.Record(event); err != nil {
+		logger.Error("Unable to record audit event", zap.Any("event", event), zap.Error(err))
+	}
+	if event.Result == audit.Success {
+		s.webhooks.publish(event)
+	}
+}
+
+// auditRecorder records the status of a response, and the message of an error response
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *auditRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *auditRecorder) Write(data []byte) (int, error) {
+	if rec.status >= http.StatusBadRequest && rec.body.Len() < 1024 {
+		rec.body.Write(data)
+	}
+	return rec.ResponseWriter.Write(data)
+}
+
+// parseAuditFilter parses the filter of an audit query from the account of the route and the query parameters
+// entity, type, caller, since, until and limit
+func parseAuditFilter(r *http.Request) (*audit.Filter, error) {
+	query := r.URL.Query()
+	filter := &audit.Filter{
+		Account:    mux.Vars(r)["account"],
+		EntityType: query.Get("type"),
+		Caller:     query.Get("caller"),
+		Limit:      100,
+	}
+
+	if entity := query.Get("entity"); entity != "" {
+		id, err := strconv.Atoi(entity)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid entity value")
+		}
+		filter.EntityID = id
+	}
+	for param, dest := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s value, expected an RFC 3339 time: %v", param, err)
+			}
+			*dest = t
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		parsedLimit, err := strconv.Atoi(limit)
+		if err != nil || parsedLimit <= 0 || parsedLimit > 1000 {
+			return nil, fmt.Errorf("limit must be between 1 and 1000")
+		}
+		filter.Limit = parsedLimit
+	}
+
+	return filter, nil
+}
+
+// AuditHandler returns the most recent audit events, filtered by entity, caller and time range
+func (s *server) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	logger.Info("AuditHandler started")
+
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		logger.Warn("Invalid request parameters", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	querier, ok := s.audit.(audit.Querier)
+	if !ok {
+		http.Error(w, "the audit sink cannot be queried", http.StatusNotImplemented)
+		return
+	}
+	events, err := querier.Query(*filter)
+	if err != nil {
+		logger.Error("Error querying audit events", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("AuditHandler successful", zap.Int("events", len(events)))
+	s.respond(w, events, http.StatusOK)
+}
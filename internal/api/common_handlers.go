@@ -2,6 +2,7 @@ package api
 
 import (
 	"dns-api-go/internal/interfaces"
+	"dns-api-go/internal/models"
 	"dns-api-go/internal/services"
 	"dns-api-go/logger"
 	"fmt"
@@ -105,9 +106,13 @@ func parseEntitiesByHintParams(r *http.Request) (*EntitiesByHintParams, error) {
 }
 
 // HandleGetEntityReq returns an HTTP handler function that processes requests to retrieve an entity by ID.
-// It uses the provided EntityGetter interface to fetch the entity and handles various error scenarios.
-func (s *server) HandleGetEntityReq(service interfaces.EntityGetter) http.HandlerFunc {
+// It uses the EntityGetter selected from the services of the request to fetch the entity and handles various
+// error scenarios.
+func (s *server) HandleGetEntityReq(service func(Services) interfaces.EntityGetter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Send the bluecat requests of the service in the trace of the request
+		service := service(s.servicesFor(r))
+
 		// Parse the entity parameters from the request
 		params, err := parseEntityParams(r)
 		if err != nil {
@@ -140,13 +145,23 @@ func (s *server) HandleGetEntityReq(service interfaces.EntityGetter) http.Handle
 			}
 		}
 
+		// The client already has the current entity
+		if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && matchesETag(ifNoneMatch, entity.ETag(), true) {
+			w.Header().Set("ETag", entity.ETag())
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
 		// Successfully retrieved entity; sending back to client
 		s.respond(w, entity, http.StatusOK)
 	}
 }
 
-func (s *server) HandleDeleteEntityReq(service interfaces.EntityDeleter) http.HandlerFunc {
+func (s *server) HandleDeleteEntityReq(service func(Services) interfaces.EntityDeleter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Send the bluecat requests of the service in the trace of the request
+		service := service(s.servicesFor(r))
+
 		// Parse the entity parameters from the request
 		params, err := parseEntityParams(r)
 		if err != nil {
@@ -155,6 +170,23 @@ func (s *server) HandleDeleteEntityReq(service interfaces.EntityDeleter) http.Ha
 			return
 		}
 
+		// Check that the entity has not changed since the client has seen it
+		if getter, ok := service.(interfaces.EntityGetter); ok {
+			current := func() (*models.Entity, error) { return getter.GetEntity(params.ID, true) }
+			if !s.checkIfMatch(w, r, params.ID, current) {
+				return
+			}
+		}
+
+		// Keep the entity before the delete for the audit log
+		event := auditEvent(r)
+		event.EntityID = params.ID
+		if getter, ok := service.(interfaces.EntityGetter); ok {
+			if before, err := getter.GetEntity(params.ID, true); err == nil {
+				describeEntity(event, before, true)
+			}
+		}
+
 		// Attempt to delete the entity and handle potential errors
 		err = service.DeleteEntity(params.ID)
 		if err != nil {
@@ -185,9 +217,13 @@ func (s *server) HandleDeleteEntityReq(service interfaces.EntityDeleter) http.Ha
 }
 
 // HandleGetEntitiesByHintReq returns an HTTP handler function that processes requests to retrieve entities by hint.
-// It uses the provided EntitiesLister interface to fetch the entities and handles various error scenarios.
-func (s *server) HandleGetEntitiesByHintReq(service interfaces.EntitiesByHintLister) http.HandlerFunc {
+// It uses the EntitiesByHintLister selected from the services of the request to fetch the entities and handles
+// various error scenarios.
+func (s *server) HandleGetEntitiesByHintReq(service func(Services) interfaces.EntitiesByHintLister) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Send the bluecat requests of the service in the trace of the request
+		service := service(s.servicesFor(r))
+
 		// Parse the entity parameters from the request
 		params, err := parseEntitiesByHintParams(r)
 		if err != nil {
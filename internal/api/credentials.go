@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"dns-api-go/internal/common"
+	"dns-api-go/logger"
+	"errors"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// The scopes that routes require and clients are granted
+const (
+	scopeZonesRead      = "zones:read"
+	scopeZonesWrite     = "zones:write"
+	scopeRecordsRead    = "records:read"
+	scopeRecordsWrite   = "records:write"
+	scopeEntitiesRead   = "entities:read"
+	scopeEntitiesDelete = "entities:delete"
+	scopeNetworksRead   = "networks:read"
+	scopeIpsRead        = "ips:read"
+	scopeIpsAssign      = "ips:assign"
+	scopeMacsRead       = "macs:read"
+	scopeMacsWrite      = "macs:write"
+	scopeSystemRead     = "system:read"
+	scopeAuditRead      = "audit:read"
+	scopeJobsRead       = "jobs:read"
+	scopeJobsCancel     = "jobs:cancel"
+)
+
+// SCOPES lists the scopes that can be granted to clients
+var SCOPES = []string{
+	scopeZonesRead,
+	scopeZonesWrite,
+	scopeRecordsRead,
+	scopeRecordsWrite,
+	scopeEntitiesRead,
+	scopeEntitiesDelete,
+	scopeNetworksRead,
+	scopeIpsRead,
+	scopeIpsAssign,
+	scopeMacsRead,
+	scopeMacsWrite,
+	scopeSystemRead,
+	scopeAuditRead,
+	scopeJobsRead,
+	scopeJobsCancel,
+}
+
+// apiClient is a client of the API with its own key
+type apiClient struct {
+	name   string
+	key    []byte
+	scopes []string
+}
+
+// allows checks whether the client was granted the scope, either by name, by the wildcard of its resource,
+// e.g. "records:*", or by "*"
+func (c *apiClient) allows(scope string) bool {
+	resource, _, _ := strings.Cut(scope, ":")
+	for _, granted := range c.scopes {
+		if granted == "*" || granted == scope || granted == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// credentialStore authenticates clients by their tokens.
+// Clients send the bcrypt hash of their key as the token, so a token is compared with the keys of all the clients.
+// Tokens that were verified are remembered for a while to spare the cost of bcrypt on every request.
+// Bearer tokens are verified by the bearer verifier, if OIDC is configured.
+type credentialStore struct {
+	clients  []*apiClient
+	verified *cache.Cache
+	bearer   *jwtVerifier
+}
+
+// newCredentialStore creates the store of the configured clients.
+// The pre-shared token of the configuration, if set, is the key of a client with all the scopes.
+func newCredentialStore(token string, clients []common.Client) (*credentialStore, error) {
+	store := &credentialStore{verified: cache.New(10*time.Minute, 10*time.Minute)}
+	if token != "" {
+		store.clients = append(store.clients, &apiClient{name: "token", key: []byte(token), scopes: []string{"*"}})
+	}
+
+	names := map[string]bool{}
+	for _, c := range clients {
+		if c.Name == "" || c.Key == "" {
+			return nil, fmt.Errorf("clients must have a name and a key")
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("duplicate client '%s'", c.Name)
+		}
+		names[c.Name] = true
+
+		for _, scope := range c.Scopes {
+			if !validScope(scope) {
+				return nil, fmt.Errorf("unknown scope '%s' of client '%s'", scope, c.Name)
+			}
+		}
+		store.clients = append(store.clients, &apiClient{name: c.Name, key: []byte(c.Key), scopes: c.Scopes})
+	}
+
+	return store, nil
+}
+
+// validScope checks whether a scope can be granted, i.e. it is one of SCOPES or a wildcard
+func validScope(scope string) bool {
+	if scope == "*" || common.Contains(SCOPES, scope) {
+		return true
+	}
+	resource, action, _ := strings.Cut(scope, ":")
+	if action != "*" {
+		return false
+	}
+	for _, s := range SCOPES {
+		if strings.HasPrefix(s, resource+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the client whose key matches the token
+func (cs *credentialStore) authenticate(token string) (*apiClient, bool) {
+	if token == "" {
+		return nil, false
+	}
+	if c, ok := cs.verified.Get(token); ok {
+		return c.(*apiClient), true
+	}
+
+	for _, c := range cs.clients {
+		if bcrypt.CompareHashAndPassword([]byte(token), c.key) == nil {
+			cs.verified.SetDefault(token, c)
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// authenticateRequest authenticates the client of a request by its bearer token or its X-Auth-Token
+func (cs *credentialStore) authenticateRequest(r *http.Request) (*apiClient, error) {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && cs.bearer != nil {
+		return cs.bearer.verify(token)
+	}
+	client, ok := cs.authenticate(r.Header.Get("X-Auth-Token"))
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	return client, nil
+}
+
+type clientContextKey struct{}
+
+// withClient returns a context that carries the authenticated client
+func withClient(ctx context.Context, c *apiClient) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, c)
+}
+
+// clientFrom returns the authenticated client of a request context, or nil
+func clientFrom(ctx context.Context) *apiClient {
+	c, _ := ctx.Value(clientContextKey{}).(*apiClient)
+	return c
+}
+
+// requestSubject returns the subject of a request for auditing, i.e. the name of the API client or the subject
+// of the bearer token, or an empty string for public routes
+func requestSubject(r *http.Request) string {
+	if c := clientFrom(r.Context()); c != nil {
+		return c.name
+	}
+	return ""
+}
+
+// requireScope wraps the handler of a route so that it only serves clients that were granted the scope
+func requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := clientFrom(r.Context())
+		if c == nil || !c.allows(scope) {
+			logger.Warn("Client is missing the scope of the route",
+				zap.String("client", requestSubject(r)),
+				zap.String("scope", scope),
+				zap.String("URL", r.URL.String()))
+			http.Error(w, fmt.Sprintf("missing scope %s", scope), http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
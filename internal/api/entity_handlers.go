@@ -2,6 +2,7 @@ package api
 
 import (
 	"dns-api-go/internal/common"
+	"dns-api-go/internal/interfaces"
 	"dns-api-go/internal/services"
 	"dns-api-go/internal/types"
 	"dns-api-go/logger"
@@ -86,12 +87,15 @@ func parseCustomSearchParams(r *http.Request) (*CustomSearchParams, error) {
 
 // GetEntityHandler handles GET requests for retrieving an entity by ID.
 func (s *server) GetEntityHandler() http.HandlerFunc {
-	return s.HandleGetEntityReq(s.services.BaseService)
+	return s.HandleGetEntityReq(func(svc Services) interfaces.EntityGetter { return svc.BaseService })
 }
 
 // DeleteEntityHandler handles DELETE requests for deleting an entity by ID.
+// Deleting a record is checked against the policy like a delete through the records endpoint.
 func (s *server) DeleteEntityHandler() http.HandlerFunc {
-	return s.HandleDeleteEntityReq(s.services.BaseService)
+	return s.authorizeRecordDelete(
+		func(svc Services) interfaces.EntityGetter { return svc.BaseService },
+		s.HandleDeleteEntityReq(func(svc Services) interfaces.EntityDeleter { return svc.BaseService }))
 }
 
 func (s *server) CustomSearchHandler(w http.ResponseWriter, r *http.Request) {
@@ -106,7 +110,7 @@ func (s *server) CustomSearchHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call base service
-	entities, err := s.services.BaseService.CustomSearch(params.offset, params.limit, params.filters, nil, params.objectType)
+	entities, err := s.servicesFor(r).BaseService.CustomSearch(params.offset, params.limit, params.filters, nil, params.objectType)
 	if err != nil {
 		logger.Error("Error with custom search", zap.Error(err))
 		// Determine the type of error and set the HTTP response accordingly
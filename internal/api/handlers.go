@@ -17,14 +17,14 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package api
 
 import (
+	bam "dns-api-go/internal/bluecat"
 	"dns-api-go/logger"
 	"go.uber.org/zap"
 	"net/http"
-	"strings"
 )
 
 func (s *server) HomeHandler(w http.ResponseWriter, _ *http.Request) {
-	account := []string{s.bluecat.account}
+	account := []string{s.account}
 	s.respond(w, account, http.StatusOK)
 }
 
@@ -41,8 +41,8 @@ func (s *server) VersionHandler(w http.ResponseWriter, _ *http.Request) {
 	s.respond(w, s.version, http.StatusOK)
 }
 
-func (s *server) SystemInfoHandler(w http.ResponseWriter, _ *http.Request) {
-	body, err := s.MakeRequest("GET", "/getSystemInfo", "", nil)
+func (s *server) SystemInfoHandler(w http.ResponseWriter, r *http.Request) {
+	info, err := bam.NewClient(s.requesterFor(r)).GetSystemInfo()
 	if err != nil {
 		logger.Error("Failed to retrieve system info",
 			zap.Error(err))
@@ -50,16 +50,6 @@ func (s *server) SystemInfoHandler(w http.ResponseWriter, _ *http.Request) {
 		return
 	}
 
-	// Parse the response body into a map
-	info := make(map[string]string)
-	pairs := strings.Split(string(body), "|")
-	for _, pair := range pairs {
-		kv := strings.Split(pair, "=")
-		if len(kv) == 2 {
-			info[kv[0]] = kv[1]
-		}
-	}
-
 	// Encode the map as JSON and write it to the response
 	s.respond(w, info, http.StatusOK)
 }
@@ -0,0 +1,173 @@
+package api
+
+import (
+	bam "dns-api-go/internal/bluecat"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/types"
+	"dns-api-go/logger"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// healthCheckTimeout is the time the readiness checks get before they are reported as failed
+const healthCheckTimeout = 5 * time.Second
+
+// healthCheck is the outcome of a single readiness check
+type healthCheck struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// readiness is the outcome of the readiness checks, the server is ready when every check passed
+type readiness struct {
+	Status  string                  `json:"status"`
+	Checked time.Time               `json:"checked"`
+	Checks  map[string]*healthCheck `json:"checks,omitempty"`
+}
+
+// healthChecker keeps the outcome of the readiness checks for the interval of the configuration, so that frequent
+// probes do not reach bluecat every time
+type healthChecker struct {
+	lock     sync.Mutex
+	interval time.Duration
+	result   *readiness
+	expires  time.Time
+}
+
+// newHealthChecker creates the health checker of the configuration, results are kept for 10 seconds by default
+func newHealthChecker(c *common.Health) (*healthChecker, error) {
+	interval := 10 * time.Second
+	if c != nil && c.Interval != "" {
+		d, err := time.ParseDuration(c.Interval)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("invalid health check interval '%s'", c.Interval)
+		}
+		interval = d
+	}
+	return &healthChecker{interval: interval}, nil
+}
+
+// readinessChecks returns the checks that the server must pass to be ready. Bluecat must accept the credentials and
+// know the configured view and a configuration, and the CIDR file must parse.
+func (s *server) readinessChecks() map[string]func() error {
+	checks := map[string]func() error{}
+	if s.bluecat != nil {
+		checks["bluecatLogin"] = func() error {
+			_, err := s.getToken()
+			return err
+		}
+		checks["view"] = func() error {
+			viewId, err := strconv.Atoi(s.bluecat.viewId)
+			if err != nil {
+				return fmt.Errorf("invalid view id '%s'", s.bluecat.viewId)
+			}
+			view, err := bam.NewClient(s).GetEntityById(viewId, false)
+			if err != nil {
+				return err
+			}
+			if view.Type != types.VIEW {
+				return fmt.Errorf("entity %d is a %s, not a view", viewId, view.Type)
+			}
+			return nil
+		}
+		// The configuration is looked up in bluecat rather than the lookup cache
+		checks["configuration"] = func() error {
+			configurations, err := bam.NewClient(s).GetEntities(0, types.CONFIGURATION, 0, 1, false)
+			if err != nil {
+				return err
+			}
+			if len(configurations) == 0 {
+				return fmt.Errorf("no configuration found")
+			}
+			return nil
+		}
+	}
+	if s.cidrFile != "" {
+		checks["cidrFile"] = func() error {
+			content, err := s.GetCIDRFile()
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal([]byte(content), &map[string]string{})
+		}
+	}
+	return checks
+}
+
+// check runs the readiness checks at the same time, or returns the outcome of the last run within the interval.
+// Checks that do not finish within the timeout fail.
+func (h *healthChecker) check(checks map[string]func() error) *readiness {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.result != nil && time.Now().Before(h.expires) {
+		return h.result
+	}
+
+	type outcome struct {
+		name  string
+		check *healthCheck
+	}
+	outcomes := make(chan outcome, len(checks))
+	for name, f := range checks {
+		go func(name string, f func() error) {
+			start := time.Now()
+			err := f()
+			check := &healthCheck{Status: "ok", Duration: time.Since(start).String()}
+			if err != nil {
+				check.Status, check.Error = "failed", err.Error()
+			}
+			outcomes <- outcome{name, check}
+		}(name, f)
+	}
+
+	result := &readiness{Status: "ready", Checked: time.Now().UTC(), Checks: map[string]*healthCheck{}}
+	timeout := time.After(healthCheckTimeout)
+	for len(result.Checks) < len(checks) {
+		select {
+		case o := <-outcomes:
+			result.Checks[o.name] = o.check
+		case <-timeout:
+			for name := range checks {
+				if _, ok := result.Checks[name]; !ok {
+					result.Checks[name] = &healthCheck{Status: "failed", Error: "timed out", Duration: healthCheckTimeout.String()}
+				}
+			}
+		}
+	}
+	for name, check := range result.Checks {
+		if check.Status != "ok" {
+			result.Status = "not ready"
+			logger.Warn("Readiness check failed", zap.String("check", name), zap.String("error", check.Error))
+		}
+	}
+
+	h.result, h.expires = result, time.Now().Add(h.interval)
+	return result
+}
+
+// HealthHandler responds to liveness probes, the server is alive as long as it serves requests
+func (s *server) HealthHandler(w http.ResponseWriter, _ *http.Request) {
+	s.respond(w, "ok", http.StatusOK)
+}
+
+// ReadyHandler responds to readiness probes with the outcome of each readiness check. The server is not ready when
+// a check failed, or once it is shutting down so that no new requests are routed to it while it drains.
+func (s *server) ReadyHandler(w http.ResponseWriter, _ *http.Request) {
+	if !s.ready.Load() {
+		s.respond(w, &readiness{Status: "shutting down", Checked: time.Now().UTC()}, http.StatusServiceUnavailable)
+		return
+	}
+
+	result := s.health.check(s.readinessChecks())
+	if result.Status != "ready" {
+		s.respond(w, result, http.StatusServiceUnavailable)
+		return
+	}
+	s.respond(w, result, http.StatusOK)
+}
@@ -1,61 +1,133 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
+	bam "dns-api-go/internal/bluecat"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/policy"
+	"dns-api-go/internal/services"
 	"dns-api-go/logger"
 	"encoding/json"
 	"fmt"
 	"github.com/YaleSpinup/apierror"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/route53"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func (s *server) generateAuthToken(username, password string) (string, error) {
-	// Construct the login URL
-	loginURL := fmt.Sprintf("%s/login?username=%s&password=%s", s.bluecat.baseUrl, username, password)
-	logger.Debug("Login URL", zap.String("URL", loginURL))
+// sessions returns the client that logs the server in to and out of bluecat
+func (s *server) sessions() *bam.SessionClient {
+	return bam.NewSessionClient(s.bluecat.client, s.bluecat.baseUrl, s.bluecat.apiVersion)
+}
 
-	client := &http.Client{
-		Timeout: 120 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
+// newBluecatClient creates the http client that is shared by all requests to bluecat.
+// Connections are kept alive and reused, and certificates are verified unless the configuration explicitly disables it.
+func newBluecatClient(config *common.Bluecat) (*http.Client, error) {
+	timeout := 120 * time.Second
+	if config.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil {
+			return nil, fmt.Errorf("invalid bluecat timeout '%s': %v", config.Timeout, err)
+		}
 	}
 
-	// Send the login request using the custom http.Client
-	resp, err := client.Get(loginURL)
-	if err != nil {
-		logger.Error("Error sending login request", zap.Error(err))
-		return "", err
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c := config.TLS; c != nil {
+		tlsConfig.ServerName = c.ServerName
+
+		if c.CAFile != "" {
+			pem, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("error reading bluecat CA bundle: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in bluecat CA bundle %s", c.CAFile)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		if c.CertFile != "" || c.KeyFile != "" {
+			cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("error loading bluecat client certificate: %v", err)
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+
+		if c.InsecureSkipVerify {
+			logger.Warn("TLS certificate verification of bluecat is disabled")
+			tlsConfig.InsecureSkipVerify = true
+		}
 	}
-	defer resp.Body.Close()
 
-	// Read the response body
-	body, err := io.ReadAll(resp.Body)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	transport.MaxIdleConnsPerHost = 10
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}, nil
+}
+
+// newRoute53Client creates a Route 53 client from the configuration.
+// Without static credentials, the default AWS credential chain is used.
+func newRoute53Client(config *common.Route53) (*route53.Route53, error) {
+	awsConfig := aws.NewConfig().WithRegion(config.Region)
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+	if config.AccessKeyId != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(config.AccessKeyId, config.SecretAccessKey, ""))
+	}
+
+	sess, err := session.NewSession(awsConfig)
 	if err != nil {
-		logger.Error("Error reading login response body", zap.Error(err))
-		return "", err
+		return nil, fmt.Errorf("error creating AWS session: %v", err)
 	}
 
-	// Check the response status code
-	if resp.StatusCode != http.StatusOK {
-		logger.Error("Login failed with status code",
-			zap.Int("StatusCode", resp.StatusCode),
-			zap.String("Body", string(body)))
-		return "", fmt.Errorf("login failed: %s", string(body))
+	return route53.New(sess), nil
+}
+
+// viewId returns the configured bluecat view id.
+// Providers other than bluecat have no views, so 0 is returned when bluecat is not configured.
+func (s *server) viewId() (int, error) {
+	if s.bluecat == nil {
+		return 0, nil
 	}
+	return strconv.Atoi(s.bluecat.viewId)
+}
 
-	// Extract the token from the response body
-	token := strings.TrimPrefix(string(body), "\"Session Token-> ")
-	token = strings.TrimSuffix(token, " <- for User : "+username+"\"")
-	logger.Debug("Generated authentication token", zap.String("Token", token))
+// APIVersion returns the version of the bluecat API that requests are sent to
+func (s *server) APIVersion() string {
+	return s.bluecat.apiVersion
+}
 
-	return token, nil
+// LookupCache returns the cache of the bluecat lookups that rarely change
+func (s *server) LookupCache() *services.LookupCache {
+	if s.bluecat == nil {
+		return nil
+	}
+	return s.bluecat.lookups
 }
 
 func (s *server) getToken() (string, error) {
@@ -63,25 +135,91 @@ func (s *server) getToken() (string, error) {
 	defer s.bluecat.tokenLock.Unlock()
 
 	if s.bluecat.token == "" {
-		token, err := s.generateAuthToken(s.bluecat.user, s.bluecat.password)
+		token, err := s.sessions().Login(s.bluecat.user, s.bluecat.password)
 		if err != nil {
+			bluecatLoginFailures.Inc()
 			return "", err
 		}
+		bluecatTokenRefreshes.Inc()
 		s.bluecat.token = token
 	}
 
 	return s.bluecat.token, nil
 }
 
+// MakeRequest sends a request to bluecat and returns the body of the response.
+// Requests that are not part of an API request, e.g. those of the readiness checks, start their own trace.
 func (s *server) MakeRequest(method, route, queryParam string, body io.Reader) ([]byte, error) {
+	return s.makeRequest(context.Background(), method, route, queryParam, body)
+}
+
+// makeRequest sends a request to bluecat in a span of the trace of the context.
+// Failed requests are sent again as long as the retry policy considers it safe.
+func (s *server) makeRequest(ctx context.Context, method, route, queryParam string, body io.Reader) ([]byte, error) {
+	ctx, span := startBluecatSpan(ctx, method, route)
+	defer span.End()
+
+	// Keep the body so that it can be sent again
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			span.SetStatus(codes.Error, err.Error())
+			return nil, fmt.Errorf("error reading request body: %v", err)
+		}
+	}
+
+	policy := s.bluecat.retry
+	attempt := 0
+	var respBody []byte
+	err := retryWithContext(ctx, policy.attempts, policy.doubling, policy.sleep, func() error {
+		attempt++
+		resp, err := s.sendRequest(ctx, method, route, queryParam, payload)
+		if err != nil {
+			if !policy.retryable(strings.ToUpper(method), route, err) {
+				return stop{err}
+			}
+			logger.Warn("Request to bluecat failed",
+				zap.String("route", route),
+				zap.Int("attempt", attempt),
+				zap.Int("attempts", policy.attempts),
+				zap.Error(err))
+			return err
+		}
+		respBody = resp
+		return nil
+	})
+	if attempt > 1 {
+		span.SetAttributes(semconv.HTTPResendCount(attempt - 1))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+
+	return respBody, nil
+}
+
+// sendRequest sends a single request to bluecat, renewing the token once if it was rejected.
+// The trace context is sent along, and the status of the response is recorded on the span of the context.
+func (s *server) sendRequest(ctx context.Context, method, route, queryParam string, payload []byte) ([]byte, error) {
 	// Construct the API URL
 	apiURL := s.bluecat.baseUrl + route
 	if queryParam != "" {
 		apiURL += "?" + queryParam
 	}
 	token, err := s.getToken()
+	if err != nil {
+		return nil, err
+	}
 	logger.Debug("API URL", zap.String("URL", apiURL))
 
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
 	// Create a new HTTP request
 	req, err := http.NewRequest(strings.ToUpper(method), apiURL, body)
 	if err != nil {
@@ -90,25 +228,25 @@ func (s *server) MakeRequest(method, route, queryParam string, body io.Reader) (
 
 	req.Header.Set("Authorization", token)
 	req.Header.Set("Content-Type", "application/json") // Set Content-Type header
+	propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	// Send the HTTP request
-	client := &http.Client{
-		Timeout: 120 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := s.bluecat.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending HTTP request: %v", err)
+		observeBluecatRequest(method, route, 0, start)
+		return nil, fmt.Errorf("error sending HTTP request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read the response body
 	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		observeBluecatRequest(method, route, 0, start)
+		return nil, fmt.Errorf("error reading response body: %w", err)
 	}
+	observeBluecatRequest(method, route, resp.StatusCode, start)
+	trace.SpanFromContext(ctx).SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
 
 	// Check the response status code
 	if resp.StatusCode == http.StatusUnauthorized {
@@ -121,26 +259,31 @@ func (s *server) MakeRequest(method, route, queryParam string, body io.Reader) (
 		s.bluecat.token = ""
 		s.bluecat.tokenLock.Unlock()
 
-		return s.MakeRequest(method, route, queryParam, body)
+		return s.sendRequest(ctx, method, route, queryParam, payload)
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
 		logger.Error("Unexpected status code received from API",
 			zap.Int("StatusCode", resp.StatusCode),
 			zap.String("Body", string(respBody)))
-		return nil, fmt.Errorf("unexpected status code: %d, Body: %s", resp.StatusCode, string(respBody))
+		return nil, &bam.StatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
 	}
 
 	return respBody, nil
 }
 
 // respond writes the response to the client
-// adds a newline to the end of the response body
+// adds a newline to the end of the response body, and an ETag header when the data is an entity
 func (s *server) respond(w http.ResponseWriter, data interface{}, status int) {
+	if data != nil {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if entity, ok := data.(*models.Entity); ok && entity != nil {
+		w.Header().Set("ETag", entity.ETag())
+	}
 	w.WriteHeader(status)
 
 	if data != nil {
-		w.Header().Set("Content-Type", "application/json")
 		err := json.NewEncoder(w).Encode(data)
 		if err != nil {
 			// Log failure to write the response
@@ -150,6 +293,66 @@ func (s *server) respond(w http.ResponseWriter, data interface{}, status int) {
 	}
 }
 
+// matchesETag checks whether an If-Match or If-None-Match header lists the entity tag.
+// If-None-Match compares tags weakly, i.e. ignoring the W/ prefix of weak tags.
+func matchesETag(header string, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch checks the If-Match header of a request against the current entity before it is changed, so
+// that a client does not overwrite changes it has not seen. It responds with 412 Precondition Failed when the
+// entity has changed or does not exist and returns false when the request should not proceed.
+// The entity is read from bluecat rather than the cache, since another instance of the API may have changed it.
+func (s *server) checkIfMatch(w http.ResponseWriter, r *http.Request, id int, current func() (*models.Entity, error)) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return true
+	}
+
+	if id > 0 {
+		s.LookupCache().Invalidate(id)
+	}
+	entity, err := current()
+	if err != nil {
+		if _, ok := err.(*services.ErrEntityNotFound); ok {
+			http.Error(w, "precondition failed: entity not found", http.StatusPreconditionFailed)
+			return false
+		}
+		logger.Error("Error checking If-Match precondition", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if !matchesETag(ifMatch, entity.ETag(), false) {
+		logger.Info("If-Match precondition failed", zap.Int("id", entity.ID), zap.String("If-Match", ifMatch))
+		w.Header().Set("ETag", entity.ETag())
+		http.Error(w, "precondition failed: entity has been modified", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+// authorize evaluates the policy for a change requested by the client of the request. It responds with
+// 403 Forbidden and returns false when the policy denies the change.
+func (s *server) authorize(w http.ResponseWriter, r *http.Request, req policy.Request) bool {
+	req.Client = requestSubject(r)
+	if err := s.policy.Evaluate(req); err != nil {
+		logger.Warn("Request denied by policy", zap.String("client", req.Client), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
 // handleError handles standard apierror return codes
 func handleError(w http.ResponseWriter, err error) {
 	logger.Error("API error", zap.Error(err))
@@ -189,3 +392,27 @@ func validateMacAddress(macAddress string) error {
 
 	return nil
 }
+
+// responseBuffer keeps the response of a request that is handled internally, like an operation of a batch
+// or the request of a job
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{header: http.Header{}, status: http.StatusOK}
+}
+
+func (rec *responseBuffer) Header() http.Header {
+	return rec.header
+}
+
+func (rec *responseBuffer) WriteHeader(status int) {
+	rec.status = status
+}
+
+func (rec *responseBuffer) Write(data []byte) (int, error) {
+	return rec.body.Write(data)
+}
@@ -0,0 +1,309 @@
+package api
+
+import (
+	"context"
+	bam "dns-api-go/internal/bluecat"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/services"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBluecatClientTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			fmt.Fprint(w, `"Session Token-> token <- for User : user"`)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	// Write the certificate of the test server as a CA bundle
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		tls         *common.TLS
+		expectError bool
+	}{
+		{name: "Unknown certificate authority", tls: nil, expectError: true},
+		{name: "CA bundle", tls: &common.TLS{CAFile: caFile}},
+		{name: "Wrong server name", tls: &common.TLS{CAFile: caFile, ServerName: "bam.example.org"}, expectError: true},
+		{name: "Explicitly insecure", tls: &common.TLS{InsecureSkipVerify: true}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := newServer(context.Background(), common.Config{
+				Org: "test",
+				Bluecat: &common.Bluecat{
+					Account:  "test",
+					BaseUrl:  ts.URL,
+					Username: "user",
+					Timeout:  "5s",
+					TLS:      tc.tls,
+					Retry:    &common.Retry{Sleep: "1ms"},
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := s.MakeRequest(http.MethodGet, "/getSystemInfo", "", nil)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("%s: expected an error, got nil", tc.name)
+				}
+				return
+			}
+			common.CheckError(t, tc.name, nil, err)
+			common.CheckResponse(t, tc.name, "ok", string(resp))
+		})
+	}
+
+	// Invalid configurations are rejected when the server is created
+	for _, b := range []*common.Bluecat{
+		{Timeout: "soon"},
+		{TLS: &common.TLS{CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+		{TLS: &common.TLS{CertFile: caFile}},
+	} {
+		if _, err := newBluecatClient(b); err == nil {
+			t.Errorf("expected an error for bluecat configuration %+v", *b)
+		}
+	}
+}
+
+func TestBluecatV2Session(t *testing.T) {
+	sessions := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/sessions":
+			var credentials map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&credentials); err != nil || credentials["username"] != "user" {
+				http.Error(w, "invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			sessions++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"id": 1, "type": "UserSession", "basicAuthenticationCredentials": "dXNlcjp0b2tlbg=="}`)
+		case "/api/v2/entities/5":
+			// The first request is rejected to make the server start a new session
+			if r.Header.Get("Authorization") != "Basic dXNlcjp0b2tlbg==" || sessions < 2 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"id": 5, "type": "Zone", "name": "example.com", "absoluteName": "example.com"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	s, err := newServer(context.Background(), common.Config{
+		Org: "test",
+		Bluecat: &common.Bluecat{
+			Account:    "test",
+			BaseUrl:    ts.URL + "/",
+			Username:   "user",
+			APIVersion: bam.V2,
+			Retry:      &common.Retry{Sleep: "1ms"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entity, err := bam.NewClient(s).GetEntityById(5, false)
+	common.CheckError(t, "GetEntityById", nil, err)
+	common.CheckResponse(t, "Zone", "example.com", entity.Properties["absoluteName"])
+	common.CheckResponse(t, "Sessions", 2, sessions)
+
+	_, err = newServer(context.Background(), common.Config{
+		Org:     "test",
+		Bluecat: &common.Bluecat{Account: "test", APIVersion: "v3"},
+	})
+	if err == nil {
+		t.Error("expected an error for an unsupported API version")
+	}
+}
+
+func TestNewServerRoute53Account(t *testing.T) {
+	_, err := newServer(context.Background(), common.Config{
+		Org:      "test",
+		Provider: "route53",
+		Bluecat:  &common.Bluecat{Account: "test", APIVersion: bam.V1},
+		Route53:  &common.Route53{Account: "aws", Region: "us-east-1"},
+	})
+	common.CheckError(t, "Different accounts", fmt.Errorf("the route53 account 'aws' differs from the bluecat account 'test'"), err)
+}
+
+// route53APIStub serves the hosted zone example.com through the REST API of Route 53. Record sets are listed in
+// pages of pageSize, and the requests must be signed with the access key AKIDSTUB.
+type route53APIStub struct {
+	lock     sync.Mutex
+	rrsets   []route53StubRecordSet
+	pageSize int
+	pages    int
+	changes  []route53StubChange
+}
+
+type route53StubRecordSet struct {
+	Name   string             `xml:"Name"`
+	Type   string             `xml:"Type"`
+	TTL    int64              `xml:"TTL"`
+	Values []route53StubValue `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53StubValue struct {
+	Value string `xml:"Value"`
+}
+
+type route53StubChange struct {
+	Action            string               `xml:"Action"`
+	ResourceRecordSet route53StubRecordSet `xml:"ResourceRecordSet"`
+}
+
+const route53Namespace = "https://route53.amazonaws.com/doc/2013-04-01/"
+
+func (stub *route53APIStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stub.lock.Lock()
+	defer stub.lock.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDSTUB/") {
+		http.Error(w, "the request is not signed", http.StatusForbidden)
+		return
+	}
+	w.Header().Set("Content-Type", "text/xml")
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/2013-04-01/hostedzone":
+		fmt.Fprintf(w, `<ListHostedZonesResponse xmlns="%s"><HostedZones><HostedZone><Id>/hostedzone/Z123</Id>`+
+			`<Name>example.com.</Name><CallerReference>stub</CallerReference><Config><PrivateZone>false</PrivateZone></Config>`+
+			`</HostedZone></HostedZones><IsTruncated>false</IsTruncated><Marker></Marker><MaxItems>100</MaxItems>`+
+			`</ListHostedZonesResponse>`, route53Namespace)
+	case r.Method == http.MethodGet && r.URL.Path == "/2013-04-01/hostedzone/Z123/rrset":
+		// A page starts at the record set named by the previous page
+		stub.pages++
+		start := 0
+		if name := r.URL.Query().Get("name"); name != "" {
+			for start < len(stub.rrsets) && (stub.rrsets[start].Name != name || stub.rrsets[start].Type != r.URL.Query().Get("type")) {
+				start++
+			}
+		}
+		end := start + stub.pageSize
+		if end > len(stub.rrsets) {
+			end = len(stub.rrsets)
+		}
+		page := struct {
+			XMLName        xml.Name               `xml:"ListResourceRecordSetsResponse"`
+			Namespace      string                 `xml:"xmlns,attr"`
+			RecordSets     []route53StubRecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+			IsTruncated    bool
+			MaxItems       int
+			NextRecordName string `xml:",omitempty"`
+			NextRecordType string `xml:",omitempty"`
+		}{Namespace: route53Namespace, RecordSets: stub.rrsets[start:end], IsTruncated: end < len(stub.rrsets), MaxItems: stub.pageSize}
+		if page.IsTruncated {
+			page.NextRecordName, page.NextRecordType = stub.rrsets[end].Name, stub.rrsets[end].Type
+		}
+		xml.NewEncoder(w).Encode(page)
+	case r.Method == http.MethodPost && r.URL.Path == "/2013-04-01/hostedzone/Z123/rrset/":
+		var request struct {
+			Changes []route53StubChange `xml:"ChangeBatch>Changes>Change"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, change := range request.Changes {
+			stub.changes = append(stub.changes, change)
+			rrsets := stub.rrsets[:0:0]
+			for _, rrset := range stub.rrsets {
+				if rrset.Name != change.ResourceRecordSet.Name || rrset.Type != change.ResourceRecordSet.Type {
+					rrsets = append(rrsets, rrset)
+				}
+			}
+			if change.Action != "DELETE" {
+				rrsets = append(rrsets, change.ResourceRecordSet)
+			}
+			stub.rrsets = rrsets
+		}
+		fmt.Fprintf(w, `<ChangeResourceRecordSetsResponse xmlns="%s"><ChangeInfo><Id>/change/C%d</Id><Status>PENDING</Status>`+
+			`<SubmittedAt>2024-03-01T12:00:00Z</SubmittedAt></ChangeInfo></ChangeResourceRecordSetsResponse>`, route53Namespace, len(stub.changes))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestRoute53Client(t *testing.T) {
+	stub := &route53APIStub{pageSize: 2, rrsets: []route53StubRecordSet{
+		{Name: "example.com.", Type: "MX", TTL: 300, Values: []route53StubValue{{"10 mail.example.com."}, {"20 mail2.example.com."}}},
+		{Name: "example.com.", Type: "NS", TTL: 172800, Values: []route53StubValue{{"ns-1.awsdns-01.org."}}},
+		{Name: "example.com.", Type: "SOA", TTL: 900, Values: []route53StubValue{{"ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400"}}},
+		{Name: "example.com.", Type: "TXT", TTL: 300, Values: []route53StubValue{{`"v=spf1 -all"`}}},
+		{Name: "www.example.com.", Type: "A", TTL: 300, Values: []route53StubValue{{"10.0.0.1"}}},
+	}}
+	ts := httptest.NewServer(stub)
+	defer ts.Close()
+
+	client, err := newRoute53Client(&common.Route53{Region: "us-east-1", Endpoint: ts.URL, AccessKeyId: "AKIDSTUB", SecretAccessKey: "secret"})
+	common.CheckError(t, "newRoute53Client", nil, err)
+	recordService := services.NewRoute53RecordService(client, nil)
+	list := func(recordType string) []models.Entity {
+		entities, err := recordService.GetRecordsByType(recordType, map[string]interface{}{"start": 0, "count": 10}, 0)
+		common.CheckError(t, "List "+recordType, nil, err)
+		return *entities
+	}
+
+	// The record sets are listed across the pages
+	common.CheckResponse(t, "MX records", 2, len(list("MXRecord")))
+	common.CheckResponse(t, "Host records", 1, len(list("HostRecord")))
+	if stub.pages < 3 {
+		t.Errorf("expected the record sets to be listed in 3 pages, got %d", stub.pages)
+	}
+
+	// A created record is sent as a change of its record set and read back
+	created, err := recordService.CreateRecord("HostRecord", map[string]interface{}{
+		"absoluteName": "app.example.com",
+		"addresses":    []string{"10.0.0.2", "10.0.0.3"},
+		"ttl":          600,
+	}, 0)
+	common.CheckError(t, "Create host record", nil, err)
+	common.CheckResponse(t, "Change", route53StubChange{Action: "UPSERT", ResourceRecordSet: route53StubRecordSet{
+		Name: "app.example.com.", Type: "A", TTL: 600, Values: []route53StubValue{{"10.0.0.2"}, {"10.0.0.3"}},
+	}}, stub.changes[0])
+	found, err := recordService.GetEntity(created.ID, false)
+	common.CheckError(t, "Get created record", nil, err)
+	common.CheckResponse(t, "Get created record", "10.0.0.2,10.0.0.3", found.Properties["addresses"])
+
+	// Deleting one MX record keeps the other value of the record set
+	for _, mx := range list("MXRecord") {
+		if mx.Properties["priority"] == "10" {
+			common.CheckError(t, "Delete MX record", nil, recordService.DeleteEntity(mx.ID))
+		}
+	}
+	mx := list("MXRecord")
+	common.CheckResponse(t, "Remaining MX records", 1, len(mx))
+	common.CheckResponse(t, "Remaining MX record", "mail2.example.com", mx[0].Properties["linkedRecordName"])
+
+	// Requests signed with other credentials are rejected by the stub
+	unsigned, err := newRoute53Client(&common.Route53{Region: "us-east-1", Endpoint: ts.URL, AccessKeyId: "OTHER", SecretAccessKey: "secret"})
+	common.CheckError(t, "newRoute53Client", nil, err)
+	if _, err := services.NewRoute53RecordService(unsigned, nil).GetEntity(created.ID, false); err == nil {
+		t.Error("expected a request with other credentials to fail")
+	}
+}
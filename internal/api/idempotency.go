@@ -0,0 +1,144 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"dns-api-go/internal/common"
+	"dns-api-go/logger"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// maxIdempotencyKeyLength is the longest Idempotency-Key header that is accepted
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodySize is the largest request body of a request with an Idempotency-Key
+	maxIdempotentBodySize = 1 << 20
+)
+
+// idempotentResponse is the response to the first request with an idempotency key.
+// The response is incomplete while the first request is processed.
+type idempotentResponse struct {
+	hash   [sha256.Size]byte
+	done   bool
+	status int
+	header http.Header
+	body   []byte
+}
+
+// idempotencyStore keeps the responses to the requests with idempotency keys for the window of the configuration
+type idempotencyStore struct {
+	lock      sync.Mutex
+	window    time.Duration
+	responses *cache.Cache
+}
+
+// newIdempotencyStore creates the store of the configuration, responses are kept for a day by default
+func newIdempotencyStore(c *common.Idempotency) (*idempotencyStore, error) {
+	window := 24 * time.Hour
+	if c != nil && c.Window != "" {
+		d, err := time.ParseDuration(c.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid idempotency window '%s': %v", c.Window, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("idempotency window must be positive")
+		}
+		window = d
+	}
+	return &idempotencyStore{window: window, responses: cache.New(window, 10*time.Minute)}, nil
+}
+
+// idempotent wraps the handler of a POST route so that a request with an Idempotency-Key header is processed once.
+// A repeated request with the same key, query and body gets the stored response of the first request, and a request
+// that reuses the key for a different request or while the first request is processed gets 409 Conflict. Keys are
+// scoped to the client, and server errors are not stored, so the request can be retried with the same key.
+func (s *server) idempotent(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		if key == "" || s.idempotency == nil {
+			h(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodySize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n"), body...))
+		cacheKey := requestSubject(r) + "\x00" + key
+
+		store := s.idempotency
+		store.lock.Lock()
+		if value, ok := store.responses.Get(cacheKey); ok {
+			first := *value.(*idempotentResponse)
+			store.lock.Unlock()
+
+			switch {
+			case first.hash != hash:
+				logger.Warn("Idempotency key reused for a different request", zap.String("key", key))
+				http.Error(w, "Idempotency-Key was already used for a different request", http.StatusConflict)
+			case !first.done:
+				http.Error(w, "a request with the Idempotency-Key is in progress", http.StatusConflict)
+			default:
+				logger.Info("Replaying response for idempotency key", zap.String("key", key))
+				for name, values := range first.header {
+					w.Header()[name] = values
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(first.status)
+				w.Write(first.body)
+			}
+			return
+		}
+		response := &idempotentResponse{hash: hash}
+		store.responses.Set(cacheKey, response, store.window)
+		store.lock.Unlock()
+
+		// Complete the entry even if the handler panics, e.g. with http.ErrAbortHandler, so that the key can be retried
+		recorder := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			store.lock.Lock()
+			defer store.lock.Unlock()
+			if !completed || recorder.status >= http.StatusInternalServerError {
+				store.responses.Delete(cacheKey)
+				return
+			}
+			response.status = recorder.status
+			response.header = w.Header().Clone()
+			response.body = recorder.body.Bytes()
+			response.done = true
+		}()
+		h(recorder, r)
+		completed = true
+	}
+}
+
+// idempotencyRecorder records the status and the body of a response
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *idempotencyRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *idempotencyRecorder) Write(data []byte) (int, error) {
+	rec.body.Write(data)
+	return rec.ResponseWriter.Write(data)
+}
@@ -2,7 +2,11 @@ package api
 
 import (
 	"dns-api-go/internal/common"
+	"dns-api-go/internal/interfaces"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/policy"
 	"dns-api-go/internal/services"
+	"dns-api-go/internal/types"
 	"dns-api-go/logger"
 	"encoding/json"
 	"fmt"
@@ -58,7 +62,7 @@ func parseAssignIpAddressBody(s *server, ipAddressService services.IpAddressEnti
 
 	// If there is no parent id provided, attempt to find it from the provided CIDR
 	if AssignIpAddressParams.ParentId == 0 {
-		cidrParentId, err := parentIdFromCidr(s, ipAddressService, AssignIpAddressParams.CIDR)
+		cidrParentId, err := parentIdFromCidr(s.requesterFor(r), ipAddressService, AssignIpAddressParams.CIDR)
 		if err != nil {
 			return nil, fmt.Errorf("you must either pass a valid network_id or a valid CIDR")
 		}
@@ -80,7 +84,7 @@ func parseAssignIpAddressBody(s *server, ipAddressService services.IpAddressEnti
 }
 
 // parentIdFromCidr returns the parent ID for the given CIDR range.
-func parentIdFromCidr(s *server, ipAddressService services.IpAddressEntityService, cidr string) (int, error) {
+func parentIdFromCidr(server interfaces.ServerInterface, ipAddressService services.IpAddressEntityService, cidr string) (int, error) {
 	// Check if cidr is empty
 	if cidr == "" {
 		return -1, fmt.Errorf("CIDR cannot be empty")
@@ -109,7 +113,7 @@ func parentIdFromCidr(s *server, ipAddressService services.IpAddressEntityServic
 		}
 
 		// Attempt to find parent ID of the IP address entity
-		parentID, err := services.GetParentID(s, ipAddressEntity.ID)
+		parentID, err := services.GetParentID(server, ipAddressEntity.ID)
 		if err == nil {
 			return parentID, nil
 		}
@@ -144,7 +148,7 @@ func (s *server) GetIpAddressHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Attempt to retrieve the ip address entity and handle potential errors
-	entity, err := s.services.IpAddressService.GetIpAddress(params.Address)
+	entity, err := s.servicesFor(r).IpAddressService.GetIpAddress(params.Address)
 	if err != nil {
 		logger.Error("Error retrieving ip address entity",
 			zap.String("address", params.Address),
@@ -177,8 +181,26 @@ func (s *server) DeleteIpAddressHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Check that the client may release the ip address
+	if !s.authorize(w, r, policy.Request{Action: policy.ReleaseIp, Networks: []string{params.Address}}) {
+		return
+	}
+
+	// Check that the ip address has not changed since the client has seen it
+	current := func() (*models.Entity, error) { return s.servicesFor(r).IpAddressService.GetIpAddress(params.Address) }
+	if !s.checkIfMatch(w, r, 0, current) {
+		return
+	}
+
+	// Keep the ip address before the delete for the audit log
+	event := auditEvent(r)
+	event.Name = params.Address
+	if before, err := current(); err == nil {
+		describeEntity(event, before, true)
+	}
+
 	// Attempt to delete the ip address and handle potential errors
-	err = s.services.IpAddressService.DeleteIpAddress(params.Address)
+	err = s.servicesFor(r).IpAddressService.DeleteIpAddress(params.Address)
 	if err != nil {
 		logger.Error("Error deleting ip address", zap.String("address", params.Address), zap.Error(err))
 
@@ -210,14 +232,41 @@ func (s *server) AssignIpAddressHandler(w http.ResponseWriter, r *http.Request)
 	logger.Info("AssignIpAddressHandler started")
 
 	// Parse the body from the request
-	body, err := parseAssignIpAddressBody(s, s.services.IpAddressService, r)
+	body, err := parseAssignIpAddressBody(s, s.servicesFor(r).IpAddressService, r)
 	if err != nil {
 		logger.Warn("Invalid request body", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Set hostInfo
+	event := auditEvent(r)
+	event.Name = body.Hostname
+
+	// Check that the client may assign an address of the network to the hostname
+	if s.policy != nil {
+		network, err := s.servicesFor(r).NetworkService.GetEntity(body.ParentId, false)
+		if err != nil {
+			logger.Error("Error getting network for policy", zap.Int("networkId", body.ParentId), zap.Error(err))
+			switch err.(type) {
+			case *services.ErrEntityNotFound, *services.ErrEntityTypeMismatch:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		req := policy.Request{
+			Action:     policy.AssignIp,
+			Name:       body.Hostname,
+			RecordType: types.HOSTRECORD,
+			Networks:   []string{network.Properties["CIDR"]},
+		}
+		if !s.authorize(w, r, req) {
+			return
+		}
+	}
+
+	// Set hostInfo, bluecat creates the linked host record along with the address
 	hostInfo := map[string]string{
 		"hostname":       body.Hostname,
 		"viewId":         s.bluecat.viewId,
@@ -230,7 +279,7 @@ func (s *server) AssignIpAddressHandler(w http.ResponseWriter, r *http.Request)
 	propertiesMap["name"] = body.Hostname
 
 	// Assign the ip address and handle potential errors
-	entity, err := s.services.IpAddressService.AssignIpAddress(
+	entity, err := s.servicesFor(r).IpAddressService.AssignIpAddress(
 		"MAKE_STATIC", body.MacAddress, body.ParentId, hostInfo, propertiesMap)
 	if err != nil {
 		logger.Error("Error assigning ip address", zap.Error(err))
@@ -238,6 +287,8 @@ func (s *server) AssignIpAddressHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	describeEntity(event, entity, false)
+
 	// Create temporary struct that adds ip field to entity fields
 	type tempEntity struct {
 		ID         int               `json:"id"`
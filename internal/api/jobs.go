@@ -0,0 +1,438 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"dns-api-go/internal/common"
+	"dns-api-go/logger"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/patrickmn/go-cache"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The states of a job
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+const (
+	// jobQueueSize is the number of jobs that can wait for a worker
+	jobQueueSize = 100
+	// maxJobBodySize is the largest request body of an asynchronous request
+	maxJobBodySize = 10 << 20
+)
+
+type jobContextKey struct{}
+
+// jobProgress counts the items of a job that are done, out of the total once the job knows it
+type jobProgress struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// jobState is the state of a job as reported by the jobs endpoints. Results holds the result of each item as
+// soon as it is done, Response holds the response of the request once the job finished.
+type jobState struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Account    string            `json:"account"`
+	Owner      string            `json:"owner"`
+	Status     string            `json:"status"`
+	Created    time.Time         `json:"created"`
+	Started    *time.Time        `json:"started,omitempty"`
+	Finished   *time.Time        `json:"finished,omitempty"`
+	Progress   jobProgress       `json:"progress"`
+	Results    []json.RawMessage `json:"results,omitempty"`
+	StatusCode int               `json:"statusCode,omitempty"`
+	Response   json.RawMessage   `json:"response,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// job is a request that is handled in the background
+type job struct {
+	lock    sync.Mutex
+	state   jobState
+	handler http.HandlerFunc
+	request *http.Request
+	cancel  context.CancelFunc
+}
+
+// jobManager queues the jobs for its workers, which run them under the server context.
+// Finished jobs are kept for the retention of the configuration.
+type jobManager struct {
+	ctx       context.Context
+	retention time.Duration
+	jobs      *cache.Cache
+	lock      sync.Mutex
+	closed    bool
+	queue     chan *job
+	workers   sync.WaitGroup
+}
+
+// newJobManager creates the job manager of the configuration and starts its workers.
+// Two jobs run at the same time and finished jobs are kept for an hour by default.
+func newJobManager(ctx context.Context, c *common.Jobs) (*jobManager, error) {
+	workers, retention := 2, time.Hour
+	if c != nil {
+		if c.Workers < 0 {
+			return nil, fmt.Errorf("job workers cannot be negative")
+		}
+		if c.Workers > 0 {
+			workers = c.Workers
+		}
+		if c.Retention != "" {
+			d, err := time.ParseDuration(c.Retention)
+			if err != nil {
+				return nil, fmt.Errorf("invalid job retention '%s': %v", c.Retention, err)
+			}
+			if d <= 0 {
+				return nil, fmt.Errorf("job retention must be positive")
+			}
+			retention = d
+		}
+	}
+
+	m := &jobManager{
+		ctx:       ctx,
+		retention: retention,
+		jobs:      cache.New(retention, 10*time.Minute),
+		queue:     make(chan *job, jobQueueSize),
+	}
+	for i := 0; i < workers; i++ {
+		m.workers.Add(1)
+		go m.run()
+	}
+	return m, nil
+}
+
+// run runs the queued jobs until the manager is drained
+func (m *jobManager) run() {
+	defer m.workers.Done()
+	for j := range m.queue {
+		if err := m.ctx.Err(); err != nil {
+			j.interrupt(err)
+		} else {
+			j.run()
+		}
+		m.jobs.Set(j.state.ID, j, m.retention)
+	}
+}
+
+// drain stops accepting jobs and waits until the queued and running jobs finished, or until the context is done.
+// Jobs that are still queued when the server context is done fail without running.
+func (m *jobManager) drain(ctx context.Context) error {
+	m.lock.Lock()
+	if !m.closed {
+		m.closed = true
+		close(m.queue)
+	}
+	m.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		m.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unfinished returns the ids of the jobs that are queued or running
+func (m *jobManager) unfinished() []string {
+	ids := []string{}
+	for id, item := range m.jobs.Items() {
+		if item.Object.(*job).snapshot().Finished == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// submit queues a job that runs the handler with a copy of the request. The job keeps the values of the request
+// context, like the client and the route variables, but it is cancelled with the server context instead of
+// the request.
+func (m *jobManager) submit(r *http.Request, jobType string, body []byte, h http.HandlerFunc) (*job, error) {
+	j := &job{
+		state: jobState{
+			ID:      newRandomId(),
+			Type:    jobType,
+			Account: mux.Vars(r)["account"],
+			Owner:   requestSubject(r),
+			Status:  JobQueued,
+			Created: time.Now().UTC(),
+		},
+		handler: h,
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	stop := context.AfterFunc(m.ctx, cancel)
+	j.cancel = func() {
+		stop()
+		cancel()
+	}
+	j.request = r.Clone(context.WithValue(ctx, jobContextKey{}, j))
+	j.request.Body = io.NopCloser(bytes.NewReader(body))
+	j.request.ContentLength = int64(len(body))
+
+	// Queued and running jobs do not expire, the worker sets the retention when the job finished
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.closed {
+		j.cancel()
+		return nil, fmt.Errorf("the server is shutting down, try again later")
+	}
+	m.jobs.Set(j.state.ID, j, cache.NoExpiration)
+	select {
+	case m.queue <- j:
+	default:
+		m.jobs.Delete(j.state.ID)
+		j.cancel()
+		return nil, fmt.Errorf("too many jobs are queued, try again later")
+	}
+	return j, nil
+}
+
+// get returns a job of the account that was submitted by the client of the request
+func (m *jobManager) get(r *http.Request, id string) (*job, bool) {
+	value, ok := m.jobs.Get(id)
+	if !ok {
+		return nil, false
+	}
+	j := value.(*job)
+	state := j.snapshot()
+	if state.Account != mux.Vars(r)["account"] || state.Owner != requestSubject(r) {
+		return nil, false
+	}
+	return j, true
+}
+
+// list returns the jobs of the account that were submitted by the client of the request, the most recent first
+func (m *jobManager) list(r *http.Request) []jobState {
+	jobs := []jobState{}
+	for _, item := range m.jobs.Items() {
+		state := item.Object.(*job).snapshot()
+		if state.Account == mux.Vars(r)["account"] && state.Owner == requestSubject(r) {
+			state.Results, state.Response = nil, nil
+			jobs = append(jobs, state)
+		}
+	}
+	sort.Slice(jobs, func(i, k int) bool { return jobs[i].Created.After(jobs[k].Created) })
+	return jobs
+}
+
+// run handles the request of the job and keeps its response. The job failed when the request failed or any
+// of its items failed.
+func (j *job) run() {
+	defer j.cancel()
+
+	j.lock.Lock()
+	if j.state.Status != JobQueued {
+		j.lock.Unlock()
+		return
+	}
+	started := time.Now().UTC()
+	j.state.Status, j.state.Started = JobRunning, &started
+	j.lock.Unlock()
+	logger.Info("Job started", zap.String("id", j.state.ID), zap.String("type", j.state.Type))
+
+	recorder := newResponseBuffer()
+	j.handler(recorder, j.request)
+	body := bytes.TrimSpace(recorder.body.Bytes())
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	finished := time.Now().UTC()
+	j.state.Finished = &finished
+	j.state.StatusCode = recorder.status
+	if len(body) > 0 && json.Valid(body) {
+		j.state.Response = body
+	} else if recorder.status >= http.StatusBadRequest {
+		j.state.Error = string(body)
+	}
+
+	switch {
+	case j.state.Status == JobCancelled:
+	case recorder.status >= http.StatusMultipleChoices || j.state.Progress.Failed > 0:
+		j.state.Status = JobFailed
+	default:
+		j.state.Status = JobSucceeded
+	}
+	if err := j.request.Context().Err(); err != nil && j.state.Status != JobCancelled {
+		j.state.Status, j.state.Error = JobFailed, fmt.Sprintf("job was interrupted: %v", err)
+	}
+	logger.Info("Job finished",
+		zap.String("id", j.state.ID),
+		zap.String("type", j.state.Type),
+		zap.String("status", j.state.Status),
+		zap.Int("statusCode", recorder.status))
+}
+
+// interrupt fails a queued job without running it
+func (j *job) interrupt(err error) {
+	defer j.cancel()
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.state.Status != JobQueued {
+		return
+	}
+	finished := time.Now().UTC()
+	j.state.Status, j.state.Finished = JobFailed, &finished
+	j.state.Error = fmt.Sprintf("job was interrupted: %v", err)
+}
+
+// stop cancels a job that is queued or running. A running job stops at its next item, the items that are done
+// are handled like a failure of the request. It returns false when the job already finished.
+func (j *job) stop() bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	switch j.state.Status {
+	case JobQueued:
+		finished := time.Now().UTC()
+		j.state.Finished = &finished
+	case JobRunning:
+	default:
+		return false
+	}
+	j.state.Status = JobCancelled
+	j.cancel()
+	return true
+}
+
+// snapshot returns a copy of the state of the job
+func (j *job) snapshot() jobState {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	state := j.state
+	state.Results = append([]json.RawMessage(nil), j.state.Results...)
+	return state
+}
+
+// jobFrom returns the job that handles a request, or nil if the request is not handled by a job
+func jobFrom(ctx context.Context) *job {
+	j, _ := ctx.Value(jobContextKey{}).(*job)
+	return j
+}
+
+// setTotal sets the number of items of the job
+func (j *job) setTotal(total int) {
+	if j == nil {
+		return
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.state.Progress.Total = total
+}
+
+// itemDone records the result of an item of the job
+func (j *job) itemDone(result interface{}, failed bool) {
+	if j == nil {
+		return
+	}
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		logger.Error("Unable to encode job result", zap.Error(err))
+		return
+	}
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.state.Results = append(j.state.Results, encoded)
+	j.state.Progress.Completed++
+	if failed {
+		j.state.Progress.Failed++
+	}
+}
+
+// async wraps the handler of a POST route so that a request with the query parameter async=true is handled by a
+// background job. The request is answered with 202 Accepted, the job and its location.
+func (s *server) async(jobType string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		async := false
+		if asyncStr := r.URL.Query().Get("async"); asyncStr != "" {
+			var err error
+			if async, err = strconv.ParseBool(asyncStr); err != nil {
+				http.Error(w, "invalid async value", http.StatusBadRequest)
+				return
+			}
+		}
+		if !async || s.jobs == nil {
+			h(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxJobBodySize))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		j, err := s.jobs.submit(r, jobType, body, h)
+		if err != nil {
+			logger.Warn("Unable to queue job", zap.String("type", jobType), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		state := j.snapshot()
+		logger.Info("Job queued", zap.String("id", state.ID), zap.String("type", jobType))
+		w.Header().Set("Location", jobLocation(r, state.ID))
+		s.respond(w, state, http.StatusAccepted)
+	}
+}
+
+// jobLocation returns the path of a job in the account of the request
+func jobLocation(r *http.Request, id string) string {
+	account := mux.Vars(r)["account"]
+	prefix, _, _ := strings.Cut(r.URL.Path, "/"+account+"/")
+	return prefix + "/" + account + "/jobs/" + id
+}
+
+// GetJobsHandler lists the jobs of the client in the account without their results
+func (s *server) GetJobsHandler(w http.ResponseWriter, r *http.Request) {
+	s.respond(w, s.jobs.list(r), http.StatusOK)
+}
+
+// GetJobHandler reports the progress, the results and the errors of a job of the client
+func (s *server) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	j, ok := s.jobs.get(r, id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("job %s not found", id), http.StatusNotFound)
+		return
+	}
+	s.respond(w, j.snapshot(), http.StatusOK)
+}
+
+// CancelJobHandler cancels a queued or running job of the client. Jobs that finished cannot be cancelled.
+func (s *server) CancelJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	j, ok := s.jobs.get(r, id)
+	if !ok {
+		http.Error(w, fmt.Sprintf("job %s not found", id), http.StatusNotFound)
+		return
+	}
+	if !j.stop() {
+		http.Error(w, fmt.Sprintf("job %s already finished", id), http.StatusConflict)
+		return
+	}
+
+	logger.Info("Job cancelled", zap.String("id", id))
+	s.respond(w, j.snapshot(), http.StatusAccepted)
+}
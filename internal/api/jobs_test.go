@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"dns-api-go/internal/common"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// waitForJob waits until the job finished and returns its state
+func waitForJob(t *testing.T, j *job) jobState {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if state := j.snapshot(); state.Finished != nil && state.Status != JobRunning {
+			return state
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", j.snapshot().ID)
+	return jobState{}
+}
+
+func TestJobManager(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := newJobManager(ctx, &common.Jobs{Retention: "forever"}); err == nil {
+		t.Error("expected an invalid retention to be rejected")
+	}
+	m, err := newJobManager(ctx, &common.Jobs{Workers: 1, Retention: "1m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := asAdmin(mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/v2/dns/test/records/batch", nil), map[string]string{"account": "test"}))
+	started := make(chan struct{})
+	blocking := func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		http.Error(w, r.Context().Err().Error(), http.StatusInternalServerError)
+	}
+	items := func(w http.ResponseWriter, r *http.Request) {
+		j := jobFrom(r.Context())
+		j.setTotal(2)
+		j.itemDone(map[string]int{"index": 0}, false)
+		j.itemDone(map[string]int{"index": 1}, true)
+		w.WriteHeader(http.StatusMultiStatus)
+		w.Write([]byte(`{"results": []}`))
+	}
+
+	// The single worker runs the first job, the second one waits in the queue
+	running, err := m.submit(req, "test", nil, blocking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	queued, err := m.submit(req, "test", nil, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.CheckResponse(t, "Running job", JobRunning, running.snapshot().Status)
+	common.CheckResponse(t, "Queued job", JobQueued, queued.snapshot().Status)
+
+	// Cancelled jobs keep their state, and finished jobs cannot be cancelled
+	common.CheckResponse(t, "Cancel queued job", true, queued.stop())
+	common.CheckResponse(t, "Cancel running job", true, running.stop())
+	state := waitForJob(t, running)
+	common.CheckResponse(t, "Cancelled job", JobCancelled, state.Status)
+	common.CheckResponse(t, "Cancelled job", http.StatusInternalServerError, state.StatusCode)
+	common.CheckResponse(t, "Cancel finished job", false, running.stop())
+	common.CheckResponse(t, "Cancelled queued job", JobCancelled, waitForJob(t, queued).Status)
+	common.CheckResponse(t, "Cancelled queued job", (*time.Time)(nil), queued.snapshot().Started)
+
+	// The progress and the results of the items are reported
+	finished, err := m.submit(req, "test", nil, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state = waitForJob(t, finished)
+	common.CheckResponse(t, "Failed job", JobFailed, state.Status)
+	common.CheckResponse(t, "Progress", jobProgress{Total: 2, Completed: 2, Failed: 1}, state.Progress)
+	common.CheckResponse(t, "Results", 2, len(state.Results))
+	common.CheckResponse(t, "Response", `{"results": []}`, string(state.Response))
+
+	// Jobs are only found by the client and in the account that submitted them
+	if _, ok := m.get(req, state.ID); !ok {
+		t.Errorf("expected job %s to be found", state.ID)
+	}
+	other := mux.SetURLVars(req.Clone(withClient(req.Context(), &apiClient{name: "other"})), map[string]string{"account": "test"})
+	if _, ok := m.get(other, state.ID); ok {
+		t.Errorf("expected job %s to be hidden from another client", state.ID)
+	}
+	common.CheckResponse(t, "Listed jobs", 3, len(m.list(req)))
+	common.CheckResponse(t, "Listed jobs of another client", 0, len(m.list(other)))
+
+	// Draining waits for the jobs until the server context is done, which interrupts them
+	started = make(chan struct{})
+	interrupted, err := m.submit(req, "test", nil, blocking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	queued, err = m.submit(req, "test", nil, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelTimeout()
+	common.CheckResponse(t, "Drain running job", context.DeadlineExceeded, m.drain(timeout))
+	if _, err := m.submit(req, "test", nil, items); err == nil {
+		t.Error("expected a drained manager to reject jobs")
+	}
+	cancel()
+	common.CheckResponse(t, "Drain interrupted job", nil, m.drain(context.Background()))
+	state = waitForJob(t, interrupted)
+	common.CheckResponse(t, "Interrupted job", JobFailed, state.Status)
+	common.CheckResponse(t, "Interrupted job", "job was interrupted: context canceled", state.Error)
+	common.CheckResponse(t, "Interrupted queued job", JobFailed, queued.snapshot().Status)
+	common.CheckResponse(t, "Interrupted queued job", (*time.Time)(nil), queued.snapshot().Started)
+}
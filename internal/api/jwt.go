@@ -0,0 +1,383 @@
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"dns-api-go/internal/common"
+	"dns-api-go/logger"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jwtLeeway is the clock skew tolerated when checking the expiry of tokens
+const jwtLeeway = time.Minute
+
+// jwtHashes are the hashes of the supported signature algorithms
+var jwtHashes = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"PS256": crypto.SHA256,
+	"PS384": crypto.SHA384,
+	"PS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// jwtVerifier verifies the bearer tokens of an OpenID Connect provider and maps their groups to scopes
+type jwtVerifier struct {
+	issuer      string
+	audience    string
+	groupsClaim string
+	groupScopes map[string][]string
+	jwksURL     string
+	client      *http.Client
+	lock        sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetched     time.Time
+}
+
+// newJWTVerifier creates a verifier from the configuration, nil is returned when OIDC is not configured.
+// The keys of a JWKS file are read right away, the keys of a JWKS url on first use and again when a token
+// is signed with an unknown key.
+func newJWTVerifier(c *common.OIDC) (*jwtVerifier, error) {
+	if c == nil {
+		return nil, nil
+	}
+	if c.Issuer == "" || c.Audience == "" {
+		return nil, errors.New("oidc requires an issuer and an audience")
+	}
+	if (c.JWKSFile == "") == (c.JWKSURL == "") {
+		return nil, errors.New("oidc requires either a jwksFile or a jwksUrl")
+	}
+	for group, scopes := range c.GroupScopes {
+		for _, scope := range scopes {
+			if !validScope(scope) {
+				return nil, fmt.Errorf("unknown scope '%s' of group '%s'", scope, group)
+			}
+		}
+	}
+
+	v := &jwtVerifier{
+		issuer:      c.Issuer,
+		audience:    c.Audience,
+		groupsClaim: c.GroupsClaim,
+		groupScopes: c.GroupScopes,
+		jwksURL:     c.JWKSURL,
+		keys:        map[string]crypto.PublicKey{},
+	}
+	if v.groupsClaim == "" {
+		v.groupsClaim = "groups"
+	}
+
+	if c.JWKSFile != "" {
+		data, err := os.ReadFile(c.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read jwks file: %v", err)
+		}
+		if v.keys, err = parseJWKS(data); err != nil {
+			return nil, err
+		}
+	} else {
+		v.client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return v, nil
+}
+
+// jwtClaims are the registered claims of a token that are verified
+type jwtClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  audience `json:"aud"`
+	Expires   float64  `json:"exp"`
+	NotBefore float64  `json:"nbf"`
+}
+
+// audience is the audience claim, which is either a single string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// verify checks the signature and the claims of a token and returns the client it identifies.
+// The client is named after the subject of the token and granted the scopes of its groups.
+func (v *jwtVerifier) verify(token string) (*apiClient, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid token header: %v", err)
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature: %v", err)
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %v", err)
+	}
+	now := time.Now()
+	switch {
+	case claims.Issuer != v.issuer:
+		return nil, fmt.Errorf("unexpected issuer '%s'", claims.Issuer)
+	case !common.Contains(claims.Audience, v.audience):
+		return nil, fmt.Errorf("token is not issued for '%s'", v.audience)
+	case claims.Subject == "":
+		return nil, errors.New("token has no subject")
+	case claims.Expires == 0:
+		return nil, errors.New("token has no expiry")
+	case now.After(numericDate(claims.Expires).Add(jwtLeeway)):
+		return nil, errors.New("token is expired")
+	case claims.NotBefore != 0 && now.Add(jwtLeeway).Before(numericDate(claims.NotBefore)):
+		return nil, errors.New("token is not valid yet")
+	}
+
+	groups, err := v.groups(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	var scopes []string
+	for _, group := range groups {
+		scopes = append(scopes, v.groupScopes[group]...)
+	}
+
+	return &apiClient{name: claims.Subject, scopes: scopes}, nil
+}
+
+// groups returns the groups in the groups claim of the token, which is either a single string or an array
+func (v *jwtVerifier) groups(segment string) ([]string, error) {
+	var claims map[string]json.RawMessage
+	if err := decodeSegment(segment, &claims); err != nil {
+		return nil, fmt.Errorf("invalid token claims: %v", err)
+	}
+	claim, ok := claims[v.groupsClaim]
+	if !ok {
+		return nil, nil
+	}
+	var groups audience
+	if err := json.Unmarshal(claim, &groups); err != nil {
+		return nil, fmt.Errorf("invalid claim '%s': %v", v.groupsClaim, err)
+	}
+	return groups, nil
+}
+
+// key returns the public key with the id, refreshing the keys of a JWKS url at most once a minute
+func (v *jwtVerifier) key(kid string) (crypto.PublicKey, error) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	key, ok := v.keys[kid]
+	if !ok && v.jwksURL != "" && time.Since(v.fetched) > time.Minute {
+		v.fetched = time.Now()
+		keys, err := v.fetchKeys()
+		if err != nil {
+			logger.Error("Unable to fetch the jwks", zap.String("url", v.jwksURL), zap.Error(err))
+			return nil, err
+		}
+		v.keys = keys
+		key, ok = v.keys[kid]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key '%s'", kid)
+	}
+	return key, nil
+}
+
+// fetchKeys downloads the key set from the JWKS url
+func (v *jwtVerifier) fetchKeys() (map[string]crypto.PublicKey, error) {
+	resp, err := v.client.Get(v.jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+// jwk is a public key of a JSON web key set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS parses the RSA and EC signing keys of a JSON web key set by their ids
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %v", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid key '%s': %v", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// publicKey decodes the public key
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		if _, err := key.ECDH(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type '%s'", k.Kty)
+}
+
+// verifySignature verifies the signature of the signed part of a token with the key.
+// Only the asymmetric algorithms of jwtHashes are accepted, in particular not "none".
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm '%s'", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") {
+			return rsa.VerifyPKCS1v15(k, hash, digest, signature)
+		}
+		if strings.HasPrefix(alg, "PS") {
+			return rsa.VerifyPSS(k, hash, digest, signature, nil)
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			break
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("invalid token signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("algorithm '%s' does not match the key", alg)
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// decodeInt decodes a base64url encoded big-endian integer of a key
+func decodeInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("missing key parameter")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// numericDate converts a numeric date claim to a time
+func numericDate(seconds float64) time.Time {
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
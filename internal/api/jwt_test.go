@@ -0,0 +1,231 @@
+package api
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"dns-api-go/internal/common"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// signJWT signs the claims with the key as a token with the algorithm and key id
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+
+	hash, ok := jwtHashes[alg]
+	if !ok {
+		hash = crypto.SHA256
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest); err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// writeJWKS writes the public keys of the signers to a JWKS file
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	encode := func(i *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(i.Bytes())
+	}
+	jwks := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "", "e": ""},
+	}}
+	data, err := json.Marshal(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier, err := newJWTVerifier(&common.OIDC{
+		Issuer:   "https://idp.example.edu",
+		Audience: "dns-api",
+		JWKSFile: writeJWKS(t, rsaKey, ecKey),
+		GroupScopes: map[string][]string{
+			"dns-admins":  {"*"},
+			"dns-readers": {scopeRecordsRead, scopeZonesRead},
+		},
+	})
+	common.CheckError(t, "newJWTVerifier", nil, err)
+
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    "https://idp.example.edu",
+			"aud":    []string{"dns-api", "portal"},
+			"sub":    "jdoe",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"groups": []string{"dns-readers", "staff"},
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedScopes []string
+		valid          bool
+	}{
+		{"RSA token", signJWT(t, "RS256", "rsa", rsaKey, claims(nil)), []string{scopeRecordsRead, scopeZonesRead}, true},
+		{"EC token", signJWT(t, "ES256", "ec", ecKey, claims(map[string]interface{}{"aud": "dns-api", "groups": "dns-admins"})), []string{"*"}, true},
+		{"Expired within leeway", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"exp": time.Now().Add(-30 * time.Second).Unix()})), []string{scopeRecordsRead, scopeZonesRead}, true},
+		{"Without groups", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"groups": nil})), nil, true},
+		{"Expired", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), nil, false},
+		{"Without expiry", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"exp": nil})), nil, false},
+		{"Not valid yet", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})), nil, false},
+		{"Other issuer", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"iss": "https://evil.example.com"})), nil, false},
+		{"Other audience", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"aud": "portal"})), nil, false},
+		{"Without subject", signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"sub": nil})), nil, false},
+		{"Other signer", signJWT(t, "RS256", "rsa", otherKey, claims(nil)), nil, false},
+		{"Unknown key", signJWT(t, "RS256", "other", otherKey, claims(nil)), nil, false},
+		{"Encryption key", signJWT(t, "RS256", "enc", rsaKey, claims(nil)), nil, false},
+		{"Mismatched algorithm", signJWT(t, "ES256", "rsa", ecKey, claims(nil)), nil, false},
+		{"Unsigned", signJWT(t, "none", "rsa", rsaKey, claims(nil)), nil, false},
+		{"Malformed", "not.a-token", nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, err := verifier.verify(tc.token)
+			if !tc.valid {
+				if err == nil {
+					t.Fatalf("expected an error for the token, got client %+v", client)
+				}
+				return
+			}
+			common.CheckError(t, "verify", nil, err)
+			common.CheckResponse(t, "Subject", "jdoe", client.name)
+			common.CheckResponse(t, "Scopes", tc.expectedScopes, client.scopes)
+		})
+	}
+
+	// Bearer tokens authenticate requests next to the keys of the clients
+	store, err := newCredentialStore("", nil)
+	common.CheckError(t, "newCredentialStore", nil, err)
+	store.bearer = verifier
+	var subject string
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		subject = requestSubject(r)
+		w.WriteHeader(http.StatusOK)
+	}
+	handler := AuthMiddleware(store, map[string]string{}, requireScope(scopeRecordsRead, okHandler))
+
+	for token, expectedStatus := range map[string]int{
+		signJWT(t, "RS256", "rsa", rsaKey, claims(nil)):                                                 http.StatusOK,
+		signJWT(t, "RS256", "rsa", rsaKey, claims(map[string]interface{}{"groups": []string{"staff"}})): http.StatusForbidden,
+		signJWT(t, "RS256", "rsa", otherKey, claims(nil)):                                               http.StatusForbidden,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/records", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		common.CheckResponse(t, "Bearer status", expectedStatus, rr.Code)
+	}
+	common.CheckResponse(t, "Subject", "jdoe", subject)
+
+	for _, c := range []*common.OIDC{
+		{Issuer: "https://idp.example.edu", JWKSURL: "https://idp.example.edu/jwks"},
+		{Issuer: "https://idp.example.edu", Audience: "dns-api"},
+		{Issuer: "https://idp.example.edu", Audience: "dns-api", JWKSURL: "https://idp.example.edu/jwks", GroupScopes: map[string][]string{"staff": {"dns:admin"}}},
+	} {
+		if _, err := newJWTVerifier(c); err == nil {
+			t.Errorf("expected an error for oidc configuration %+v", *c)
+		}
+	}
+}
+
+func TestJWKSURL(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		encode := func(i *big.Int) string {
+			return base64.RawURLEncoding.EncodeToString(i.FillBytes(make([]byte, 48)))
+		}
+		fmt.Fprintf(w, `{"keys": [{"kty": "EC", "kid": "ec", "crv": "P-384", "x": "%s", "y": "%s"}]}`, encode(key.X), encode(key.Y))
+	}))
+	defer ts.Close()
+
+	verifier, err := newJWTVerifier(&common.OIDC{Issuer: "idp", Audience: "dns-api", JWKSURL: ts.URL, GroupsClaim: "roles"})
+	common.CheckError(t, "newJWTVerifier", nil, err)
+
+	claims := map[string]interface{}{"iss": "idp", "aud": "dns-api", "sub": "svc", "exp": time.Now().Add(time.Minute).Unix()}
+	for i := 0; i < 2; i++ {
+		_, err = verifier.verify(signJWT(t, "ES384", "ec", key, claims))
+		common.CheckError(t, "verify", nil, err)
+	}
+
+	// Unknown keys refresh the key set at most once a minute
+	for i := 0; i < 2; i++ {
+		_, err = verifier.verify(signJWT(t, "ES384", "rotated", key, claims))
+		if err == nil {
+			t.Error("expected an error for an unknown key")
+		}
+	}
+	common.CheckResponse(t, "Fetches", 1, fetches)
+}
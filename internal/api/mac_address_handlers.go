@@ -4,6 +4,7 @@ import (
 	"dns-api-go/internal/common"
 	"dns-api-go/internal/models"
 	"dns-api-go/internal/services"
+	"dns-api-go/internal/types"
 	"dns-api-go/logger"
 	"encoding/json"
 	"fmt"
@@ -97,7 +98,7 @@ func (s *server) GetMacAddressHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Attempt to get the mac address entity and handle potential errors
-	entity, err := s.services.MacAddressService.GetMacAddress(params.Address)
+	entity, err := s.servicesFor(r).MacAddressService.GetMacAddress(params.Address)
 	if err != nil {
 		logger.Error("Error getting mac address entity", zap.String("macAddress", params.Address), zap.Error(err))
 		// Determine the type of error and set the HTTP response accordingly
@@ -135,21 +136,28 @@ func (s *server) CreateMacAddressHandler(w http.ResponseWriter, r *http.Request)
 		Properties: propertiesMap,
 	}
 
+	event := auditEvent(r)
+	event.EntityType = types.MACADDRESS
+	event.Name = mac.Address
+
 	// Attempt to create the mac address to bluecat and handle potential errors
-	objectId, err := s.services.MacAddressService.CreateMacAddress(*mac)
+	objectId, err := s.servicesFor(r).MacAddressService.CreateMacAddress(*mac)
 	if err != nil {
 		logger.Error("Failed to create mac address", zap.Error(err))
-		switch e := err.(type) {
+		switch services.TransactionCause(err).(type) {
 		case *services.ErrEntityAlreadyExists:
-			http.Error(w, e.Error(), http.StatusConflict)
+			http.Error(w, err.Error(), http.StatusConflict)
 		case *services.PoolIDError:
-			http.Error(w, e.Error(), http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
 
+	event.EntityID = objectId
+	event.After = propertiesMap
+
 	// Send the response back to client with objectId of newly created Mac object
 	s.respond(w, objectId, http.StatusOK)
 }
@@ -164,6 +172,14 @@ func (s *server) UpdateMacAddressHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Check that the mac address has not changed since the client has seen it
+	current := func() (*models.Entity, error) {
+		return s.servicesFor(r).MacAddressService.GetMacAddress(params.Address)
+	}
+	if !s.checkIfMatch(w, r, 0, current) {
+		return
+	}
+
 	// Convert properties into a map
 	propertiesMap := common.ConvertToMap(params.Properties, "|")
 
@@ -174,8 +190,17 @@ func (s *server) UpdateMacAddressHandler(w http.ResponseWriter, r *http.Request)
 		Properties: propertiesMap,
 	}
 
+	// Keep the mac address before the update for the audit log
+	event := auditEvent(r)
+	event.EntityType = types.MACADDRESS
+	event.Name = mac.Address
+	event.After = propertiesMap
+	if before, err := current(); err == nil {
+		describeEntity(event, before, true)
+	}
+
 	// Update the mac object with the new properties
-	err = s.services.MacAddressService.UpdateMacAddress(*mac)
+	err = s.servicesFor(r).MacAddressService.UpdateMacAddress(*mac)
 	if err != nil {
 		logger.Error("Failed to update mac address", zap.Error(err))
 		// Determine the type of error and set the HTTP response accordingly
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// bluecatBuckets are the buckets of the bluecat request durations, bluecat can take up to the client timeout
+var bluecatBuckets = []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120}
+
+var (
+	bluecatRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dns_api",
+		Subsystem: "bluecat",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the requests to bluecat by route, method and status.",
+		Buckets:   bluecatBuckets,
+	}, []string{"route", "method", "status"})
+
+	bluecatRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dns_api",
+		Subsystem: "bluecat",
+		Name:      "requests_total",
+		Help:      "Requests to bluecat by route, method and status.",
+	}, []string{"route", "method", "status"})
+
+	bluecatTokenRefreshes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dns_api",
+		Subsystem: "bluecat",
+		Name:      "token_refreshes_total",
+		Help:      "Logins to bluecat that created a new session token.",
+	})
+
+	bluecatLoginFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "dns_api",
+		Subsystem: "bluecat",
+		Name:      "login_failures_total",
+		Help:      "Logins to bluecat that failed.",
+	})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "dns_api",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of the API requests by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dns_api",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "API requests by route, method and status.",
+	}, []string{"route", "method", "status"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		bluecatRequestDuration,
+		bluecatRequests,
+		bluecatTokenRefreshes,
+		bluecatLoginFailures,
+		httpRequestDuration,
+		httpRequests,
+	)
+}
+
+// bluecatRouteLabel returns the route of a bluecat request as a metric label. The ids in the paths of the v2 API
+// are replaced, so that the label does not grow with the number of entities.
+func bluecatRouteLabel(route string) string {
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// observeBluecatRequest records the duration and the status of a request to bluecat. Requests that failed without
+// a response have the status "error".
+func observeBluecatRequest(method, route string, status int, start time.Time) {
+	statusLabel := "error"
+	if status > 0 {
+		statusLabel = strconv.Itoa(status)
+	}
+	labels := prometheus.Labels{"route": bluecatRouteLabel(route), "method": strings.ToUpper(method), "status": statusLabel}
+	bluecatRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	bluecatRequests.With(labels).Inc()
+}
+
+// MetricsMiddleware records the duration and the status of the requests of each route of the router.
+// Routes are labeled by their path template, e.g. /v2/dns/{account}/records/{id}.
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(recorder.status)}
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+		httpRequests.With(labels).Inc()
+	})
+}
+
+// statusRecorder records the status of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"dns-api-go/internal/common"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBluecatRouteLabel(t *testing.T) {
+	tests := map[string]string{
+		"/getEntityById":                    "/getEntityById",
+		"/api/v2/zones/123/resourceRecords": "/api/v2/zones/{id}/resourceRecords",
+		"/api/v2/resourceRecords/45":        "/api/v2/resourceRecords/{id}",
+	}
+	for route, expected := range tests {
+		common.CheckResponse(t, route, expected, bluecatRouteLabel(route))
+	}
+}
+
+func TestSimulatedMetrics(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "metrics.example.com", "target": "10.0.0.70"}`, nil)
+	common.CheckResponse(t, "Create host record", http.StatusCreated, status)
+	serve(t, s, http.MethodGet, "/v2/dns/test/records/999999", "", nil)
+
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v2/dns/metrics", nil))
+	body, _ := io.ReadAll(rr.Body)
+	metrics := string(body)
+
+	for _, expected := range []string{
+		`dns_api_bluecat_requests_total{method="POST",route="/addHostRecord",status="200"}`,
+		`dns_api_bluecat_request_duration_seconds_bucket{method="GET",route="/getEntityById",status="200",le="0.01"}`,
+		`dns_api_bluecat_token_refreshes_total`,
+		`dns_api_http_requests_total{method="POST",route="/v2/dns/{account}/records",status="201"}`,
+		`dns_api_http_requests_total{method="GET",route="/v2/dns/{account}/records/{id}",status="404"}`,
+		`dns_api_http_request_duration_seconds_count{method="POST",route="/v2/dns/{account}/records",status="201"}`,
+	} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("expected the metrics to contain %s", expected)
+		}
+	}
+}
@@ -19,15 +19,25 @@ package api
 import (
 	"dns-api-go/logger"
 	"github.com/gorilla/mux"
+	"github.com/patrickmn/go-cache"
 	"go.uber.org/zap"
 	"net/http"
 	"net/url"
-
-	"golang.org/x/crypto/bcrypt"
+	"time"
 )
 
-// TokenMiddleware checks the tokens for non-public URLs
+// TokenMiddleware checks the tokens for non-public URLs against a single pre-shared key that grants every scope
 func TokenMiddleware(psk []byte, public map[string]string, h http.Handler) http.Handler {
+	store := &credentialStore{
+		clients:  []*apiClient{{name: "token", key: psk, scopes: []string{"*"}}},
+		verified: cache.New(10*time.Minute, 10*time.Minute),
+	}
+	return AuthMiddleware(store, public, h)
+}
+
+// AuthMiddleware authenticates the clients of non-public URLs by their X-Auth-Token or their bearer token and
+// passes the client on to the handlers in the request context, where the routes check its scopes
+func AuthMiddleware(store *credentialStore, public map[string]string, h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		logger.Debug("Processing token middleware for protected URLs",
 			zap.String("method", r.Method),
@@ -37,7 +47,7 @@ func TokenMiddleware(psk []byte, public map[string]string, h http.Handler) http.
 		if r.Method == "OPTIONS" {
 			logger.Info("Setting CORS preflight options and returning")
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Headers", "X-Auth-Token")
+			w.Header().Set("Access-Control-Allow-Headers", "X-Auth-Token, Authorization")
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte{})
 			return
@@ -55,8 +65,8 @@ func TokenMiddleware(psk []byte, public map[string]string, h http.Handler) http.
 		} else {
 			logger.Debug("Authenticating token for protected URL", zap.String("URL", r.URL.String()))
 
-			htoken := r.Header.Get("X-Auth-Token")
-			if err := bcrypt.CompareHashAndPassword([]byte(htoken), psk); err != nil {
+			client, err := store.authenticateRequest(r)
+			if err != nil {
 				logger.Warn("Unable to authenticate session for URL",
 					zap.String("URL", r.URL.String()),
 					zap.Error(err))
@@ -64,7 +74,10 @@ func TokenMiddleware(psk []byte, public map[string]string, h http.Handler) http.
 				return
 			}
 
-			logger.Info("Successfully authenticated token for URL", zap.String("URL", r.URL.String()))
+			logger.Info("Successfully authenticated token for URL",
+				zap.String("URL", r.URL.String()),
+				zap.String("client", client.name))
+			r = r.WithContext(withClient(r.Context(), client))
 		}
 
 		h.ServeHTTP(w, r)
@@ -81,10 +94,10 @@ func (s *server) AccountValidationMiddleware(next http.Handler) http.Handler {
 		account := vars["account"]
 
 		// Check if the provided account matches the expected account
-		if s.bluecat.account != account {
+		if s.account != account {
 			logger.Warn("Invalid account attempt",
 				zap.String("providedAccount", account),
-				zap.String("expectedAccount", s.bluecat.account))
+				zap.String("expectedAccount", s.account))
 			http.Error(w, "Invalid account", http.StatusBadRequest)
 			return
 		}
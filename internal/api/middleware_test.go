@@ -17,6 +17,7 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package api
 
 import (
+	"dns-api-go/internal/common"
 	"fmt"
 	"github.com/gorilla/mux"
 	"net/http"
@@ -99,7 +100,7 @@ func TestTokenMiddleware(t *testing.T) {
 
 	testHeaders := map[string]string{
 		"Access-Control-Allow-Origin":  "*",
-		"Access-Control-Allow-Headers": "X-Auth-Token",
+		"Access-Control-Allow-Headers": "X-Auth-Token, Authorization",
 	}
 
 	for k, v := range testHeaders {
@@ -112,9 +113,7 @@ func TestTokenMiddleware(t *testing.T) {
 func TestAccountValidationMiddleware(t *testing.T) {
 	// Mock server setup
 	mockServer := server{
-		bluecat: &bluecat{
-			account: "validAccount",
-		},
+		account: "validAccount",
 	}
 
 	// Mock next handler
@@ -167,3 +166,62 @@ func TestAccountValidationMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestScopedClients(t *testing.T) {
+	store, err := newCredentialStore("", []common.Client{
+		{Name: "reader", Key: "readerkey", Scopes: []string{scopeRecordsRead}},
+		{Name: "writer", Key: "writerkey", Scopes: []string{"records:*"}},
+	})
+	common.CheckError(t, "newCredentialStore", nil, err)
+
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/records", requireScope(scopeRecordsRead, okHandler)).Methods(http.MethodGet)
+	router.HandleFunc("/records", requireScope(scopeRecordsWrite, okHandler)).Methods(http.MethodPost)
+	router.HandleFunc("/ips", requireScope(scopeIpsAssign, okHandler)).Methods(http.MethodPost)
+	handler := AuthMiddleware(store, map[string]string{}, router)
+
+	token := func(key string) string {
+		hash, _ := bcrypt.GenerateFromPassword([]byte(key), bcrypt.MinCost)
+		return string(hash)
+	}
+	readerToken, writerToken := token("readerkey"), token("writerkey")
+
+	tests := []struct {
+		name           string
+		token          string
+		method         string
+		path           string
+		expectedStatus int
+	}{
+		{"Reader reads records", readerToken, http.MethodGet, "/records", http.StatusOK},
+		{"Reader cannot write records", readerToken, http.MethodPost, "/records", http.StatusForbidden},
+		{"Writer writes records", writerToken, http.MethodPost, "/records", http.StatusOK},
+		{"Writer reads records again", writerToken, http.MethodGet, "/records", http.StatusOK},
+		{"Writer cannot assign ips", writerToken, http.MethodPost, "/ips", http.StatusForbidden},
+		{"Unknown key", token("otherkey"), http.MethodGet, "/records", http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("X-Auth-Token", tc.token)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			common.CheckResponse(t, "Status", tc.expectedStatus, rr.Code)
+		})
+	}
+
+	invalid := [][]common.Client{
+		{{Name: "reader", Key: "readerkey", Scopes: []string{"records:admin"}}},
+		{{Name: "reader", Key: "readerkey"}, {Name: "reader", Key: "otherkey"}},
+		{{Name: "nokey"}},
+	}
+	for _, clients := range invalid {
+		if _, err := newCredentialStore("", clients); err == nil {
+			t.Errorf("expected an error for clients %+v", clients)
+		}
+	}
+}
@@ -1,13 +1,14 @@
 package api
 
 import (
+	"dns-api-go/internal/interfaces"
 	"net/http"
 )
 
 func (s *server) GetNetworksHandler() http.HandlerFunc {
-	return s.HandleGetEntitiesByHintReq(s.services.NetworkService)
+	return s.HandleGetEntitiesByHintReq(func(svc Services) interfaces.EntitiesByHintLister { return svc.NetworkService })
 }
 
 func (s *server) GetNetworkHandler() http.HandlerFunc {
-	return s.HandleGetEntityReq(s.services.NetworkService)
+	return s.HandleGetEntityReq(func(svc Services) interfaces.EntityGetter { return svc.NetworkService })
 }
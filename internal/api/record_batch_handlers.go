@@ -0,0 +1,350 @@
+package api
+
+import (
+	"bytes"
+	"dns-api-go/internal/audit"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/services"
+	"dns-api-go/internal/zonefile"
+	"dns-api-go/logger"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// maxBatchOperations is the largest number of operations in a batch
+	maxBatchOperations = 500
+	// maxBatchConcurrency is the largest number of operations of a batch that run at the same time
+	maxBatchConcurrency = 16
+)
+
+// What a batch does when an operation fails
+const (
+	batchContinue = "continue"
+	batchStop     = "stop"
+	batchRollback = "rollback"
+)
+
+// BatchOperation is a create, update or delete of a record. Record holds the body of the single record
+// request, i.e. the CreateRecordParams of a create and the UpdateRecordParams of an update.
+type BatchOperation struct {
+	Op     string          `json:"op"`
+	ID     int             `json:"id"`
+	Record json.RawMessage `json:"record"`
+}
+
+// BatchRecordsParams holds the operations of a batch, what to do when one of them fails and how many of them run
+// at the same time
+type BatchRecordsParams struct {
+	Operations  []BatchOperation `json:"operations"`
+	OnFailure   string           `json:"onFailure"`
+	Concurrency int              `json:"concurrency"`
+}
+
+// batchResult is the outcome of an operation of a batch
+type batchResult struct {
+	Index      int             `json:"index"`
+	Op         string          `json:"op"`
+	ID         int             `json:"id,omitempty"`
+	Status     int             `json:"status,omitempty"`
+	Entity     json.RawMessage `json:"entity,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Skipped    bool            `json:"skipped,omitempty"`
+	RolledBack bool            `json:"rolledBack,omitempty"`
+}
+
+// batchResponse holds the results of the operations in the order of the request
+type batchResponse struct {
+	Results       []*batchResult `json:"results"`
+	RolledBack    bool           `json:"rolledBack,omitempty"`
+	RollbackError string         `json:"rollbackError,omitempty"`
+}
+
+// parseBatchRecordsParams parses and validates the operations of a batch
+func parseBatchRecordsParams(r *http.Request) (*BatchRecordsParams, error) {
+	var params BatchRecordsParams
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		return nil, fmt.Errorf("failed to decode request body: %v", err)
+	}
+
+	if len(params.Operations) == 0 {
+		return nil, fmt.Errorf("missing required parameter: operations")
+	}
+	if len(params.Operations) > maxBatchOperations {
+		return nil, fmt.Errorf("a batch cannot have more than %d operations", maxBatchOperations)
+	}
+	for i, op := range params.Operations {
+		switch op.Op {
+		case audit.Create:
+			if len(op.Record) == 0 {
+				return nil, fmt.Errorf("operation %d: missing required parameter: record", i)
+			}
+		case audit.Update, audit.Delete:
+			if op.ID <= 0 {
+				return nil, fmt.Errorf("operation %d: missing required parameter: id", i)
+			}
+		default:
+			return nil, fmt.Errorf("operation %d: invalid op '%s', expected create, update or delete", i, op.Op)
+		}
+	}
+
+	switch params.OnFailure {
+	case "":
+		params.OnFailure = batchContinue
+	case batchContinue, batchStop, batchRollback:
+	default:
+		return nil, fmt.Errorf("invalid onFailure value, expected continue, stop or rollback")
+	}
+
+	if params.Concurrency == 0 {
+		params.Concurrency = 4
+	}
+	if params.Concurrency < 0 || params.Concurrency > maxBatchConcurrency {
+		return nil, fmt.Errorf("concurrency must be between 1 and %d", maxBatchConcurrency)
+	}
+
+	return &params, nil
+}
+
+// BatchRecordsHandler runs the create, update and delete operations of a batch of records with bounded
+// concurrency. Each operation is handled like the single record request, including the policy and the audit log,
+// and its result reports the status of that request. When an operation fails, the batch goes on, stops starting
+// new operations, or stops and rolls back the operations that succeeded, depending on onFailure.
+func (s *server) BatchRecordsHandler(w http.ResponseWriter, r *http.Request) {
+	logger.Info("BatchRecordsHandler started")
+
+	params, err := parseBatchRecordsParams(r)
+	if err != nil {
+		logger.Warn("Invalid request parameters", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	handlers := map[string]http.HandlerFunc{
+		audit.Create: s.audited(audit.Create, s.CreateRecordHandler),
+		audit.Update: s.audited(audit.Update, s.UpdateRecordHandler),
+		audit.Delete: s.audited(audit.Delete, s.DeleteRecordHandler()),
+	}
+	recordService := s.servicesFor(r).RecordService
+
+	var (
+		lock     sync.Mutex
+		failed   bool
+		firstErr error
+		done     []*batchResult
+		wg       sync.WaitGroup
+	)
+	j := jobFrom(r.Context())
+	j.setTotal(len(params.Operations))
+	tx := services.NewTransaction(fmt.Sprintf("batch of %d records", len(params.Operations)))
+	slots := make(chan struct{}, params.Concurrency)
+	response := &batchResponse{Results: make([]*batchResult, len(params.Operations))}
+
+	for i, op := range params.Operations {
+		result := &batchResult{Index: i, Op: op.Op, ID: op.ID}
+		response.Results[i] = result
+
+		// A cancelled request stops the batch like a failure
+		slots <- struct{}{}
+		lock.Lock()
+		stopped := failed && params.OnFailure != batchContinue
+		if err := r.Context().Err(); err != nil {
+			if !failed {
+				failed, firstErr = true, err
+			}
+			stopped = true
+		}
+		lock.Unlock()
+		if stopped {
+			<-slots
+			result.Skipped = true
+			continue
+		}
+
+		wg.Add(1)
+		go func(op BatchOperation) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			// Keep the record before it is changed to be able to restore it
+			var before *models.Entity
+			if params.OnFailure == batchRollback && op.Op != audit.Create {
+				before, _ = recordService.GetEntity(op.ID, true)
+			}
+
+			s.runBatchOperation(r, handlers[op.Op], op, result)
+
+			lock.Lock()
+			defer lock.Unlock()
+			j.itemDone(*result, result.Status >= http.StatusBadRequest)
+			if result.Status >= http.StatusBadRequest {
+				if !failed {
+					failed = true
+					firstErr = fmt.Errorf("operation %d failed with status %d: %s", result.Index, result.Status, result.Error)
+				}
+				return
+			}
+			done = append(done, result)
+			if params.OnFailure == batchRollback {
+				tx.OnRollback(s.batchRollbackTask(recordService, op.Op, result.ID, before))
+			}
+		}(op)
+	}
+	wg.Wait()
+
+	// Undo the operations that succeeded
+	if failed && params.OnFailure == batchRollback && len(done) > 0 {
+		err := tx.Fail(firstErr)
+		response.RolledBack = true
+		if e, ok := err.(*services.ErrTransactionFailed); ok && e.RollbackErr != nil {
+			response.RollbackError = e.RollbackErr.Error()
+		}
+		for _, result := range done {
+			result.RolledBack = response.RollbackError == ""
+		}
+	}
+
+	status := http.StatusOK
+	if failed {
+		status = http.StatusMultiStatus
+		logger.Warn("BatchRecordsHandler finished with failures", zap.Error(firstErr), zap.Bool("rolledBack", response.RolledBack))
+	} else {
+		logger.Info("BatchRecordsHandler successful", zap.Int("operations", len(params.Operations)))
+	}
+	s.respond(w, response, status)
+}
+
+// runBatchOperation runs an operation of a batch through the handler of the single record request, on behalf of the
+// client of the batch request, and records the response in the result
+func (s *server) runBatchOperation(r *http.Request, handler http.HandlerFunc, op BatchOperation, result *batchResult) {
+	method, path := http.MethodPost, strings.TrimSuffix(r.URL.Path, "/batch")
+	vars := map[string]string{"account": mux.Vars(r)["account"]}
+	switch op.Op {
+	case audit.Update:
+		method = http.MethodPut
+	case audit.Delete:
+		method = http.MethodDelete
+	}
+	if op.Op != audit.Create {
+		vars["id"] = strconv.Itoa(op.ID)
+		path += "/" + vars["id"]
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), method, path, bytes.NewReader(op.Record))
+	if err != nil {
+		result.Status = http.StatusInternalServerError
+		result.Error = err.Error()
+		return
+	}
+	req = mux.SetURLVars(req, vars)
+	recorder := newResponseBuffer()
+	handler(recorder, req)
+
+	result.Status = recorder.status
+	body := bytes.TrimSpace(recorder.body.Bytes())
+	if recorder.status >= http.StatusBadRequest {
+		result.Error = string(body)
+		return
+	}
+	if len(body) > 0 && json.Valid(body) {
+		result.Entity = body
+		var entity models.Entity
+		if err := json.Unmarshal(body, &entity); err == nil && entity.ID > 0 {
+			result.ID = entity.ID
+		}
+	}
+}
+
+// batchRollbackTask returns the task that undoes a successful operation of a batch. Created records are deleted,
+// and updated and deleted records are restored with all the properties of their records before the operation,
+// including comments and user-defined fields. Restored records that were deleted get a new id.
+func (s *server) batchRollbackTask(recordService services.RecordEntityService, op string, id int, before *models.Entity) services.RollbackFunc {
+	return services.RollbackTask(func() error {
+		if op == audit.Create {
+			return recordService.DeleteEntity(id)
+		}
+		if before == nil {
+			return fmt.Errorf("record %d cannot be restored, it was not found before the batch", id)
+		}
+
+		if op == audit.Update {
+			current, err := recordService.GetEntity(id, false)
+			if err != nil {
+				return err
+			}
+			parameters, err := restoreRecordParameters(before, current)
+			if err != nil {
+				return err
+			}
+			_, err = recordService.UpdateRecord(id, parameters)
+			return err
+		}
+
+		// The record data is passed as the parameters of its type, the other properties as properties
+		records, err := zonefile.FromEntity(*before)
+		if err != nil {
+			return err
+		}
+		change := zonefile.Change{Action: zonefile.ActionCreate, RecordType: before.Type, Name: records[0].Name, After: records}
+		parameters, err := change.Parameters()
+		if err != nil {
+			return err
+		}
+		properties := map[string]string{}
+		for key, value := range before.Properties {
+			if !common.Contains(services.RESERVEDRECORDPROPERTIES, key) {
+				properties[key] = value
+			}
+		}
+		parameters["properties"] = properties
+
+		viewId, err := s.viewId()
+		if err != nil {
+			return err
+		}
+		_, err = recordService.CreateRecord(before.Type, parameters, viewId)
+		return err
+	})
+}
+
+// restoreRecordParameters returns the parameters of the update that restores a record to its state before. The record
+// data is restored through the parameters of its type, the other properties as properties, and the properties that
+// were added since are cleared.
+func restoreRecordParameters(before, current *models.Entity) (map[string]interface{}, error) {
+	parameters := map[string]interface{}{}
+	properties := map[string]string{}
+	for key := range current.Properties {
+		if !common.Contains(services.RESERVEDRECORDPROPERTIES, key) {
+			properties[key] = ""
+		}
+	}
+	for key, value := range before.Properties {
+		switch key {
+		case "absoluteName", "type":
+			// The name and the type of a record never change with an update
+		case "addresses":
+			parameters[key] = strings.Split(value, ",")
+		case "ttl", "priority", "weight", "port":
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s '%s' of record %d", key, value, before.ID)
+			}
+			parameters[key] = number
+		case "linkedRecordName", "txt", "rdata", "cpu", "os":
+			parameters[key] = value
+		default:
+			properties[key] = value
+		}
+	}
+	parameters["properties"] = properties
+	return parameters, nil
+}
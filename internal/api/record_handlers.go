@@ -2,7 +2,11 @@ package api
 
 import (
 	"dns-api-go/internal/common"
+	"dns-api-go/internal/interfaces"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/policy"
 	"dns-api-go/internal/services"
+	"dns-api-go/internal/types"
 	"dns-api-go/logger"
 	"encoding/json"
 	"fmt"
@@ -22,19 +26,113 @@ type GetRecordsByTypeParams struct {
 }
 
 type CreateRecordParams struct {
-	RecordType string `json:"type"`
-	RecordName string `json:"record"`
-	Target     string `json:"target"`
-	Properties string `json:"properties"`
-	Ttl        int    `json:"ttl"`
+	RecordType  string `json:"type"`
+	RecordName  string `json:"record"`
+	Target      string `json:"target"`
+	Properties  string `json:"properties"`
+	Ttl         int    `json:"ttl"`
+	Priority    int    `json:"priority"`
+	Weight      int    `json:"weight"`
+	Port        int    `json:"port"`
+	Text        string `json:"text"`
+	GenericType string `json:"generic_type"`
+	Rdata       string `json:"rdata"`
+	CPU         string `json:"cpu"`
+	OS          string `json:"os"`
+}
+
+// UpdateRecordParams holds the optional fields of a record update. Fields left out of the request are unchanged.
+// The fields of the record data only apply to the record types that have them, e.g. port to SRV records.
+type UpdateRecordParams struct {
+	Target     *string `json:"target"`
+	Properties *string `json:"properties"`
+	Ttl        *int    `json:"ttl"`
+	Priority   *int    `json:"priority"`
+	Weight     *int    `json:"weight"`
+	Port       *int    `json:"port"`
+	Text       *string `json:"text"`
+	Rdata      *string `json:"rdata"`
+	CPU        *string `json:"cpu"`
+	OS         *string `json:"os"`
 }
 
 func (s *server) GetRecordHandler() http.HandlerFunc {
-	return s.HandleGetEntityReq(s.services.RecordService)
+	return s.HandleGetEntityReq(func(svc Services) interfaces.EntityGetter { return svc.RecordService })
 }
 
+// DeleteRecordHandler deletes a record if the policy allows the client to delete records of its name and type
 func (s *server) DeleteRecordHandler() http.HandlerFunc {
-	return s.HandleDeleteEntityReq(s.services.RecordService)
+	return s.authorizeRecordDelete(
+		func(svc Services) interfaces.EntityGetter { return svc.RecordService },
+		s.HandleDeleteEntityReq(func(svc Services) interfaces.EntityDeleter { return svc.RecordService }))
+}
+
+// authorizeRecordDelete evaluates the policy before the delete handler deletes an entity that is a record.
+// The entity is looked up with the getter, and entities that are missing are reported by the delete handler.
+func (s *server) authorizeRecordDelete(getter func(Services) interfaces.EntityGetter, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.policy == nil {
+			next(w, r)
+			return
+		}
+
+		params, err := parseEntityParams(r)
+		if err == nil {
+			entity, err := getter(s.servicesFor(r)).GetEntity(params.ID, true)
+			switch err.(type) {
+			case nil:
+				if common.Contains(services.SUPPORTEDRECORDS, entity.Type) &&
+					!s.authorize(w, r, recordPolicyRequest(policy.DeleteRecord, entity)) {
+					return
+				}
+			case *services.ErrEntityNotFound, *services.ErrEntityTypeMismatch:
+			default:
+				logger.Error("Error getting record for policy", zap.Int("id", params.ID), zap.Error(err))
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// updatedRecord returns the record as it will be after the update with the parameters
+func (p *UpdateRecordParams) updatedRecord(record *models.Entity) *models.Entity {
+	updated := &models.Entity{ID: record.ID, Name: record.Name, Type: record.Type, Properties: map[string]string{}}
+	for key, value := range record.Properties {
+		updated.Properties[key] = value
+	}
+	if p.Properties != nil {
+		for key, value := range common.ConvertToMap(*p.Properties, "|") {
+			updated.Properties[key] = value
+		}
+	}
+	if p.Target != nil {
+		switch record.Type {
+		case types.HOSTRECORD:
+			updated.Properties["addresses"] = *p.Target
+		case types.CNAMERECORD, types.MXRECORD, types.SRVRECORD:
+			updated.Properties["linkedRecordName"] = *p.Target
+		}
+	}
+	return updated
+}
+
+// recordPolicyRequest describes the change of a record for the policy, including the addresses of host records
+func recordPolicyRequest(action string, record *models.Entity) policy.Request {
+	req := policy.Request{
+		Action:     action,
+		Name:       record.Properties["absoluteName"],
+		RecordType: record.Type,
+	}
+	if req.Name == "" {
+		req.Name = record.Name
+	}
+	if addresses := record.Properties["addresses"]; addresses != "" && record.Type == types.HOSTRECORD {
+		req.Networks = strings.Split(addresses, ",")
+	}
+	return req
 }
 
 func parseGetRecordsByTypeParams(r *http.Request) (*GetRecordsByTypeParams, error) {
@@ -54,8 +152,8 @@ func parseGetRecordsByTypeParams(r *http.Request) (*GetRecordsByTypeParams, erro
 	if recordType == "" {
 		return nil, fmt.Errorf("missing required parameter: type")
 	}
-	// Make sure record type of either HostRecord, AliasRecord, or ExternalHostRecord
-	if !common.Contains([]string{"HostRecord", "AliasRecord", "ExternalHostRecord"}, recordType) {
+	// Make sure record type is one of the supported record types
+	if !common.Contains(services.SUPPORTEDRECORDS, recordType) {
 		return nil, fmt.Errorf("invalid record type")
 	}
 	Params.recordType = recordType
@@ -121,8 +219,8 @@ func parseCreateRecordParams(r *http.Request) (*CreateRecordParams, error) {
 	if Params.RecordType == "" {
 		return nil, fmt.Errorf("missing required parameter: type")
 	}
-	// Make sure record type of either HostRecord, AliasRecord, or ExternalHostRecord
-	if common.Contains([]string{"HostRecord", "AliasRecord", "ExternalHostRecord"}, Params.RecordType) == false {
+	// Make sure record type is one of the supported record types
+	if common.Contains(services.SUPPORTEDRECORDS, Params.RecordType) == false {
 		return nil, fmt.Errorf("invalid record type")
 	}
 
@@ -131,6 +229,11 @@ func parseCreateRecordParams(r *http.Request) (*CreateRecordParams, error) {
 		return nil, fmt.Errorf("missing required parameter: record")
 	}
 
+	// Validate the parameters specific to the record type
+	if err := validateRecordTypeParams(&Params); err != nil {
+		return nil, err
+	}
+
 	// If ttl is not specified, set it to 300
 	if Params.Ttl == 0 {
 		Params.Ttl = 300
@@ -139,6 +242,92 @@ func parseCreateRecordParams(r *http.Request) (*CreateRecordParams, error) {
 	return &Params, nil
 }
 
+// parseUpdateRecordParams parses and validates the parameters from the request.
+func parseUpdateRecordParams(r *http.Request) (int, *UpdateRecordParams, error) {
+	var Params UpdateRecordParams
+
+	// Extract the record id from the request URL
+	entityParams, err := parseEntityParams(r)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Extract the parameters from the request body
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&Params); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode request body: %v", err)
+	}
+
+	// Make sure there is something to update
+	if Params.Target == nil && Params.Properties == nil && Params.Ttl == nil && len(Params.dataFields()) == 0 {
+		return 0, nil, fmt.Errorf("at least one of target, properties, ttl or a field of the record data is required")
+	}
+
+	return entityParams.ID, &Params, nil
+}
+
+// dataFields returns the fields of the record data in the update by their names in the record service
+func (p *UpdateRecordParams) dataFields() map[string]interface{} {
+	fields := map[string]interface{}{}
+	for key, value := range map[string]*int{"priority": p.Priority, "weight": p.Weight, "port": p.Port} {
+		if value != nil {
+			fields[key] = *value
+		}
+	}
+	for key, value := range map[string]*string{"txt": p.Text, "rdata": p.Rdata, "cpu": p.CPU, "os": p.OS} {
+		if value != nil {
+			fields[key] = *value
+		}
+	}
+	return fields
+}
+
+// validateRecordTypeParams validates the fields that only apply to some record types
+func validateRecordTypeParams(params *CreateRecordParams) error {
+	switch params.RecordType {
+	case types.MXRECORD:
+		if params.Target == "" {
+			return fmt.Errorf("missing required parameter: target")
+		}
+		if params.Priority < 0 || params.Priority > 65535 {
+			return fmt.Errorf("priority must be between 0 and 65535")
+		}
+	case types.TXTRECORD:
+		if params.Text == "" {
+			return fmt.Errorf("missing required parameter: text")
+		}
+	case types.SRVRECORD:
+		if params.Target == "" {
+			return fmt.Errorf("missing required parameter: target")
+		}
+		if params.Port <= 0 || params.Port > 65535 {
+			return fmt.Errorf("port must be between 1 and 65535")
+		}
+		if params.Priority < 0 || params.Priority > 65535 {
+			return fmt.Errorf("priority must be between 0 and 65535")
+		}
+		if params.Weight < 0 || params.Weight > 65535 {
+			return fmt.Errorf("weight must be between 0 and 65535")
+		}
+	case types.GENERICRECORD:
+		if params.GenericType == "" {
+			return fmt.Errorf("missing required parameter: generic_type")
+		}
+		if params.Rdata == "" {
+			return fmt.Errorf("missing required parameter: rdata")
+		}
+	case types.HINFORECORD:
+		if params.CPU == "" {
+			return fmt.Errorf("missing required parameter: cpu")
+		}
+		if params.OS == "" {
+			return fmt.Errorf("missing required parameter: os")
+		}
+	}
+
+	return nil
+}
+
 func (s *server) GetRecordsHandler(w http.ResponseWriter, r *http.Request) {
 	logger.Info("GetRecordsHandler started")
 
@@ -160,15 +349,14 @@ func (s *server) GetRecordsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get the view id
-	viewIdStr := s.bluecat.viewId
-	viewId, err := strconv.Atoi(viewIdStr)
+	viewId, err := s.viewId()
 	if err != nil {
 		logger.Error("Error converting viewId to int", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	entities, err := s.services.RecordService.GetRecordsByType(params.recordType, paramMap, viewId)
+	entities, err := s.servicesFor(r).RecordService.GetRecordsByType(params.recordType, paramMap, viewId)
 	if err != nil {
 		logger.Error("Error getting records", zap.Error(err))
 		// Determine the type of error and set the HTTP response accordingly
@@ -176,6 +364,9 @@ func (s *server) GetRecordsHandler(w http.ResponseWriter, r *http.Request) {
 		case *services.ErrEntityNotFound:
 			http.Error(w, e.Error(), http.StatusNotFound)
 			return
+		case *services.ErrRecordTypeNotSupported:
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
 		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -200,9 +391,21 @@ func (s *server) CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
 	addresses := strings.Split(params.Target, ",")
 	propertiesMap := common.ConvertToMap(params.Properties, "|")
 
+	event := auditEvent(r)
+	event.EntityType = params.RecordType
+	event.Name = params.RecordName
+
+	// Check that the client may create the record
+	req := policy.Request{Action: policy.CreateRecord, Name: params.RecordName, RecordType: params.RecordType}
+	if params.RecordType == types.HOSTRECORD {
+		req.Networks = addresses
+	}
+	if !s.authorize(w, r, req) {
+		return
+	}
+
 	// Get the view id
-	viewIdStr := s.bluecat.viewId
-	viewId, err := strconv.Atoi(viewIdStr)
+	viewId, err := s.viewId()
 	if err != nil {
 		logger.Error("Error converting viewId to int", zap.Error(err))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -217,9 +420,17 @@ func (s *server) CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
 		"addresses":        addresses,
 		"properties":       propertiesMap,
 		"ttl":              params.Ttl,
+		"priority":         params.Priority,
+		"weight":           params.Weight,
+		"port":             params.Port,
+		"txt":              params.Text,
+		"type":             params.GenericType,
+		"rdata":            params.Rdata,
+		"cpu":              params.CPU,
+		"os":               params.OS,
 	}
 
-	entity, err := s.services.RecordService.CreateRecord(params.RecordType, paramMap, viewId)
+	entity, err := s.servicesFor(r).RecordService.CreateRecord(params.RecordType, paramMap, viewId)
 	if err != nil {
 		logger.Error("Error creating record", zap.Error(err))
 		// Determine the type of error and set the HTTP response accordingly
@@ -227,12 +438,106 @@ func (s *server) CreateRecordHandler(w http.ResponseWriter, r *http.Request) {
 		case *services.ErrEntityAlreadyExists:
 			http.Error(w, e.Error(), http.StatusConflict)
 			return
+		case *services.ErrRecordTypeNotSupported:
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		case *services.ErrNoMatchingZone:
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
 		default:
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 	}
 
+	describeEntity(event, entity, false)
 	logger.Info("CreateRecordHandler successful")
 	s.respond(w, entity, http.StatusCreated)
 }
+
+func (s *server) UpdateRecordHandler(w http.ResponseWriter, r *http.Request) {
+	logger.Info("UpdateRecordHandler started")
+
+	// Parse parameters from the request
+	recordId, params, err := parseUpdateRecordParams(r)
+	if err != nil {
+		logger.Warn("Invalid request parameters", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Check that the record has not changed since the client has seen it
+	current := func() (*models.Entity, error) { return s.servicesFor(r).RecordService.GetEntity(recordId, true) }
+	if !s.checkIfMatch(w, r, recordId, current) {
+		return
+	}
+
+	// Keep the record before the update for the audit log
+	event := auditEvent(r)
+	event.EntityID = recordId
+	before, err := current()
+	if err == nil {
+		describeEntity(event, before, true)
+	}
+
+	// Check that the client may update the record, as it is and as it will be after the update, with their names
+	// and addresses. Missing records are reported by the update.
+	if s.policy != nil {
+		switch err.(type) {
+		case nil:
+			for _, record := range []*models.Entity{before, params.updatedRecord(before)} {
+				if !s.authorize(w, r, recordPolicyRequest(policy.UpdateRecord, record)) {
+					return
+				}
+			}
+		case *services.ErrEntityNotFound, *services.ErrEntityTypeMismatch:
+		default:
+			logger.Error("Error getting record for policy", zap.Int("id", recordId), zap.Error(err))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Create a map of only the parameters that were passed to the handler
+	paramMap := map[string]interface{}{}
+	if params.Target != nil {
+		paramMap["addresses"] = strings.Split(*params.Target, ",")
+		paramMap["linkedRecordName"] = *params.Target
+	}
+	if params.Properties != nil {
+		paramMap["properties"] = common.ConvertToMap(*params.Properties, "|")
+	}
+	if params.Ttl != nil {
+		paramMap["ttl"] = *params.Ttl
+	}
+	for key, value := range params.dataFields() {
+		paramMap[key] = value
+	}
+
+	entity, err := s.servicesFor(r).RecordService.UpdateRecord(recordId, paramMap)
+	if err != nil {
+		logger.Error("Error updating record", zap.Int("recordId", recordId), zap.Error(err))
+		// Determine the type of error and set the HTTP response accordingly
+		switch e := err.(type) {
+		case *services.ErrEntityNotFound:
+			http.Error(w, e.Error(), http.StatusNotFound)
+			return
+		case *services.ErrEntityTypeMismatch:
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		case *services.ErrInvalidRecordUpdate:
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		case *services.ErrRecordTypeNotSupported:
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	describeEntity(event, entity, false)
+	logger.Info("UpdateRecordHandler successful")
+	s.respond(w, entity, http.StatusOK)
+}
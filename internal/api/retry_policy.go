@@ -0,0 +1,99 @@
+package api
+
+import (
+	"crypto/tls"
+	bam "dns-api-go/internal/bluecat"
+	"dns-api-go/internal/common"
+	"fmt"
+	"github.com/pkg/errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultSafeRoutes are the mutating bluecat routes that leave the same result when they are repeated
+var defaultSafeRoutes = []string{"/update", "/associateMACAddressWithPool"}
+
+// retryPolicy decides which failed requests to bluecat are sent again
+type retryPolicy struct {
+	attempts   int
+	doubling   int
+	sleep      time.Duration
+	safeRoutes map[string]bool
+}
+
+// newRetryPolicy returns the retry policy for the configuration, the defaults apply to the unset values
+func newRetryPolicy(c *common.Retry) (*retryPolicy, error) {
+	if c == nil {
+		c = &common.Retry{}
+	}
+
+	p := &retryPolicy{
+		attempts:   3,
+		doubling:   2,
+		sleep:      500 * time.Millisecond,
+		safeRoutes: map[string]bool{},
+	}
+	if c.Attempts < 0 || c.Doubling < 0 {
+		return nil, fmt.Errorf("retry attempts and doubling cannot be negative")
+	}
+	if c.Attempts > 0 {
+		p.attempts = c.Attempts
+	}
+	if c.Doubling > 0 {
+		p.doubling = c.Doubling
+	}
+	if c.Sleep != "" {
+		sleep, err := time.ParseDuration(c.Sleep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry sleep '%s': %v", c.Sleep, err)
+		}
+		if sleep <= 0 {
+			return nil, fmt.Errorf("retry sleep must be positive")
+		}
+		p.sleep = sleep
+	}
+
+	safeRoutes := defaultSafeRoutes
+	if c.SafeRoutes != nil {
+		safeRoutes = c.SafeRoutes
+	}
+	for _, route := range safeRoutes {
+		p.safeRoutes[route] = true
+	}
+
+	return p, nil
+}
+
+// idempotent checks whether a request can be sent again after bluecat may already have processed it.
+// PUT requests of the v2 API replace the whole resource, so they are idempotent as well.
+func (p *retryPolicy) idempotent(method, route string) bool {
+	if method == http.MethodPut && strings.HasPrefix(route, "/api/v2/") {
+		return true
+	}
+	return method == http.MethodGet || p.safeRoutes[route]
+}
+
+// retryable checks whether a request that failed with the error should be sent again.
+// Error responses other than 5xx are never retried, nor are 5xx responses that report missing or duplicate
+// entities. Idempotent requests are retried after server errors and connection failures, the other requests
+// only when the connection could not be established, as bluecat has not seen them then.
+func (p *retryPolicy) retryable(method, route string, err error) bool {
+	// Certificate errors do not go away by trying again
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) {
+		return false
+	}
+
+	var statusErr *bam.StatusError
+	if errors.As(err, &statusErr) {
+		return bam.IsTransient(err) && p.idempotent(method, route)
+	}
+	if p.idempotent(method, route) {
+		return true
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	bam "dns-api-go/internal/bluecat"
+	"dns-api-go/internal/common"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	policy, err := newRetryPolicy(nil)
+	common.CheckError(t, "Default policy", nil, err)
+
+	dialErr := fmt.Errorf("error sending HTTP request: %w", &net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")})
+	readErr := fmt.Errorf("error sending HTTP request: %w", &net.OpError{Op: "read", Err: fmt.Errorf("connection reset by peer")})
+
+	tests := []struct {
+		name     string
+		method   string
+		route    string
+		err      error
+		expected bool
+	}{
+		{"GET after server error", http.MethodGet, "/getEntityById", &bam.StatusError{StatusCode: 503}, true},
+		{"GET after client error", http.MethodGet, "/getEntityById", &bam.StatusError{StatusCode: 404}, false},
+		{"GET after connection reset", http.MethodGet, "/getEntityById", readErr, true},
+		{"POST after server error", http.MethodPost, "/addHostRecord", &bam.StatusError{StatusCode: 503}, false},
+		{"POST after connection reset", http.MethodPost, "/addHostRecord", readErr, false},
+		{"POST after failed dial", http.MethodPost, "/addHostRecord", dialErr, true},
+		{"Safe PUT after server error", http.MethodPut, "/update", &bam.StatusError{StatusCode: 500}, true},
+		{"v2 PUT after server error", http.MethodPut, "/api/v2/resourceRecords/12", &bam.StatusError{StatusCode: 502}, true},
+		{"v2 POST after server error", http.MethodPost, "/api/v2/views/3/resourceRecords", &bam.StatusError{StatusCode: 502}, false},
+		{"GET of a missing entity", http.MethodGet, "/getEntityById", &bam.StatusError{StatusCode: 500, Body: "Object was not found"}, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			common.CheckResponse(t, tc.name, tc.expected, policy.retryable(tc.method, tc.route, tc.err))
+		})
+	}
+
+	policy, err = newRetryPolicy(&common.Retry{Attempts: 5, Sleep: "10ms", SafeRoutes: []string{}})
+	common.CheckError(t, "Configured policy", nil, err)
+	common.CheckResponse(t, "Configured attempts", 5, policy.attempts)
+	common.CheckResponse(t, "Configured safe routes", false, policy.idempotent(http.MethodPut, "/update"))
+
+	for _, c := range []*common.Retry{{Attempts: -1}, {Sleep: "soon"}, {Sleep: "0s"}} {
+		if _, err := newRetryPolicy(c); err == nil {
+			t.Errorf("expected an error for retry configuration %+v", *c)
+		}
+	}
+}
+
+func TestMakeRequestRetries(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			fmt.Fprint(w, `"Session Token-> token <- for User : user"`)
+			return
+		}
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/flaky":
+			if requests[r.URL.Path] < 3 {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "ok")
+		case "/missing":
+			http.Error(w, "not found", http.StatusNotFound)
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	s, err := newServer(context.Background(), common.Config{
+		Org: "test",
+		Bluecat: &common.Bluecat{
+			Account: "test",
+			BaseUrl: ts.URL,
+			Retry:   &common.Retry{Attempts: 3, Sleep: "1ms"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.bluecat.user = "user"
+
+	resp, err := s.MakeRequest(http.MethodGet, "/flaky", "", nil)
+	common.CheckError(t, "Flaky GET", nil, err)
+	common.CheckResponse(t, "Flaky GET", "ok", string(resp))
+	common.CheckResponse(t, "Flaky GET attempts", 3, requests["/flaky"])
+
+	_, err = s.MakeRequest(http.MethodGet, "/missing", "", nil)
+	if _, ok := err.(*bam.StatusError); !ok {
+		t.Errorf("expected an unexpected status error, got %v", err)
+	}
+	common.CheckResponse(t, "Missing GET attempts", 1, requests["/missing"])
+
+	_, err = s.MakeRequest(http.MethodPost, "/addHostRecord", "", nil)
+	if err == nil {
+		t.Error("expected an error for a failed POST")
+	}
+	common.CheckResponse(t, "Failed POST attempts", 1, requests["/addHostRecord"])
+}
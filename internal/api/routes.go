@@ -17,18 +17,23 @@ along with this program. If not, see <http://www.gnu.org/licenses/>.
 package api
 
 import (
+	"dns-api-go/internal/audit"
 	"net/http"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func (s *server) routes() {
+	s.router.Use(MetricsMiddleware)
+	s.router.Use(TracingMiddleware)
+
 	api := s.router.PathPrefix("/v2/dns").Subrouter()
 	api.HandleFunc("/ping", s.PingHandler).Methods(http.MethodGet)
 	api.HandleFunc("/version", s.VersionHandler).Methods(http.MethodGet)
+	api.HandleFunc("/healthz", s.HealthHandler).Methods(http.MethodGet)
+	api.HandleFunc("/readyz", s.ReadyHandler).Methods(http.MethodGet)
 	api.Handle("/metrics", promhttp.Handler()).Methods(http.MethodGet)
 	api.HandleFunc("/", s.HomeHandler).Methods(http.MethodGet)
-	api.HandleFunc("/systeminfo", s.SystemInfoHandler).Methods(http.MethodGet)
 
 	// Create a subrouter for routes that need account validation
 	accountRouter := api.PathPrefix("/{account}").Subrouter()
@@ -36,35 +41,54 @@ func (s *server) routes() {
 	// Apply the middleware to all routes in this subrouter
 	accountRouter.Use(s.AccountValidationMiddleware)
 
-	// Custom search based on type and filters
-	accountRouter.HandleFunc("/search", s.CustomSearchHandler).Methods(http.MethodGet)
-
-	// Manage entities by ID
-	accountRouter.HandleFunc("/id/{id}", s.GetEntityHandler()).Methods(http.MethodGet)
-	accountRouter.HandleFunc("/id/{id}", s.DeleteEntityHandler()).Methods(http.MethodDelete)
-
 	// Manage Zones
-	accountRouter.HandleFunc("/zones", s.GetZonesHandler()).Methods(http.MethodGet)
-	accountRouter.HandleFunc("/zones/{id}", s.GetZoneHandler()).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/zones", requireScope(scopeZonesRead, s.GetZonesHandler())).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/zones/{id}", requireScope(scopeZonesRead, s.GetZoneHandler())).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/zones/{id}/export", requireScope(scopeZonesRead, s.ExportZoneHandler)).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/zones/{id}/import", requireScope(scopeZonesWrite, s.async("zone.import", s.ImportZoneHandler))).Methods(http.MethodPost)
 
 	// Manage DNS records
-	accountRouter.HandleFunc("/records", s.GetRecordsHandler).Methods(http.MethodGet)
-	accountRouter.HandleFunc("/records/{id}", s.GetRecordHandler()).Methods(http.MethodGet)
-	accountRouter.HandleFunc("/records/{id}", s.DeleteRecordHandler()).Methods(http.MethodDelete)
-	accountRouter.HandleFunc("/records", s.CreateRecordHandler).Methods(http.MethodPost)
+	accountRouter.HandleFunc("/records", requireScope(scopeRecordsRead, s.GetRecordsHandler)).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/records/{id}", requireScope(scopeRecordsRead, s.GetRecordHandler())).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/records/{id}", requireScope(scopeRecordsWrite, s.audited(audit.Update, s.UpdateRecordHandler))).Methods(http.MethodPut)
+	accountRouter.HandleFunc("/records/{id}", requireScope(scopeRecordsWrite, s.audited(audit.Delete, s.DeleteRecordHandler()))).Methods(http.MethodDelete)
+	accountRouter.HandleFunc("/records", requireScope(scopeRecordsWrite, s.idempotent(s.audited(audit.Create, s.CreateRecordHandler)))).Methods(http.MethodPost)
+	accountRouter.HandleFunc("/records/batch", requireScope(scopeRecordsWrite, s.idempotent(s.async("records.batch", s.BatchRecordsHandler)))).Methods(http.MethodPost)
+
+	// Jobs of asynchronous requests
+	accountRouter.HandleFunc("/jobs", requireScope(scopeJobsRead, s.GetJobsHandler)).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/jobs/{id}", requireScope(scopeJobsRead, s.GetJobHandler)).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/jobs/{id}", requireScope(scopeJobsCancel, s.CancelJobHandler)).Methods(http.MethodDelete)
+
+	// Audit log of the changes
+	accountRouter.HandleFunc("/audit", requireScope(scopeAuditRead, s.AuditHandler)).Methods(http.MethodGet)
+
+	// The remaining routes are only served by bluecat
+	if s.bluecat == nil {
+		return
+	}
+
+	api.HandleFunc("/systeminfo", requireScope(scopeSystemRead, s.SystemInfoHandler)).Methods(http.MethodGet)
+
+	// Custom search based on type and filters
+	accountRouter.HandleFunc("/search", requireScope(scopeEntitiesRead, s.CustomSearchHandler)).Methods(http.MethodGet)
+
+	// Manage entities by ID
+	accountRouter.HandleFunc("/id/{id}", requireScope(scopeEntitiesRead, s.GetEntityHandler())).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/id/{id}", requireScope(scopeEntitiesDelete, s.audited(audit.Delete, s.DeleteEntityHandler()))).Methods(http.MethodDelete)
 
 	// Manage Networks
-	accountRouter.HandleFunc("/networks", s.GetNetworksHandler()).Methods(http.MethodGet)
-	accountRouter.HandleFunc("/networks/{id}", s.GetNetworkHandler()).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/networks", requireScope(scopeNetworksRead, s.GetNetworksHandler())).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/networks/{id}", requireScope(scopeNetworksRead, s.GetNetworkHandler())).Methods(http.MethodGet)
 
 	// Manage IP addresses
-	accountRouter.HandleFunc("/ips/cidrs", s.GetCIDRHandler).Methods(http.MethodGet)
-	accountRouter.HandleFunc("/ips/{ip}", s.GetIpAddressHandler).Methods(http.MethodGet)
-	accountRouter.HandleFunc("/ips/{ip}", s.DeleteIpAddressHandler).Methods(http.MethodDelete)
-	accountRouter.HandleFunc("/ips", s.AssignIpAddressHandler).Methods(http.MethodPost)
+	accountRouter.HandleFunc("/ips/cidrs", requireScope(scopeIpsRead, s.GetCIDRHandler)).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/ips/{ip}", requireScope(scopeIpsRead, s.GetIpAddressHandler)).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/ips/{ip}", requireScope(scopeIpsAssign, s.audited(audit.Delete, s.DeleteIpAddressHandler))).Methods(http.MethodDelete)
+	accountRouter.HandleFunc("/ips", requireScope(scopeIpsAssign, s.idempotent(s.audited(audit.Create, s.AssignIpAddressHandler)))).Methods(http.MethodPost)
 
 	// Manage MAC addresses
-	accountRouter.HandleFunc("/macs/{mac}", s.GetMacAddressHandler).Methods(http.MethodGet)
-	accountRouter.HandleFunc("/macs", s.CreateMacAddressHandler).Methods(http.MethodPost)
-	accountRouter.HandleFunc("/macs/{mac}", s.UpdateMacAddressHandler).Methods(http.MethodPut)
+	accountRouter.HandleFunc("/macs/{mac}", requireScope(scopeMacsRead, s.GetMacAddressHandler)).Methods(http.MethodGet)
+	accountRouter.HandleFunc("/macs", requireScope(scopeMacsWrite, s.idempotent(s.audited(audit.Create, s.CreateMacAddressHandler)))).Methods(http.MethodPost)
+	accountRouter.HandleFunc("/macs/{mac}", requireScope(scopeMacsWrite, s.audited(audit.Update, s.UpdateMacAddressHandler))).Methods(http.MethodPut)
 }
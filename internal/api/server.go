@@ -18,18 +18,29 @@ package api
 
 import (
 	"context"
+	"dns-api-go/internal/audit"
+	bam "dns-api-go/internal/bluecat"
 	"dns-api-go/internal/common"
+	"dns-api-go/internal/interfaces"
+	"dns-api-go/internal/policy"
 	"dns-api-go/internal/services"
 	"dns-api-go/logger"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	"go.uber.org/zap"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
@@ -54,43 +65,136 @@ type proxyBackend struct {
 }
 
 type bluecat struct {
-	account   string
-	baseUrl   string
-	user      string
-	password  string
-	token     string
-	tokenLock sync.Mutex
-	viewId    string
+	account    string
+	baseUrl    string
+	user       string
+	password   string
+	token      string
+	tokenLock  sync.Mutex
+	viewId     string
+	apiVersion string
+	retry      *retryPolicy
+	client     *http.Client
+	lookups    *services.LookupCache
 }
 
 type Services struct {
 	BaseService *services.BaseService
-	ZoneService *services.ZoneService
+	ZoneService services.ZoneEntityService
 	NetworkService *services.NetworkService
 	MacAddressService *services.MacAddressService
 	IpAddressService *services.IpAddressService
-	RecordService *services.RecordService
+	RecordService services.RecordEntityService
 }
 
 type server struct {
-	router   *mux.Router
-	version  *apiVersion
-	context  context.Context
-	backend  *proxyBackend
-	bluecat  *bluecat
-	org      string
-	services Services
-	cidrFile string
+	router      *mux.Router
+	version     *apiVersion
+	context     context.Context
+	backend     *proxyBackend
+	bluecat     *bluecat
+	account     string
+	org         string
+	services    Services
+	cidrFile    string
+	policy      *policy.Engine
+	audit       audit.Sink
+	webhooks    *webhookDispatcher
+	idempotency *idempotencyStore
+	jobs        *jobManager
+	ready       atomic.Bool
+	health      *healthChecker
+	tracing     *sdktrace.TracerProvider
 }
 
-// NewServer creates a new server and starts it
+// newServices creates the services that interact with Bluecat entities through the server
+func newServices(server interfaces.ServerInterface) Services {
+	return Services{
+		BaseService: services.NewBaseService(server),
+		ZoneService: services.NewZoneService(server),
+		NetworkService: services.NewNetworkService(server),
+		MacAddressService: services.NewMacAddressService(server),
+		IpAddressService: services.NewIpAddressService(server),
+		RecordService: services.NewRecordService(server),
+	}
+}
+
+// NewServer creates a new server and starts it. The server runs until it receives SIGTERM or SIGINT, then it
+// shuts down gracefully.
 func NewServer(config common.Config) error {
 	// setup server context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	s, err := newServer(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	// Export the traces of the requests and their bluecat calls
+	if s.tracing, err = newTracerProvider(config.Tracing, config.Version.Version); err != nil {
+		return err
+	}
+	if s.tracing != nil {
+		otel.SetTracerProvider(s.tracing)
+	}
+
+	credentials, err := newCredentialStore(config.Token, config.Clients)
+	if err != nil {
+		return err
+	}
+	if credentials.bearer, err = newJWTVerifier(config.OIDC); err != nil {
+		return err
+	}
+
+	publicURLs := map[string]string{
+		"/v2/dns/ping":    "public",
+		"/v2/dns/version": "public",
+		"/v2/dns/healthz": "public",
+		"/v2/dns/readyz":  "public",
+		"/v2/dns/metrics": "public",
+	}
+
+	delay, timeout, err := shutdownTimings(config.Shutdown)
+	if err != nil {
+		return err
+	}
+
+	if config.ListenAddress == "" {
+		config.ListenAddress = ":8080"
+	}
+	handler := handlers.RecoveryHandler()(handlers.LoggingHandler(os.Stdout, AuthMiddleware(credentials, publicURLs, s.router)))
+	srv := &http.Server{
+		Handler:      handler,
+		Addr:         config.ListenAddress,
+		WriteTimeout: 15 * time.Second,
+		ReadTimeout:  15 * time.Second,
+	}
+
+	// Serve until the listener fails or a signal asks the server to stop
+	signals, stop := signal.NotifyContext(ctx, syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+	failed := make(chan error, 1)
+	go func() {
+		logger.Info("Starting listener", zap.String("address", config.ListenAddress))
+		failed <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-failed:
+		return err
+	case <-signals.Done():
+		stop()
+		logger.Info("Received signal to stop the server")
+	}
+
+	return s.shutdown(srv, cancel, delay, timeout)
+}
+
+// newServer configures a server and its routes from the configuration without starting a listener
+func newServer(ctx context.Context, config common.Config) (*server, error) {
 	if config.Org == "" {
-		return errors.New("'org' cannot be empty in the configuration")
+		return nil, errors.New("'org' cannot be empty in the configuration")
 	}
 
 	s := server{
@@ -107,32 +211,127 @@ func NewServer(config common.Config) error {
 
 	if b := config.Bluecat; b != nil {
 		logger.Debug("configuring bluecat", zap.String("baseUrl", b.BaseUrl))
+		retry, err := newRetryPolicy(b.Retry)
+		if err != nil {
+			return nil, err
+		}
+		client, err := newBluecatClient(b)
+		if err != nil {
+			return nil, err
+		}
+		lookups, err := services.NewLookupCache(b.Cache)
+		if err != nil {
+			return nil, err
+		}
+		apiVersion := b.APIVersion
+		switch apiVersion {
+		case "":
+			apiVersion = bam.V1
+		case bam.V1, bam.V2:
+		default:
+			return nil, fmt.Errorf("unsupported bluecat API version '%s'", b.APIVersion)
+		}
 		s.bluecat = &bluecat{
-			account:  b.Account,
-			baseUrl:  b.BaseUrl,
-			user:     b.Username,
-			password: b.Password,
-			viewId:   b.ViewId,
+			account:    b.Account,
+			baseUrl:    strings.TrimSuffix(b.BaseUrl, "/"),
+			user:       b.Username,
+			password:   b.Password,
+			viewId:     b.ViewId,
+			apiVersion: apiVersion,
+			retry:      retry,
+			client:     client,
+			lookups:    lookups,
 		}
+		s.account = b.Account
 	}
 
 	// Set CIDR file
 	s.cidrFile = config.CIDRFile
 
+	// Record the changes in the audit log, or only in memory without one
+	if config.Audit != nil && config.Audit.File != "" {
+		sink, err := audit.NewFileSink(config.Audit.File)
+		if err != nil {
+			return nil, err
+		}
+		s.audit = sink
+	} else {
+		s.audit = audit.NewMemorySink(memoryAuditEvents)
+	}
+
+	// Send the changes to the subscribed webhooks
+	webhooks, err := newWebhookDispatcher(config.Webhooks)
+	if err != nil {
+		return nil, err
+	}
+	s.webhooks = webhooks
+
+	// Keep the responses to requests with idempotency keys
+	if s.idempotency, err = newIdempotencyStore(config.Idempotency); err != nil {
+		return nil, err
+	}
+
+	// Run the asynchronous requests in the background under the server context
+	if s.jobs, err = newJobManager(ctx, config.Jobs); err != nil {
+		return nil, err
+	}
+
+	// Keep the outcome of the readiness checks
+	if s.health, err = newHealthChecker(config.Health); err != nil {
+		return nil, err
+	}
+
+	// Parse the policy rules, whose networks can be named after the networks of the CIDR file
+	if len(config.Policies) > 0 {
+		cidrNames := map[string]string{}
+		if s.cidrFile != "" {
+			content, err := s.GetCIDRFile()
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(content), &cidrNames); err != nil {
+				return nil, fmt.Errorf("invalid CIDR file: %v", err)
+			}
+		}
+		engine, err := policy.New(config.Policies, cidrNames)
+		if err != nil {
+			return nil, err
+		}
+		s.policy = engine
+	}
+
 	// Define services that interact with Bluecat entities
-	baseService := services.NewBaseService(&s)
-	zoneService := services.NewZoneService(&s)
-	networkService := services.NewNetworkService(&s)
-	macAddressService := services.NewMacAddressService(&s)
-	ipAddressService := services.NewIpAddressService(&s)
-	recordService := services.NewRecordService(&s)
-	s.services = Services{
-		BaseService: baseService,
-		ZoneService: zoneService,
-		NetworkService: networkService,
-		MacAddressService: macAddressService,
-		IpAddressService: ipAddressService,
-		RecordService: recordService,
+	s.services = newServices(&s)
+
+	// Serve zones and records from the configured DNS provider
+	switch config.Provider {
+	case "", "bluecat":
+		if s.bluecat == nil {
+			return nil, errors.New("'bluecat' must be configured when using the bluecat provider")
+		}
+	case "route53":
+		r := config.Route53
+		if r == nil {
+			return nil, errors.New("'route53' must be configured when using the route53 provider")
+		}
+		// The bluecat and route53 endpoints share the account of the routes
+		if s.account != "" && r.Account != "" && s.account != r.Account {
+			return nil, fmt.Errorf("the route53 account '%s' differs from the bluecat account '%s'", r.Account, s.account)
+		}
+		logger.Debug("configuring route53", zap.String("region", r.Region), zap.String("endpoint", r.Endpoint))
+
+		client, err := newRoute53Client(r)
+		if err != nil {
+			return nil, err
+		}
+		s.services.ZoneService = services.NewRoute53ZoneService(client, r.HostedZoneIds)
+		s.services.RecordService = services.NewRoute53RecordService(client, r.HostedZoneIds)
+
+		if r.Account != "" {
+			s.account = r.Account
+		}
+	default:
+		return nil, fmt.Errorf("unsupported provider '%s'", config.Provider)
 	}
 
 	if b := config.ProxyBackend; b != nil {
@@ -144,32 +343,11 @@ func NewServer(config common.Config) error {
 		}
 	}
 
-	publicURLs := map[string]string{
-		"/v2/dns/ping":    "public",
-		"/v2/dns/version": "public",
-		"/v2/dns/metrics": "public",
-	}
-
 	// load routes
 	s.routes()
+	s.ready.Store(true)
 
-	if config.ListenAddress == "" {
-		config.ListenAddress = ":8080"
-	}
-	handler := handlers.RecoveryHandler()(handlers.LoggingHandler(os.Stdout, TokenMiddleware([]byte(config.Token), publicURLs, s.router)))
-	srv := &http.Server{
-		Handler:      handler,
-		Addr:         config.ListenAddress,
-		WriteTimeout: 15 * time.Second,
-		ReadTimeout:  15 * time.Second,
-	}
-
-	logger.Info("Starting listener", zap.String("address", config.ListenAddress))
-	if err := srv.ListenAndServe(); err != nil {
-		return err
-	}
-
-	return nil
+	return &s, nil
 }
 
 // LogWriter is an http.ResponseWriter
@@ -186,40 +364,6 @@ func (w LogWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
-type rollbackFunc func(ctx context.Context) error
-
-// rollBack executes functions from a stack of rollback functions
-func rollBack(t *[]rollbackFunc) {
-	if t == nil {
-		return
-	}
-
-	timeout, cancel := context.WithTimeout(context.Background(), 120*time.Second)
-	defer cancel()
-
-	done := make(chan string, 1)
-	go func() {
-		tasks := *t
-		logger.Error("executing rollback of tasks", zap.Int("taskCount", len(tasks)))
-		for i := len(tasks) - 1; i >= 0; i-- {
-			f := tasks[i]
-			if funcerr := f(timeout); funcerr != nil {
-				logger.Error("rollback task error, continuing rollback", zap.Error(funcerr))
-			}
-			logger.Info("executed rollback task", zap.Int("currentTask", len(tasks)-i), zap.Int("totalTasks", len(tasks)))
-		}
-		done <- "success"
-	}()
-
-	// wait for a done context
-	select {
-	case <-timeout.Done():
-		logger.Error("timeout waiting for successful rollback")
-	case <-done:
-		logger.Info("successfully rolled back")
-	}
-}
-
 type stop struct {
 	error
 }
@@ -229,6 +373,12 @@ type stop struct {
 // sleeping after each attempt, starting with _sleep_ time and doubling it
 // for the first _doubling_ times, then keeping it constant (+ jitter)
 func retry(attempts int, doubling int, sleep time.Duration, f func() error) error {
+	return retryWithContext(context.Background(), attempts, doubling, sleep, f)
+}
+
+// retryWithContext retries like retry, but stops waiting for the next attempt and returns the error of the
+// context when the context is done
+func retryWithContext(ctx context.Context, attempts int, doubling int, sleep time.Duration, f func() error) error {
 	if err := f(); err != nil {
 		if s, ok := err.(stop); ok {
 			// return the original error for later checking
@@ -240,12 +390,18 @@ func retry(attempts int, doubling int, sleep time.Duration, f func() error) erro
 			jitter := time.Duration(rand.Int63n(int64(sleep)))
 			sleep = sleep + jitter/2
 
-			time.Sleep(sleep)
+			timer := time.NewTimer(sleep)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
 			if doubling--; doubling > 0 {
-				return retry(attempts, doubling, 2*sleep, f)
+				return retryWithContext(ctx, attempts, doubling, 2*sleep, f)
 			}
 			// stop doubling the sleep interval after some point to prevent it from getting too big
-			return retry(attempts, 0, sleep, f)
+			return retryWithContext(ctx, attempts, 0, sleep, f)
 		}
 		return err
 	}
@@ -8,50 +8,6 @@ import (
 	"time"
 )
 
-func TestRollback(t *testing.T) {
-	// nil input
-	var rbfuncs []rollbackFunc
-	rollBack(&rbfuncs)
-
-	// empty input
-	rbfuncs = []rollbackFunc{}
-	rollBack(&rbfuncs)
-
-	// test rolling back
-	v := []int{}
-	for i := 0; i < 10; i++ {
-		v = append(v, i)
-		f := func(ctx context.Context) error {
-			if len(v) == 0 {
-				return nil
-			}
-
-			value := v[len(v)-1]
-			index := len(v) - 1
-
-			t.Logf("rolling back value %d with index %d", value, index)
-
-			if value != index {
-				t.Errorf("unexpected value %d for v index %d", value, index)
-				return fmt.Errorf("unexpected value %d for v index %d", value, index)
-			}
-
-			v = v[:len(v)-1]
-			return nil
-		}
-
-		rbfuncs = append(rbfuncs, f)
-	}
-	rollBack(&rbfuncs)
-
-	// return an error
-	f := func(ctx context.Context) error {
-		return errors.New("boom")
-	}
-	rbfuncs = append(rbfuncs, f)
-	rollBack(&rbfuncs)
-}
-
 func TestRetry(t *testing.T) {
 	if err := retry(3, 2, 1*time.Millisecond, func() error {
 		return errors.New("boom")
@@ -78,4 +34,17 @@ func TestRetry(t *testing.T) {
 	if err := retry(3, 2, 1*time.Millisecond, f); err != nil {
 		t.Errorf("unexpected error for successful retry, got %s", err)
 	}
+
+	// A done context stops the retries instead of waiting for the next attempt
+	ctx, cancel := context.WithCancel(context.Background())
+	tries := 0
+	start := time.Now()
+	err := retryWithContext(ctx, 3, 2, time.Hour, func() error {
+		tries++
+		cancel()
+		return errors.New("boom")
+	})
+	if err != context.Canceled || tries != 1 || time.Since(start) > time.Minute {
+		t.Errorf("expected the cancelled retry to stop after 1 try, got %d tries and error %v", tries, err)
+	}
 }
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"dns-api-go/internal/common"
+	"dns-api-go/logger"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"time"
+)
+
+// jobInterruptGrace is the time the jobs get to stop after they were interrupted at the shutdown timeout
+const jobInterruptGrace = 2 * time.Second
+
+// shutdownTimings returns the delay between failing the readiness probe and draining, and the time the requests and
+// jobs get to finish. The server drains after 5 seconds and waits 25 seconds by default, which fits into the default
+// grace period of Kubernetes.
+func shutdownTimings(c *common.Shutdown) (delay, timeout time.Duration, err error) {
+	delay, timeout = 5*time.Second, 25*time.Second
+	if c == nil {
+		return delay, timeout, nil
+	}
+	if c.Delay != "" {
+		if delay, err = time.ParseDuration(c.Delay); err != nil || delay < 0 {
+			return 0, 0, fmt.Errorf("invalid shutdown delay '%s'", c.Delay)
+		}
+	}
+	if c.Timeout != "" {
+		if timeout, err = time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+			return 0, 0, fmt.Errorf("invalid shutdown timeout '%s'", c.Timeout)
+		}
+	}
+	return delay, timeout, nil
+}
+
+// shutdown stops the server gracefully. The readiness probe fails first, and after the delay the listener is closed
+// and the in-flight requests and the background jobs get until the timeout to finish. The server context is cancelled
+// afterwards, which interrupts the jobs that did not finish, and jobs that do not stop within a short grace period
+// are abandoned. Finally the queued webhook events are delivered, the
+// audit log is closed, the bluecat session is logged out and the remaining spans are exported.
+func (s *server) shutdown(srv *http.Server, cancel context.CancelFunc, delay, timeout time.Duration) error {
+	s.ready.Store(false)
+	logger.Info("Shutting down", zap.Duration("delay", delay), zap.Duration("timeout", timeout))
+	time.Sleep(delay)
+
+	ctx, cancelTimeout := context.WithTimeout(context.Background(), timeout)
+	defer cancelTimeout()
+
+	var shutdownErr error
+	if err := srv.Shutdown(ctx); err != nil {
+		logger.Error("Requests did not finish before the shutdown timeout", zap.Error(err))
+		shutdownErr = err
+	}
+	if err := s.jobs.drain(ctx); err != nil {
+		logger.Error("Jobs did not finish before the shutdown timeout, interrupting them", zap.Error(err))
+		cancel()
+		grace, cancelGrace := context.WithTimeout(context.Background(), jobInterruptGrace)
+		if err := s.jobs.drain(grace); err != nil {
+			logger.Error("Jobs did not stop after being interrupted, abandoning them",
+				zap.Strings("jobs", s.jobs.unfinished()), zap.Error(err))
+		}
+		cancelGrace()
+	}
+	cancel()
+
+	// Deliver the webhook events of the last changes for as long as the timeout allows
+	delivered := make(chan struct{})
+	go func() {
+		s.webhooks.close()
+		close(delivered)
+	}()
+	select {
+	case <-delivered:
+	case <-ctx.Done():
+		logger.Error("Webhook events were not delivered before the shutdown timeout")
+	}
+
+	if closer, ok := s.audit.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logger.Error("Unable to close audit log", zap.Error(err))
+		}
+	}
+	s.logout()
+
+	// Export the spans of the last requests for as long as the timeout allows
+	if s.tracing != nil {
+		if err := s.tracing.Shutdown(ctx); err != nil {
+			logger.Error("Unable to export the remaining spans", zap.Error(err))
+		}
+	}
+
+	logger.Info("Shutdown complete")
+	return shutdownErr
+}
+
+// logout ends the bluecat session of the server, if it has one
+func (s *server) logout() {
+	if s.bluecat == nil {
+		return
+	}
+	s.bluecat.tokenLock.Lock()
+	defer s.bluecat.tokenLock.Unlock()
+	if s.bluecat.token == "" {
+		return
+	}
+
+	if err := s.sessions().Logout(s.bluecat.token); err != nil {
+		return
+	}
+
+	s.bluecat.token = ""
+	logger.Info("Logged out of bluecat")
+}
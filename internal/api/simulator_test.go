@@ -0,0 +1,831 @@
+package api
+
+import (
+	"context"
+	"dns-api-go/internal/audit"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/policy"
+	"dns-api-go/internal/simulator"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSimulatedServer returns a server backed by an in-memory BlueCat simulator
+// with the zone example.com and the network 10.0.0.0/24
+func newSimulatedServer(t *testing.T) (*server, *simulator.Simulator) {
+	sim := simulator.New("user", "password")
+	if _, err := sim.AddZone("example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.AddNetwork("test", "10.0.0.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(sim)
+	t.Cleanup(ts.Close)
+
+	s, err := newServer(context.Background(), common.Config{
+		Org: "test",
+		Bluecat: &common.Bluecat{
+			Account:  "test",
+			BaseUrl:  ts.URL + "/Services/REST/v1",
+			Username: "user",
+			Password: "password",
+			ViewId:   strconv.Itoa(sim.ViewId()),
+			Retry:    &common.Retry{Sleep: "1ms"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, sim
+}
+
+// asAdmin authenticates a request as a client with every scope, as the middleware does for the token of the server
+func asAdmin(req *http.Request) *http.Request {
+	return req.WithContext(withClient(req.Context(), &apiClient{name: "admin", scopes: []string{"*"}}))
+}
+
+// serve sends a request to the router of the server and decodes the JSON response into out, if given
+func serve(t *testing.T, s *server, method, path, body string, out interface{}) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, asAdmin(req))
+
+	if out != nil && rr.Code < 300 {
+		if err := json.Unmarshal(rr.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: failed to decode response %s: %s", method, path, rr.Body.String(), err)
+		}
+	}
+	return rr.Code
+}
+
+func TestSimulatedRecordLifecycle(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+
+	var created map[string]interface{}
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "app.example.com", "target": "10.0.0.10", "ttl": 300}`, &created)
+	common.CheckResponse(t, "Create host record", http.StatusCreated, status)
+	id := int(created["id"].(float64))
+
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "app.example.com", "target": "10.0.0.11"}`, nil)
+	common.CheckResponse(t, "Create duplicate host record", http.StatusConflict, status)
+
+	var mx map[string]interface{}
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "MXRecord", "record": "example.com", "target": "mail.example.com", "priority": 10}`, &mx)
+	common.CheckResponse(t, "Create MX record", http.StatusCreated, status)
+
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HINFORecord", "record": "app.example.com", "cpu": "x86_64", "os": "Linux"}`, nil)
+	common.CheckResponse(t, "Create HINFO record", http.StatusCreated, status)
+
+	var updated struct {
+		Properties map[string]string `json:"properties"`
+	}
+	status = serve(t, s, http.MethodPut, fmt.Sprintf("/v2/dns/test/records/%d", int(mx["id"].(float64))),
+		`{"priority": 20}`, &updated)
+	common.CheckResponse(t, "Update MX record priority", http.StatusOK, status)
+	common.CheckResponse(t, "Update MX record priority", "20", updated.Properties["priority"])
+
+	status = serve(t, s, http.MethodPut, fmt.Sprintf("/v2/dns/test/records/%d", id), `{"port": 443}`, nil)
+	common.CheckResponse(t, "Update port of host record", http.StatusBadRequest, status)
+
+	status = serve(t, s, http.MethodPut, fmt.Sprintf("/v2/dns/test/records/%d", id), `{"properties": "addresses=not-an-ip|"}`, nil)
+	common.CheckResponse(t, "Update addresses through properties", http.StatusBadRequest, status)
+
+	status = serve(t, s, http.MethodPut, fmt.Sprintf("/v2/dns/test/records/%d", id),
+		`{"target": "10.0.0.12", "ttl": 600}`, &updated)
+	common.CheckResponse(t, "Update host record", http.StatusOK, status)
+	common.CheckResponse(t, "Update host record", "10.0.0.12", updated.Properties["addresses"])
+	common.CheckResponse(t, "Update host record", "600", updated.Properties["ttl"])
+
+	var records []map[string]interface{}
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/records?type=HostRecord&hint=app", "", &records)
+	common.CheckResponse(t, "List host records", http.StatusOK, status)
+	common.CheckResponse(t, "List host records", 1, len(records))
+
+	status = serve(t, s, http.MethodDelete, fmt.Sprintf("/v2/dns/test/records/%d", id), "", nil)
+	common.CheckResponse(t, "Delete host record", http.StatusNoContent, status)
+
+	status = serve(t, s, http.MethodGet, fmt.Sprintf("/v2/dns/test/records/%d", id), "", nil)
+	common.CheckResponse(t, "Get deleted host record", http.StatusNotFound, status)
+}
+
+func TestSimulatedIpAssignment(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+
+	var networks []map[string]interface{}
+	status := serve(t, s, http.MethodGet, "/v2/dns/test/networks?hint=10.0.0", "", &networks)
+	common.CheckResponse(t, "List networks", http.StatusOK, status)
+	common.CheckResponse(t, "List networks", 1, len(networks))
+	networkId := int(networks[0]["id"].(float64))
+
+	// The network address and the gateway are never assigned
+	var assigned map[string]interface{}
+	body := fmt.Sprintf(`{"mac": "00:11:22:33:44:55", "network_id": %d, "hostname": "host1.example.com", "reverse": true}`, networkId)
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/ips", body, &assigned)
+	common.CheckResponse(t, "Assign ip address", http.StatusOK, status)
+	common.CheckResponse(t, "Assign ip address", "10.0.0.2", assigned["ip"])
+
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/ips/10.0.0.2", "", nil)
+	common.CheckResponse(t, "Get ip address", http.StatusOK, status)
+
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/macs/00:11:22:33:44:55", "", nil)
+	common.CheckResponse(t, "Get mac address", http.StatusOK, status)
+
+	var hosts []map[string]interface{}
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/records?type=HostRecord&hint=host1", "", &hosts)
+	common.CheckResponse(t, "Get linked host record", http.StatusOK, status)
+	common.CheckResponse(t, "Get linked host record", 1, len(hosts))
+
+	status = serve(t, s, http.MethodDelete, "/v2/dns/test/ips/10.0.0.2", "", nil)
+	common.CheckResponse(t, "Delete ip address", http.StatusNoContent, status)
+
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/ips/10.0.0.2", "", nil)
+	common.CheckResponse(t, "Get deleted ip address", http.StatusNotFound, status)
+}
+
+func TestSimulatedZoneExport(t *testing.T) {
+	s, sim := newSimulatedServer(t)
+	zoneId, err := sim.AddZone("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{
+		`{"type": "HostRecord", "record": "www.example.com", "target": "10.0.0.10", "ttl": 300}`,
+		`{"type": "AliasRecord", "record": "alias.example.com", "target": "www.example.com", "ttl": 300}`,
+		`{"type": "TXTRecord", "record": "example.com", "text": "v=spf1 -all", "ttl": 300}`,
+	} {
+		status := serve(t, s, http.MethodPost, "/v2/dns/test/records", body, nil)
+		common.CheckResponse(t, "Create record", http.StatusCreated, status)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v2/dns/test/zones/%d/export", zoneId), nil)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, asAdmin(req))
+	common.CheckResponse(t, "Export zone", http.StatusOK, rr.Code)
+
+	expected := strings.Join([]string{
+		"$ORIGIN example.com.",
+		"$TTL 3600",
+		"@     300 IN TXT   \"v=spf1 -all\"",
+		"alias 300 IN CNAME www.example.com.",
+		"www   300 IN A     10.0.0.10",
+		"",
+	}, "\n")
+	common.CheckResponse(t, "Export zone", expected, rr.Body.String())
+
+	status := serve(t, s, http.MethodGet, "/v2/dns/test/zones/1/export", "", nil)
+	common.CheckResponse(t, "Export unknown zone", http.StatusNotFound, status)
+}
+
+func TestSimulatedZoneImport(t *testing.T) {
+	s, sim := newSimulatedServer(t)
+	zoneId, err := sim.AddZone("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "old.example.com", "target": "10.0.0.9", "ttl": 300}`, nil)
+	common.CheckResponse(t, "Create record", http.StatusCreated, status)
+
+	zoneFile := `$TTL 300
+@     IN MX    10 mail
+@     IN TXT   "v=spf1 -all"
+www   IN A     10.0.0.10
+alias IN CNAME www
+`
+	importPath := fmt.Sprintf("/v2/dns/test/zones/%d/import", zoneId)
+
+	// A dry run only returns the plan
+	var plan struct {
+		Changes []struct {
+			Action string `json:"action"`
+			Type   string `json:"type"`
+			Name   string `json:"name"`
+			Error  string `json:"error"`
+		} `json:"changes"`
+		Applied bool `json:"applied"`
+	}
+	status = serve(t, s, http.MethodPost, importPath, zoneFile, &plan)
+	common.CheckResponse(t, "Dry run", http.StatusOK, status)
+	common.CheckResponse(t, "Dry run", 5, len(plan.Changes))
+	common.CheckResponse(t, "Dry run", false, plan.Applied)
+	common.CheckResponse(t, "Dry run", "delete", plan.Changes[0].Action)
+
+	var records []map[string]interface{}
+	serve(t, s, http.MethodGet, "/v2/dns/test/records?type=HostRecord&hint=old", "", &records)
+	common.CheckResponse(t, "Dry run keeps records", 1, len(records))
+
+	status = serve(t, s, http.MethodPost, importPath+"?apply=true", zoneFile, &plan)
+	common.CheckResponse(t, "Apply", http.StatusOK, status)
+	common.CheckResponse(t, "Apply", true, plan.Applied)
+
+	// Importing the export of the zone results in no changes
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/v2/dns/test/zones/%d/export", zoneId), nil)
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, asAdmin(req))
+	common.CheckResponse(t, "Export zone", http.StatusOK, rr.Code)
+
+	status = serve(t, s, http.MethodPost, importPath, rr.Body.String(), &plan)
+	common.CheckResponse(t, "Reimport", http.StatusOK, status)
+	common.CheckResponse(t, "Reimport", 0, len(plan.Changes))
+
+	status = serve(t, s, http.MethodPost, importPath, "www.example.org. IN A 10.0.0.1", nil)
+	common.CheckResponse(t, "Record outside of the zone", http.StatusBadRequest, status)
+}
+
+func TestSimulatedRollback(t *testing.T) {
+	s, sim := newSimulatedServer(t)
+	zoneId, err := sim.AddZone("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The mac address is removed when it cannot be associated with its pool
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/macs", `{"mac": "00:11:22:33:44:66", "macpool": 999}`, nil)
+	common.CheckResponse(t, "Create mac address with unknown pool", http.StatusBadRequest, status)
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/macs/00:11:22:33:44:66", "", nil)
+	common.CheckResponse(t, "Get rolled back mac address", http.StatusNotFound, status)
+
+	// Bluecat assigns the address along with its host record, so nothing is left behind when the host record is rejected
+	var networks []map[string]interface{}
+	serve(t, s, http.MethodGet, "/v2/dns/test/networks?hint=10.0.0", "", &networks)
+	networkId := int(networks[0]["id"].(float64))
+	body := fmt.Sprintf(`{"mac": "00:11:22:33:44:77", "network_id": %d, "hostname": "host.example.org", "reverse": true}`, networkId)
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/ips", body, nil)
+	common.CheckResponse(t, "Assign ip address outside of the zones", http.StatusInternalServerError, status)
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/ips/10.0.0.2", "", nil)
+	common.CheckResponse(t, "Get unassigned ip address", http.StatusNotFound, status)
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/macs/00:11:22:33:44:77", "", nil)
+	common.CheckResponse(t, "Get uncreated mac address", http.StatusNotFound, status)
+
+	// A zone import that fails half way leaves the zone unchanged, the alias is created before the conflicting host record
+	zoneFile := `$TTL 300
+dup   IN CNAME www
+dup   IN A     10.0.0.20
+`
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/v2/dns/test/zones/%d/import?apply=true", zoneId), strings.NewReader(zoneFile))
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, asAdmin(req))
+	common.CheckResponse(t, "Apply conflicting zone file", http.StatusInternalServerError, rr.Code)
+
+	var plan struct {
+		Changes []struct {
+			Error string `json:"error"`
+		} `json:"changes"`
+		Applied bool `json:"applied"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &plan); err != nil {
+		t.Fatal(err)
+	}
+	common.CheckResponse(t, "Apply conflicting zone file", false, plan.Applied)
+	if !strings.Contains(plan.Changes[1].Error, "1 change(s) rolled back") {
+		t.Errorf("expected the rollback to be reported, got %q", plan.Changes[1].Error)
+	}
+
+	var records []map[string]interface{}
+	serve(t, s, http.MethodGet, "/v2/dns/test/records?type=AliasRecord&hint=dup", "", &records)
+	common.CheckResponse(t, "Rolled back alias record", 0, len(records))
+
+	// A host that already has a host record is assigned an address as well
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "known.example.com", "target": "10.0.0.50"}`, nil)
+	common.CheckResponse(t, "Create existing host record", http.StatusCreated, status)
+	body = fmt.Sprintf(`{"mac": "00:11:22:33:44:88", "network_id": %d, "hostname": "known.example.com", "reverse": true}`, networkId)
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/ips", body, nil)
+	common.CheckResponse(t, "Assign ip address to a known host", http.StatusOK, status)
+}
+
+func TestSimulatedConditionalRequests(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+
+	// A second instance of the API with its own cache in front of the same bluecat
+	other, err := newServer(context.Background(), common.Config{
+		Org: "test",
+		Bluecat: &common.Bluecat{
+			Account:  "test",
+			BaseUrl:  s.bluecat.baseUrl,
+			Username: "user",
+			Password: "password",
+			ViewId:   s.bluecat.viewId,
+			Retry:    &common.Retry{Sleep: "1ms"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var created map[string]interface{}
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "cond.example.com", "target": "10.0.0.20", "ttl": 300}`, &created)
+	common.CheckResponse(t, "Create host record", http.StatusCreated, status)
+	path := fmt.Sprintf("/v2/dns/test/records/%d", int(created["id"].(float64)))
+
+	send := func(server *server, method, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header = header
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, asAdmin(req))
+		return rr
+	}
+
+	rr := send(s, http.MethodGet, "", http.Header{})
+	common.CheckResponse(t, "Get record", http.StatusOK, rr.Code)
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag header")
+	}
+
+	rr = send(s, http.MethodGet, "", http.Header{"If-None-Match": {etag}})
+	common.CheckResponse(t, "Get unchanged record", http.StatusNotModified, rr.Code)
+	common.CheckResponse(t, "Not modified body", "", rr.Body.String())
+
+	// The other instance changes the record, so the tag of the first client is outdated
+	rr = send(other, http.MethodPut, `{"ttl": 600}`, http.Header{"If-Match": {etag}})
+	common.CheckResponse(t, "Update with current tag", http.StatusOK, rr.Code)
+	current := rr.Header().Get("ETag")
+
+	rr = send(s, http.MethodPut, `{"ttl": 900}`, http.Header{"If-Match": {etag}})
+	common.CheckResponse(t, "Update with outdated tag", http.StatusPreconditionFailed, rr.Code)
+	common.CheckResponse(t, "Current tag", current, rr.Header().Get("ETag"))
+
+	rr = send(s, http.MethodDelete, "", http.Header{"If-Match": {etag}})
+	common.CheckResponse(t, "Delete with outdated tag", http.StatusPreconditionFailed, rr.Code)
+
+	rr = send(s, http.MethodGet, "", http.Header{"If-None-Match": {etag}})
+	common.CheckResponse(t, "Get changed record", http.StatusOK, rr.Code)
+
+	rr = send(s, http.MethodDelete, "", http.Header{"If-Match": {current}})
+	common.CheckResponse(t, "Delete with current tag", http.StatusNoContent, rr.Code)
+
+	rr = send(s, http.MethodPut, `{"ttl": 900}`, http.Header{"If-Match": {"*"}})
+	common.CheckResponse(t, "Update deleted record", http.StatusPreconditionFailed, rr.Code)
+}
+
+func TestSimulatedPolicy(t *testing.T) {
+	s, sim := newSimulatedServer(t)
+	zoneId, err := sim.AddZone("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var other map[string]interface{}
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "www.example.com", "target": "10.0.0.30"}`, &other)
+	common.CheckResponse(t, "Create record without policy", http.StatusCreated, status)
+
+	var networks []map[string]interface{}
+	serve(t, s, http.MethodGet, "/v2/dns/test/networks?hint=10.0.0", "", &networks)
+	networkId := int(networks[0]["id"].(float64))
+
+	engine, err := policy.New([]common.PolicyRule{
+		{Name: "no upper half", Effect: "deny", Actions: []string{"records:*"}, CIDRs: []string{"10.0.0.128/25"}},
+		{Name: "team records", Clients: []string{"admin"}, Actions: []string{"records:*"}, Suffixes: []string{"*.team.example.com"}},
+		{Name: "team ips", Clients: []string{"admin"}, Actions: []string{"ips:*"}, Suffixes: []string{"*.team.example.com"}, CIDRs: []string{"10.0.0.0/24"}},
+		{Name: "team releases", Clients: []string{"admin"}, Actions: []string{policy.ReleaseIp}, CIDRs: []string{"10.0.0.0/25"}},
+	}, nil)
+	common.CheckError(t, "policy.New", nil, err)
+	s.policy = engine
+
+	var team map[string]interface{}
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "www.team.example.com", "target": "10.0.0.31"}`, &team)
+	common.CheckResponse(t, "Create record below suffix", http.StatusCreated, status)
+	teamPath := fmt.Sprintf("/v2/dns/test/records/%d", int(team["id"].(float64)))
+	otherPath := fmt.Sprintf("/v2/dns/test/records/%d", int(other["id"].(float64)))
+
+	status = serve(t, s, http.MethodPut, teamPath, `{"target": "10.0.0.33"}`, nil)
+	common.CheckResponse(t, "Update record below suffix", http.StatusOK, status)
+
+	status = serve(t, s, http.MethodPut, teamPath, `{"target": "10.0.0.200"}`, nil)
+	common.CheckResponse(t, "Update record to a denied address", http.StatusForbidden, status)
+
+	// The record is checked as it would be after the update, including the properties
+	status = serve(t, s, http.MethodPut, teamPath, `{"properties": "addresses=10.0.0.200|"}`, nil)
+	common.CheckResponse(t, "Update addresses through properties", http.StatusForbidden, status)
+	status = serve(t, s, http.MethodPut, teamPath, `{"properties": "absoluteName=www.example.com|"}`, nil)
+	common.CheckResponse(t, "Update name through properties", http.StatusForbidden, status)
+
+	status = serve(t, s, http.MethodPut, otherPath, `{"ttl": 600}`, nil)
+	common.CheckResponse(t, "Update record outside suffix", http.StatusForbidden, status)
+
+	status = serve(t, s, http.MethodDelete, fmt.Sprintf("/v2/dns/test/id/%d", int(other["id"].(float64))), "", nil)
+	common.CheckResponse(t, "Delete record outside suffix by entity id", http.StatusForbidden, status)
+
+	// The import would delete the record outside of the suffix, so none of its changes are applied
+	importPath := fmt.Sprintf("/v2/dns/test/zones/%d/import?apply=true", zoneId)
+	status = serve(t, s, http.MethodPost, importPath, "api.team.example.com. 300 IN A 10.0.0.34", nil)
+	common.CheckResponse(t, "Import with a denied change", http.StatusForbidden, status)
+	var records []map[string]interface{}
+	serve(t, s, http.MethodGet, "/v2/dns/test/records?type=HostRecord&hint=api.team", "", &records)
+	common.CheckResponse(t, "Record of the denied import", 0, len(records))
+
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "api.example.com", "target": "10.0.0.32"}`, nil)
+	common.CheckResponse(t, "Create record outside suffix", http.StatusForbidden, status)
+
+	status = serve(t, s, http.MethodDelete, otherPath, "", nil)
+	common.CheckResponse(t, "Delete record outside suffix", http.StatusForbidden, status)
+
+	body := `{"mac": "00:11:22:33:44:66", "network_id": %d, "hostname": "%s", "reverse": true}`
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/ips", fmt.Sprintf(body, networkId, "db.team.example.com"), nil)
+	common.CheckResponse(t, "Assign ip address in network", http.StatusOK, status)
+
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/ips", fmt.Sprintf(body, networkId, "db.example.com"), nil)
+	common.CheckResponse(t, "Assign ip address outside suffix", http.StatusForbidden, status)
+
+	status = serve(t, s, http.MethodDelete, "/v2/dns/test/ips/10.0.0.200", "", nil)
+	common.CheckResponse(t, "Release ip address outside network", http.StatusForbidden, status)
+
+	status = serve(t, s, http.MethodDelete, "/v2/dns/test/ips/10.0.0.2", "", nil)
+	common.CheckResponse(t, "Release ip address in network", http.StatusNoContent, status)
+}
+
+func TestSimulatedAudit(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+
+	var created map[string]interface{}
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "audit.example.com", "target": "10.0.0.40", "ttl": 300}`, &created)
+	common.CheckResponse(t, "Create host record", http.StatusCreated, status)
+	id := int(created["id"].(float64))
+	path := fmt.Sprintf("/v2/dns/test/records/%d", id)
+
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "audit.example.com", "target": "10.0.0.41"}`, nil)
+	common.CheckResponse(t, "Create duplicate host record", http.StatusConflict, status)
+	status = serve(t, s, http.MethodPut, path, `{"ttl": 600}`, nil)
+	common.CheckResponse(t, "Update host record", http.StatusOK, status)
+	status = serve(t, s, http.MethodDelete, path, "", nil)
+	common.CheckResponse(t, "Delete host record", http.StatusNoContent, status)
+
+	var events []audit.Event
+	status = serve(t, s, http.MethodGet, fmt.Sprintf("/v2/dns/test/audit?entity=%d", id), "", &events)
+	common.CheckResponse(t, "Query audit by entity", http.StatusOK, status)
+	common.CheckResponse(t, "Events of the record", 3, len(events))
+
+	// The most recent event comes first
+	deleted, updated, create := events[0], events[1], events[2]
+	common.CheckResponse(t, "Delete action", audit.Delete, deleted.Action)
+	common.CheckResponse(t, "Delete before", "600", deleted.Before["ttl"])
+	common.CheckResponse(t, "Update action", audit.Update, updated.Action)
+	common.CheckResponse(t, "Update before", "300", updated.Before["ttl"])
+	common.CheckResponse(t, "Update after", "600", updated.After["ttl"])
+	common.CheckResponse(t, "Create entity", "HostRecord", create.EntityType)
+	common.CheckResponse(t, "Create caller", "admin", create.Caller)
+	common.CheckResponse(t, "Create account", "test", create.Account)
+	common.CheckResponse(t, "Create result", audit.Success, create.Result)
+
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/audit?caller=admin&limit=10", "", &events)
+	common.CheckResponse(t, "Query audit by caller", http.StatusOK, status)
+	common.CheckResponse(t, "Events of the caller", 4, len(events))
+	common.CheckResponse(t, "Failed create", audit.Failure, events[2].Result)
+	common.CheckResponse(t, "Failed create status", http.StatusConflict, events[2].Status)
+
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/audit?since="+url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339)), "", &events)
+	common.CheckResponse(t, "Query audit by time", http.StatusOK, status)
+	common.CheckResponse(t, "Future events", 0, len(events))
+
+	status = serve(t, s, http.MethodGet, "/v2/dns/test/audit?since=yesterday", "", nil)
+	common.CheckResponse(t, "Invalid time", http.StatusBadRequest, status)
+}
+
+func TestSimulatedIdempotency(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+
+	var networks []map[string]interface{}
+	serve(t, s, http.MethodGet, "/v2/dns/test/networks?hint=10.0.0", "", &networks)
+	networkId := int(networks[0]["id"].(float64))
+
+	post := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, asAdmin(req))
+		return rr
+	}
+
+	// A retried assignment gets the address of the first request instead of a second address
+	body := fmt.Sprintf(`{"mac": "00:11:22:33:44:88", "network_id": %d, "hostname": "retry.example.com", "reverse": true}`, networkId)
+	first := post("/v2/dns/test/ips", "assign-1", body)
+	common.CheckResponse(t, "Assign ip address", http.StatusOK, first.Code)
+	retried := post("/v2/dns/test/ips", "assign-1", body)
+	common.CheckResponse(t, "Retry assignment", http.StatusOK, retried.Code)
+	common.CheckResponse(t, "Replayed response", first.Body.String(), retried.Body.String())
+	common.CheckResponse(t, "Replayed header", "true", retried.Header().Get("Idempotent-Replayed"))
+
+	status := serve(t, s, http.MethodGet, "/v2/dns/test/ips/10.0.0.3", "", nil)
+	common.CheckResponse(t, "Second address", http.StatusNotFound, status)
+
+	// The key cannot be reused for another request
+	other := fmt.Sprintf(`{"mac": "00:11:22:33:44:99", "network_id": %d, "hostname": "other.example.com", "reverse": true}`, networkId)
+	common.CheckResponse(t, "Reused key", http.StatusConflict, post("/v2/dns/test/ips", "assign-1", other).Code)
+	common.CheckResponse(t, "Reused key on other route", http.StatusConflict, post("/v2/dns/test/records", "assign-1", body).Code)
+	common.CheckResponse(t, "Reused key with other query", http.StatusConflict, post("/v2/dns/test/ips?async=true", "assign-1", body).Code)
+
+	// Client errors are replayed as well, requests without a key are processed again
+	record := `{"type": "HostRecord", "record": "idem.example.com", "target": "10.0.0.50"}`
+	common.CheckResponse(t, "Create record", http.StatusCreated, post("/v2/dns/test/records", "record-1", record).Code)
+	common.CheckResponse(t, "Retry record", http.StatusCreated, post("/v2/dns/test/records", "record-1", record).Code)
+	common.CheckResponse(t, "Duplicate record", http.StatusConflict, post("/v2/dns/test/records", "", record).Code)
+	common.CheckResponse(t, "Duplicate record with key", http.StatusConflict, post("/v2/dns/test/records", "record-2", record).Code)
+	common.CheckResponse(t, "Retry duplicate record", http.StatusConflict, post("/v2/dns/test/records", "record-2", record).Code)
+
+	// The replays are not audited as changes
+	var events []audit.Event
+	serve(t, s, http.MethodGet, "/v2/dns/test/audit?type=IP4Address", "", &events)
+	common.CheckResponse(t, "Audited assignments", 1, len(events))
+
+	// A handler that aborts releases the key, so the request can be retried
+	aborting := s.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	req := asAdmin(httptest.NewRequest(http.MethodPost, "/v2/dns/test/records", strings.NewReader(record)))
+	req.Header.Set("Idempotency-Key", "record-3")
+	func() {
+		defer func() {
+			common.CheckResponse(t, "Aborted handler", http.ErrAbortHandler, recover())
+		}()
+		aborting(httptest.NewRecorder(), req)
+	}()
+	common.CheckResponse(t, "Retry aborted request as duplicate", http.StatusConflict, post("/v2/dns/test/records", "record-3", record).Code)
+	common.CheckResponse(t, "Replay retried request", "true", post("/v2/dns/test/records", "record-3", record).Header().Get("Idempotent-Replayed"))
+}
+
+func TestSimulatedBatch(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+
+	create := func(record, target string) int {
+		var created map[string]interface{}
+		status := serve(t, s, http.MethodPost, "/v2/dns/test/records",
+			fmt.Sprintf(`{"type": "HostRecord", "record": "%s", "target": "%s", "ttl": 300}`, record, target), &created)
+		common.CheckResponse(t, "Create "+record, http.StatusCreated, status)
+		return int(created["id"].(float64))
+	}
+	property := func(id int, key string) string {
+		var record struct {
+			Properties map[string]string `json:"properties"`
+		}
+		if status := serve(t, s, http.MethodGet, fmt.Sprintf("/v2/dns/test/records/%d", id), "", &record); status != http.StatusOK {
+			return ""
+		}
+		return record.Properties[key]
+	}
+	addresses := func(id int) string {
+		return property(id, "addresses")
+	}
+	count := func(hint string) int {
+		var records []map[string]interface{}
+		serve(t, s, http.MethodGet, "/v2/dns/test/records?type=HostRecord&hint="+hint, "", &records)
+		return len(records)
+	}
+	app := create("app.example.com", "10.0.0.10")
+	old := create("old.example.com", "10.0.0.11")
+
+	var response batchResponse
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/records/batch", `{"operations": [
+		{"op": "create", "record": {"type": "HostRecord", "record": "new.example.com", "target": "10.0.0.20"}},
+		{"op": "create", "record": {"type": "HostRecord", "record": "app.example.com", "target": "10.0.0.21"}},
+		{"op": "update", "id": `+strconv.Itoa(app)+`, "record": {"target": "10.0.0.22"}}
+	]}`, &response)
+	common.CheckResponse(t, "Batch with a failure", http.StatusMultiStatus, status)
+	common.CheckResponse(t, "Created record", http.StatusCreated, response.Results[0].Status)
+	common.CheckResponse(t, "Duplicate record", http.StatusConflict, response.Results[1].Status)
+	common.CheckResponse(t, "Updated record", http.StatusOK, response.Results[2].Status)
+	common.CheckResponse(t, "Updated record", "10.0.0.22", addresses(app))
+	common.CheckResponse(t, "New record", 1, count("new"))
+	created := response.Results[0].ID
+
+	// The operations after the first failure are skipped
+	response = batchResponse{}
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records/batch", `{"onFailure": "stop", "concurrency": 1, "operations": [
+		{"op": "update", "id": 999999, "record": {"target": "10.0.0.23"}},
+		{"op": "delete", "id": `+strconv.Itoa(created)+`}
+	]}`, &response)
+	common.CheckResponse(t, "Stopped batch", http.StatusMultiStatus, status)
+	common.CheckResponse(t, "Missing record", http.StatusNotFound, response.Results[0].Status)
+	common.CheckResponse(t, "Skipped delete", true, response.Results[1].Skipped)
+	common.CheckResponse(t, "Skipped delete", 1, count("new"))
+
+	// The successful operations are undone when an operation fails
+	for _, id := range []int{app, old} {
+		status = serve(t, s, http.MethodPut, fmt.Sprintf("/v2/dns/test/records/%d", id), `{"properties": "comments=keep|"}`, nil)
+		common.CheckResponse(t, "Comment record", http.StatusOK, status)
+	}
+	response = batchResponse{}
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records/batch", `{"onFailure": "rollback", "concurrency": 1, "operations": [
+		{"op": "create", "record": {"type": "HostRecord", "record": "temp.example.com", "target": "10.0.0.30"}},
+		{"op": "update", "id": `+strconv.Itoa(app)+`, "record": {"target": "10.0.0.31", "ttl": 600, "properties": "comments=changed|owner=batch|"}},
+		{"op": "delete", "id": `+strconv.Itoa(old)+`},
+		{"op": "create", "record": {"type": "HostRecord", "record": "new.example.com", "target": "10.0.0.32"}},
+		{"op": "delete", "id": `+strconv.Itoa(created)+`}
+	]}`, &response)
+	common.CheckResponse(t, "Rolled back batch", http.StatusMultiStatus, status)
+	common.CheckResponse(t, "Rolled back batch", true, response.RolledBack)
+	common.CheckResponse(t, "Rollback error", "", response.RollbackError)
+	common.CheckResponse(t, "Rolled back create", true, response.Results[0].RolledBack)
+	common.CheckResponse(t, "Failed create", http.StatusConflict, response.Results[3].Status)
+	common.CheckResponse(t, "Skipped delete", true, response.Results[4].Skipped)
+	common.CheckResponse(t, "Removed create", 0, count("temp"))
+	common.CheckResponse(t, "Restored update", "10.0.0.22", addresses(app))
+	common.CheckResponse(t, "Restored ttl", "300", property(app, "ttl"))
+	common.CheckResponse(t, "Restored comments", "keep", property(app, "comments"))
+	common.CheckResponse(t, "Cleared property", "", property(app, "owner"))
+	var restored []struct {
+		Properties map[string]string `json:"properties"`
+	}
+	serve(t, s, http.MethodGet, "/v2/dns/test/records?type=HostRecord&hint=old", "", &restored)
+	common.CheckResponse(t, "Restored delete", 1, len(restored))
+	common.CheckResponse(t, "Restored delete comments", "keep", restored[0].Properties["comments"])
+	common.CheckResponse(t, "Restored delete addresses", "10.0.0.11", restored[0].Properties["addresses"])
+	common.CheckResponse(t, "Kept record", 1, count("new"))
+
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records/batch", `{"operations": [{"op": "rename", "id": 1}]}`, nil)
+	common.CheckResponse(t, "Invalid operation", http.StatusBadRequest, status)
+}
+
+func TestSimulatedJobs(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/dns/test/records/batch?async=true", strings.NewReader(`{"operations": [
+		{"op": "create", "record": {"type": "HostRecord", "record": "one.example.com", "target": "10.0.0.40"}},
+		{"op": "create", "record": {"type": "HostRecord", "record": "two.example.com", "target": "10.0.0.41"}}
+	]}`))
+	rr := httptest.NewRecorder()
+	s.router.ServeHTTP(rr, asAdmin(req))
+	common.CheckResponse(t, "Queue batch", http.StatusAccepted, rr.Code)
+	var queued jobState
+	if err := json.Unmarshal(rr.Body.Bytes(), &queued); err != nil {
+		t.Fatal(err)
+	}
+	location := rr.Header().Get("Location")
+	common.CheckResponse(t, "Job location", "/v2/dns/test/jobs/"+queued.ID, location)
+
+	var state jobState
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		state = jobState{}
+		common.CheckResponse(t, "Get job", http.StatusOK, serve(t, s, http.MethodGet, location, "", &state))
+		if state.Finished != nil {
+			break
+		}
+	}
+	common.CheckResponse(t, "Finished job", JobSucceeded, state.Status)
+	common.CheckResponse(t, "Finished job", http.StatusOK, state.StatusCode)
+	common.CheckResponse(t, "Progress", jobProgress{Total: 2, Completed: 2}, state.Progress)
+	common.CheckResponse(t, "Results", 2, len(state.Results))
+
+	var response batchResponse
+	if err := json.Unmarshal(state.Response, &response); err != nil {
+		t.Fatal(err)
+	}
+	common.CheckResponse(t, "Created record", http.StatusCreated, response.Results[1].Status)
+
+	var jobs []jobState
+	common.CheckResponse(t, "List jobs", http.StatusOK, serve(t, s, http.MethodGet, "/v2/dns/test/jobs", "", &jobs))
+	common.CheckResponse(t, "List jobs", 1, len(jobs))
+	common.CheckResponse(t, "Cancel finished job", http.StatusConflict, serve(t, s, http.MethodDelete, location, "", nil))
+	common.CheckResponse(t, "Unknown job", http.StatusNotFound, serve(t, s, http.MethodGet, "/v2/dns/test/jobs/unknown", "", nil))
+	common.CheckResponse(t, "Invalid async", http.StatusBadRequest, serve(t, s, http.MethodPost, "/v2/dns/test/records/batch?async=maybe", "{}", nil))
+}
+
+func TestSimulatedShutdown(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var err error
+	if s.jobs, err = newJobManager(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: s.router}
+	go srv.Serve(listener)
+	common.CheckResponse(t, "Ready", http.StatusOK, serve(t, s, http.MethodGet, "/v2/dns/readyz", "", nil))
+
+	// A job that is queued when the server stops still runs
+	var queued jobState
+	status := serve(t, s, http.MethodPost, "/v2/dns/test/records/batch?async=true",
+		`{"operations": [{"op": "create", "record": {"type": "HostRecord", "record": "drain.example.com", "target": "10.0.0.60"}}]}`, &queued)
+	common.CheckResponse(t, "Queue batch", http.StatusAccepted, status)
+
+	if err := s.shutdown(srv, cancel, 0, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	common.CheckResponse(t, "Shutting down", http.StatusServiceUnavailable, serve(t, s, http.MethodGet, "/v2/dns/readyz", "", nil))
+	common.CheckResponse(t, "Logged out", "", s.bluecat.token)
+	if _, err := http.Get("http://" + listener.Addr().String() + "/v2/dns/ping"); err == nil {
+		t.Error("expected the listener to be closed")
+	}
+
+	var state jobState
+	serve(t, s, http.MethodGet, "/v2/dns/test/jobs/"+queued.ID, "", &state)
+	common.CheckResponse(t, "Drained job", JobSucceeded, state.Status)
+	status = serve(t, s, http.MethodPost, "/v2/dns/test/records/batch?async=true",
+		`{"operations": [{"op": "delete", "id": 1}]}`, nil)
+	common.CheckResponse(t, "Queue batch after shutdown", http.StatusServiceUnavailable, status)
+}
+
+func TestSimulatedShutdownAbandonsJobs(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var err error
+	if s.jobs, err = newJobManager(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// A job that ignores the interruption does not hold up the shutdown
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	req := asAdmin(mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/v2/dns/test/records/batch", nil), map[string]string{"account": "test"}))
+	stuck, err := s.jobs.submit(req, "test", nil, func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	start := time.Now()
+	s.shutdown(&http.Server{}, cancel, 0, 10*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > jobInterruptGrace+time.Second {
+		t.Errorf("expected the shutdown to abandon the job, it took %s", elapsed)
+	}
+	common.CheckResponse(t, "Abandoned jobs", []string{stuck.snapshot().ID}, s.jobs.unfinished())
+}
+
+func TestSimulatedReadiness(t *testing.T) {
+	s, _ := newSimulatedServer(t)
+	cidrFile := filepath.Join(t.TempDir(), "cidrs.json")
+	if err := os.WriteFile(cidrFile, []byte(`{"test": "10.0.0.0/24"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s.cidrFile = cidrFile
+
+	common.CheckResponse(t, "Alive", http.StatusOK, serve(t, s, http.MethodGet, "/v2/dns/healthz", "", nil))
+
+	var result readiness
+	common.CheckResponse(t, "Ready", http.StatusOK, serve(t, s, http.MethodGet, "/v2/dns/readyz", "", &result))
+	common.CheckResponse(t, "Ready", "ready", result.Status)
+	for _, name := range []string{"bluecatLogin", "view", "configuration", "cidrFile"} {
+		if check, ok := result.Checks[name]; !ok || check.Status != "ok" {
+			t.Errorf("expected check %s to pass, got %+v", name, check)
+		}
+	}
+
+	// The outcome is kept for the interval
+	if err := os.WriteFile(cidrFile, []byte(`not json`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	common.CheckResponse(t, "Cached", http.StatusOK, serve(t, s, http.MethodGet, "/v2/dns/readyz", "", nil))
+
+	tests := []struct {
+		name   string
+		change func()
+		failed string
+	}{
+		{"Invalid CIDR file", func() {}, "cidrFile"},
+		{"Unknown view", func() { s.cidrFile = ""; s.bluecat.viewId = "999999" }, "view"},
+		{"Wrong password", func() { s.bluecat.token, s.bluecat.password = "", "wrong" }, "bluecatLogin"},
+	}
+	for _, tc := range tests {
+		tc.change()
+		s.health.expires = time.Time{}
+		req := httptest.NewRequest(http.MethodGet, "/v2/dns/readyz", nil)
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+		common.CheckResponse(t, tc.name, http.StatusServiceUnavailable, rr.Code)
+
+		result = readiness{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		common.CheckResponse(t, tc.name, "not ready", result.Status)
+		if check := result.Checks[tc.failed]; check == nil || check.Status != "failed" || check.Error == "" {
+			t.Errorf("%s: expected check %s to fail, got %+v", tc.name, tc.failed, check)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/services"
+	"fmt"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// tracerName is the name of the instrumentation that creates the spans of the API
+const tracerName = "dns-api-go"
+
+// propagator reads the W3C trace context of the incoming requests and writes it to the requests sent to bluecat
+var propagator = propagation.TraceContext{}
+
+// tracer returns the tracer of the global tracer provider, which does not record spans unless tracing is configured
+func tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// newTracerProvider creates the tracer provider of the configuration, or returns nil when tracing is not configured.
+// Spans are exported over OTLP/HTTP, or written as JSON to stdout or to a file for environments without a collector.
+func newTracerProvider(c *common.Tracing, version string) (*sdktrace.TracerProvider, error) {
+	if c == nil || c.Exporter == "" {
+		return nil, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch c.Exporter {
+	case "otlp":
+		options := []otlptracehttp.Option{}
+		if c.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(c.Endpoint))
+		}
+		if c.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		if len(c.Headers) > 0 {
+			options = append(options, otlptracehttp.WithHeaders(c.Headers))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "file":
+		if c.File == "" {
+			return nil, fmt.Errorf("'file' must be configured when using the file trace exporter")
+		}
+		f, ferr := os.OpenFile(c.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if ferr != nil {
+			return nil, fmt.Errorf("unable to open trace file: %w", ferr)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter '%s'", c.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to create trace exporter: %w", err)
+	}
+
+	serviceName := c.ServiceName
+	if serviceName == "" {
+		serviceName = tracerName
+	}
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(
+			semconv.ServiceName(serviceName),
+			semconv.ServiceVersion(version),
+		)),
+	), nil
+}
+
+// TracingMiddleware starts a span for each request of the router, as a child of the trace context of the client
+// if it sent a traceparent header. Spans are named after the method and the path template of the route.
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		ctx := propagator.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer().Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			))
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.status))
+		if recorder.status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.status))
+		}
+		if client := clientFrom(ctx); client != nil {
+			span.SetAttributes(attribute.String("dns_api.client", client.name))
+		}
+	})
+}
+
+// startBluecatSpan starts the span of a request to bluecat as a child of the span in the context
+func startBluecatSpan(ctx context.Context, method, route string) (context.Context, trace.Span) {
+	method = strings.ToUpper(method)
+	return tracer().Start(ctx, "bluecat "+method+" "+bluecatRouteLabel(route),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(method),
+			attribute.String("bluecat.route", route),
+		))
+}
+
+// requestServer sends the bluecat requests of a single API request, so that they are traced as part of it
+type requestServer struct {
+	*server
+	ctx context.Context
+}
+
+// MakeRequest sends a request to bluecat in the trace of the API request
+func (rs *requestServer) MakeRequest(method, route, queryParam string, body io.Reader) ([]byte, error) {
+	return rs.makeRequest(rs.ctx, method, route, queryParam, body)
+}
+
+// requesterFor returns the server that sends the bluecat requests of the API request
+func (s *server) requesterFor(r *http.Request) *requestServer {
+	return &requestServer{server: s, ctx: r.Context()}
+}
+
+// servicesFor returns the services that send the bluecat requests of the API request. The zones and records of
+// other providers are served by the services of the server, which do not call bluecat.
+func (s *server) servicesFor(r *http.Request) Services {
+	if s.bluecat == nil {
+		return s.services
+	}
+	scoped := newServices(s.requesterFor(r))
+	if _, ok := s.services.ZoneService.(*services.ZoneService); !ok {
+		scoped.ZoneService = s.services.ZoneService
+	}
+	if _, ok := s.services.RecordService.(*services.RecordService); !ok {
+		scoped.RecordService = s.services.RecordService
+	}
+	return scoped
+}
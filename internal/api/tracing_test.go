@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"dns-api-go/internal/common"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	tp, err := newTracerProvider(nil, "")
+	common.CheckError(t, "No tracing", nil, err)
+	common.CheckResponse(t, "No tracing", (*sdktrace.TracerProvider)(nil), tp)
+
+	for name, c := range map[string]*common.Tracing{
+		"Unsupported exporter":       {Exporter: "zipkin"},
+		"File exporter without file": {Exporter: "file"},
+	} {
+		if _, err := newTracerProvider(c, ""); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	tp, err = newTracerProvider(&common.Tracing{Exporter: "file", File: filepath.Join(t.TempDir(), "traces.json")}, "v1")
+	common.CheckError(t, "File exporter", nil, err)
+	if tp == nil {
+		t.Fatal("expected a tracer provider for the file exporter")
+	}
+	tp.Shutdown(context.Background())
+}
+
+func TestSimulatedTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	s, _ := newSimulatedServer(t)
+
+	// serve sends a request in the trace of a client and returns the span of the request and of the bluecat requests
+	serve := func(traceId, parentId, path, body string) (int, sdktrace.ReadOnlySpan, []sdktrace.ReadOnlySpan) {
+		req := asAdmin(httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		req.Header.Set("traceparent", "00-"+traceId+"-"+parentId+"-01")
+		rr := httptest.NewRecorder()
+		s.router.ServeHTTP(rr, req)
+
+		var request sdktrace.ReadOnlySpan
+		var bluecat []sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID().String() != traceId {
+				continue
+			}
+			if span.SpanKind() == trace.SpanKindServer {
+				request = span
+			} else {
+				bluecat = append(bluecat, span)
+			}
+		}
+		return rr.Code, request, bluecat
+	}
+
+	// The request continues the trace of the client
+	traceId := "4bf92f3577b34da6a3ce929d0e0e4736"
+	parentId := "00f067aa0ba902b7"
+	status, request, bluecat := serve(traceId, parentId, "/v2/dns/test/records",
+		`{"type": "HostRecord", "record": "traced.example.com", "target": "10.0.0.80"}`)
+	common.CheckResponse(t, "Create host record", http.StatusCreated, status)
+	if request == nil {
+		t.Fatal("expected a span of the request in the trace of the client")
+	}
+	common.CheckResponse(t, "Request span", "POST /v2/dns/{account}/records", request.Name())
+	common.CheckResponse(t, "Request span parent", parentId, request.Parent().SpanID().String())
+
+	// The bluecat requests are children of the request span
+	names := map[string]bool{}
+	for _, span := range bluecat {
+		names[span.Name()] = true
+		common.CheckResponse(t, span.Name()+" parent", request.SpanContext().SpanID(), span.Parent().SpanID())
+	}
+	if !names["bluecat POST /addHostRecord"] {
+		t.Errorf("expected a span of the bluecat request, got %v", names)
+	}
+
+	// The requests that undo the changes of a failed request are traced as part of it
+	for i, c := range []struct {
+		name   string
+		path   string
+		body   string
+		status int
+	}{
+		{"mac address", "/v2/dns/test/macs", `{"mac": "00:11:22:33:44:81", "macpool": 999}`, http.StatusBadRequest},
+		{"batch", "/v2/dns/test/records/batch", `{"onFailure": "rollback", "concurrency": 1, "operations": [
+			{"op": "create", "record": {"type": "HostRecord", "record": "rolled.example.com", "target": "10.0.0.81"}},
+			{"op": "create", "record": {"type": "HostRecord", "record": "traced.example.com", "target": "10.0.0.82"}}
+		]}`, http.StatusMultiStatus},
+	} {
+		status, request, bluecat := serve(fmt.Sprintf("5bf92f3577b34da6a3ce929d0e0e474%d", i), parentId, c.path, c.body)
+		common.CheckResponse(t, "Rolled back "+c.name, c.status, status)
+		if request == nil {
+			t.Fatalf("expected a span of the %s request in the trace of the client", c.name)
+		}
+		rolledBack := false
+		for _, span := range bluecat {
+			if span.Name() == "bluecat DELETE /delete" {
+				rolledBack = true
+				common.CheckResponse(t, "Rollback span parent of "+c.name, request.SpanContext().SpanID(), span.Parent().SpanID())
+			}
+		}
+		if !rolledBack {
+			t.Errorf("expected a span of the rollback of the %s", c.name)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"dns-api-go/internal/audit"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/services"
+	"dns-api-go/internal/types"
+	"dns-api-go/logger"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WEBHOOKEVENTS are the types of the events that webhooks can subscribe to
+var WEBHOOKEVENTS = []string{
+	"record.created",
+	"record.updated",
+	"record.deleted",
+	"ip.assigned",
+	"ip.released",
+	"mac.created",
+	"mac.updated",
+	"entity.deleted",
+}
+
+const (
+	webhookWorkers   = 4
+	webhookQueueSize = 1000
+)
+
+// webhookEvent is the JSON body of a webhook request
+type webhookEvent struct {
+	ID         string            `json:"id"`
+	Type       string            `json:"type"`
+	Time       time.Time         `json:"time"`
+	Account    string            `json:"account"`
+	Caller     string            `json:"caller"`
+	EntityType string            `json:"entityType,omitempty"`
+	EntityID   int               `json:"entityId,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Before     map[string]string `json:"before,omitempty"`
+	After      map[string]string `json:"after,omitempty"`
+}
+
+// webhookEventType returns the type of the webhook event for a change of the audit log
+func webhookEventType(event audit.Event) string {
+	kind := "entity"
+	switch {
+	case common.Contains(services.SUPPORTEDRECORDS, event.EntityType):
+		kind = "record"
+	case event.EntityType == types.IP4ADDRESS:
+		switch event.Action {
+		case audit.Create:
+			return "ip.assigned"
+		case audit.Delete:
+			return "ip.released"
+		}
+	case event.EntityType == types.MACADDRESS:
+		kind = "mac"
+	}
+	return kind + "." + event.Action + "d"
+}
+
+// webhookSubscription is a URL subscribed to the events of the listed types, or to every event
+type webhookSubscription struct {
+	url    string
+	secret []byte
+	events []string
+}
+
+// webhookDelivery is an event on its way to a subscription
+type webhookDelivery struct {
+	subscription *webhookSubscription
+	event        webhookEvent
+	body         []byte
+}
+
+// webhookDispatcher sends the change events to the subscribed webhooks in the background.
+// Failed deliveries are retried with backoff and then written to the dead-letter file.
+type webhookDispatcher struct {
+	subscriptions []*webhookSubscription
+	retry         *retryPolicy
+	client        *http.Client
+	deadLetters   string
+	lock          sync.Mutex
+	closed        bool
+	queue         chan webhookDelivery
+	workers       sync.WaitGroup
+}
+
+// newWebhookDispatcher creates a dispatcher for the subscriptions of the configuration and starts its workers.
+// nil is returned when there are no subscriptions.
+func newWebhookDispatcher(c *common.Webhooks) (*webhookDispatcher, error) {
+	if c == nil || len(c.Subscriptions) == 0 {
+		return nil, nil
+	}
+
+	retry, err := newRetryPolicy(c.Retry)
+	if err != nil {
+		return nil, err
+	}
+	timeout := 10 * time.Second
+	if c.Timeout != "" {
+		if timeout, err = time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid webhook timeout '%s'", c.Timeout)
+		}
+	}
+
+	d := &webhookDispatcher{
+		retry:       retry,
+		client:      &http.Client{Timeout: timeout},
+		deadLetters: c.DeadLetterFile,
+		queue:       make(chan webhookDelivery, webhookQueueSize),
+	}
+	for _, w := range c.Subscriptions {
+		if w.URL == "" || w.Secret == "" {
+			return nil, fmt.Errorf("webhooks must have a url and a secret")
+		}
+		for _, event := range w.Events {
+			if !common.Contains(WEBHOOKEVENTS, event) {
+				return nil, fmt.Errorf("unknown webhook event '%s'", event)
+			}
+		}
+		d.subscriptions = append(d.subscriptions, &webhookSubscription{url: w.URL, secret: []byte(w.Secret), events: w.Events})
+	}
+
+	for i := 0; i < webhookWorkers; i++ {
+		d.workers.Add(1)
+		go d.run()
+	}
+	return d, nil
+}
+
+// publish queues a successful change of the audit log for the subscribed webhooks without waiting for the
+// deliveries. Deliveries that do not fit into the queue, or are published after the dispatcher was closed, go
+// straight to the dead-letter file.
+func (d *webhookDispatcher) publish(change audit.Event) {
+	if d == nil {
+		return
+	}
+
+	event := webhookEvent{
+		ID:         newRandomId(),
+		Type:       webhookEventType(change),
+		Time:       change.Time,
+		Account:    change.Account,
+		Caller:     change.Caller,
+		EntityType: change.EntityType,
+		EntityID:   change.EntityID,
+		Name:       change.Name,
+		Before:     change.Before,
+		After:      change.After,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		logger.Error("Unable to encode webhook event", zap.Error(err))
+		return
+	}
+
+	var rejected []webhookDelivery
+	var reason error
+	d.lock.Lock()
+	for _, subscription := range d.subscriptions {
+		if len(subscription.events) > 0 && !common.Contains(subscription.events, event.Type) {
+			continue
+		}
+		delivery := webhookDelivery{subscription: subscription, event: event, body: body}
+		if d.closed {
+			rejected, reason = append(rejected, delivery), fmt.Errorf("webhook dispatcher is closed")
+			continue
+		}
+		select {
+		case d.queue <- delivery:
+		default:
+			rejected, reason = append(rejected, delivery), fmt.Errorf("webhook queue is full")
+		}
+	}
+	d.lock.Unlock()
+
+	for _, delivery := range rejected {
+		d.deadLetter(delivery, 0, reason)
+	}
+}
+
+// close stops accepting events and waits until the queued events are delivered
+func (d *webhookDispatcher) close() {
+	if d == nil {
+		return
+	}
+	d.lock.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+	d.lock.Unlock()
+	d.workers.Wait()
+}
+
+// run delivers the queued events until the queue is closed
+func (d *webhookDispatcher) run() {
+	defer d.workers.Done()
+	for delivery := range d.queue {
+		attempts := 0
+		err := retry(d.retry.attempts, d.retry.doubling, d.retry.sleep, func() error {
+			attempts++
+			return d.deliver(delivery)
+		})
+		if err != nil {
+			d.deadLetter(delivery, attempts, err)
+			continue
+		}
+		logger.Debug("Delivered webhook event",
+			zap.String("url", delivery.subscription.url),
+			zap.String("type", delivery.event.Type),
+			zap.String("id", delivery.event.ID))
+	}
+}
+
+// deliver posts the event to the webhook. The timestamp and the body are signed with the secret of the
+// subscription. Client errors other than timeouts and rate limits are not retried.
+func (d *webhookDispatcher) deliver(delivery webhookDelivery) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, delivery.subscription.secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(delivery.body)
+
+	req, err := http.NewRequest(http.MethodPost, delivery.subscription.url, bytes.NewReader(delivery.body))
+	if err != nil {
+		return stop{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", delivery.event.ID)
+	req.Header.Set("X-Webhook-Event", delivery.event.Type)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	if resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return stop{err}
+	}
+	return err
+}
+
+// deadLetter appends an event that could not be delivered to the dead-letter file, or logs it without one
+func (d *webhookDispatcher) deadLetter(delivery webhookDelivery, attempts int, err error) {
+	logger.Error("Unable to deliver webhook event",
+		zap.String("url", delivery.subscription.url),
+		zap.String("type", delivery.event.Type),
+		zap.String("id", delivery.event.ID),
+		zap.Int("attempts", attempts),
+		zap.Error(err))
+	if d.deadLetters == "" {
+		return
+	}
+
+	line, jsonErr := json.Marshal(struct {
+		Time     time.Time       `json:"time"`
+		URL      string          `json:"url"`
+		Attempts int             `json:"attempts"`
+		Error    string          `json:"error"`
+		Event    json.RawMessage `json:"event"`
+	}{time.Now().UTC(), delivery.subscription.url, attempts, err.Error(), delivery.body})
+	if jsonErr != nil {
+		logger.Error("Unable to encode dead letter", zap.Error(jsonErr))
+		return
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	file, fileErr := os.OpenFile(d.deadLetters, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if fileErr == nil {
+		_, fileErr = file.Write(append(line, '\n'))
+		if closeErr := file.Close(); fileErr == nil {
+			fileErr = closeErr
+		}
+	}
+	if fileErr != nil {
+		logger.Error("Unable to write dead letter", zap.String("file", d.deadLetters), zap.Error(fileErr))
+	}
+}
+
+// newRandomId returns a random id, of a webhook event or a job
+func newRandomId() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(id)
+}
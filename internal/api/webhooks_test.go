@@ -0,0 +1,128 @@
+package api
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"dns-api-go/internal/audit"
+	"dns-api-go/internal/common"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWebhookEventType(t *testing.T) {
+	tests := []struct {
+		event    audit.Event
+		expected string
+	}{
+		{audit.Event{Action: audit.Create, EntityType: "HostRecord"}, "record.created"},
+		{audit.Event{Action: audit.Update, EntityType: "TXTRecord"}, "record.updated"},
+		{audit.Event{Action: audit.Create, EntityType: "IP4Address"}, "ip.assigned"},
+		{audit.Event{Action: audit.Delete, EntityType: "IP4Address"}, "ip.released"},
+		{audit.Event{Action: audit.Update, EntityType: "MACAddress"}, "mac.updated"},
+		{audit.Event{Action: audit.Delete, EntityType: "Zone"}, "entity.deleted"},
+	}
+	for _, tc := range tests {
+		common.CheckResponse(t, tc.expected, tc.expected, webhookEventType(tc.event))
+		if !common.Contains(WEBHOOKEVENTS, tc.expected) {
+			t.Errorf("%s is not a known webhook event", tc.expected)
+		}
+	}
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	var lock sync.Mutex
+	received := map[string][]webhookEvent{}
+	failures := 2
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("s3cret"))
+		mac.Write([]byte(r.Header.Get("X-Webhook-Timestamp") + "."))
+		mac.Write(body)
+		if r.Header.Get("X-Webhook-Signature") != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("invalid signature for %s", body)
+		}
+
+		switch r.URL.Path {
+		case "/flaky":
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		var event webhookEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Error(err)
+		}
+		common.CheckResponse(t, "Event header", event.Type, r.Header.Get("X-Webhook-Event"))
+		received[r.URL.Path] = append(received[r.URL.Path], event)
+	}))
+	defer ts.Close()
+
+	deadLetters := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+	d, err := newWebhookDispatcher(&common.Webhooks{
+		Subscriptions: []common.Webhook{
+			{URL: ts.URL + "/cmdb", Secret: "s3cret"},
+			{URL: ts.URL + "/flaky", Secret: "s3cret", Events: []string{"ip.assigned"}},
+			{URL: ts.URL + "/gone", Secret: "s3cret", Events: []string{"record.deleted"}},
+		},
+		Retry:          &common.Retry{Attempts: 3, Sleep: "1ms"},
+		DeadLetterFile: deadLetters,
+	})
+	common.CheckError(t, "newWebhookDispatcher", nil, err)
+
+	d.publish(audit.Event{Action: audit.Create, EntityType: "IP4Address", EntityID: 7, Name: "10.0.0.7", Caller: "jdoe"})
+	d.publish(audit.Event{Action: audit.Delete, EntityType: "HostRecord", EntityID: 8, Before: map[string]string{"ttl": "300"}})
+	d.close()
+
+	// Every event reaches the subscription without a filter, a flaky webhook gets its event after retries
+	common.CheckResponse(t, "Events of the cmdb", 2, len(received["/cmdb"]))
+	common.CheckResponse(t, "Events of the flaky webhook", 1, len(received["/flaky"]))
+	ip := received["/flaky"][0]
+	common.CheckResponse(t, "Assigned ip", "10.0.0.7", ip.Name)
+	common.CheckResponse(t, "Caller", "jdoe", ip.Caller)
+
+	// Events published after the dispatcher was closed go to the dead-letter file
+	d.publish(audit.Event{Action: audit.Update, EntityType: "HostRecord", EntityID: 9})
+	d.close()
+
+	// Client errors are not retried and end up in the dead-letter file
+	file, err := os.Open(deadLetters)
+	common.CheckError(t, "Open dead letters", nil, err)
+	defer file.Close()
+	var letters []map[string]interface{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var letter map[string]interface{}
+		common.CheckError(t, "Decode dead letter", nil, json.Unmarshal(scanner.Bytes(), &letter))
+		letters = append(letters, letter)
+	}
+	common.CheckResponse(t, "Dead letters", 2, len(letters))
+	common.CheckResponse(t, "Dead letter attempts", float64(1), letters[0]["attempts"])
+	common.CheckResponse(t, "Dead letter event", "record.deleted", letters[0]["event"].(map[string]interface{})["type"])
+	common.CheckResponse(t, "Closed dead letter attempts", float64(0), letters[1]["attempts"])
+	common.CheckResponse(t, "Closed dead letter error", "webhook dispatcher is closed", letters[1]["error"])
+
+	for _, c := range []*common.Webhooks{
+		{Subscriptions: []common.Webhook{{URL: ts.URL}}},
+		{Subscriptions: []common.Webhook{{URL: ts.URL, Secret: "s3cret", Events: []string{"zone.created"}}}},
+	} {
+		if _, err := newWebhookDispatcher(c); err == nil {
+			t.Errorf("expected an error for webhooks %+v", *c)
+		}
+	}
+}
@@ -1,13 +1,318 @@
 package api
 
 import (
+	"bytes"
+	"dns-api-go/internal/audit"
+	"dns-api-go/internal/interfaces"
+	"dns-api-go/internal/policy"
+	"dns-api-go/internal/services"
+	"dns-api-go/internal/types"
+	"dns-api-go/internal/zonefile"
+	"dns-api-go/logger"
+	"fmt"
+	"go.uber.org/zap"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
+// maxZoneFileSize is the largest zone file accepted for an import
+const maxZoneFileSize = 10 << 20
+
 func (s *server) GetZonesHandler() http.HandlerFunc {
-	return s.HandleGetEntitiesByHintReq(s.services.ZoneService)
+	return s.HandleGetEntitiesByHintReq(func(svc Services) interfaces.EntitiesByHintLister { return svc.ZoneService })
 }
 
 func (s *server) GetZoneHandler() http.HandlerFunc {
-	return s.HandleGetEntityReq(s.services.ZoneService)
+	return s.HandleGetEntityReq(func(svc Services) interfaces.EntityGetter { return svc.ZoneService })
+}
+
+// ExportZoneHandler renders every record of a zone in BIND zone file format
+func (s *server) ExportZoneHandler(w http.ResponseWriter, r *http.Request) {
+	logger.Info("ExportZoneHandler started")
+
+	// Parse the zone id from the request
+	params, err := parseEntityParams(r)
+	if err != nil {
+		logger.Warn("Invalid request parameters", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Get the zone and its records
+	zone, err := s.servicesFor(r).ZoneService.GetEntity(params.ID, false)
+	if err != nil {
+		handleZoneError(w, params.ID, err)
+		return
+	}
+	entities, err := s.servicesFor(r).ZoneService.GetZoneRecords(params.ID)
+	if err != nil {
+		handleZoneError(w, params.ID, err)
+		return
+	}
+
+	// Convert the entities to resource records, skipping the ones that cannot be represented
+	var records []zonefile.Record
+	for _, entity := range *entities {
+		converted, err := zonefile.FromEntity(entity)
+		if err != nil {
+			logger.Warn("Skipping record in zone export", zap.Int("recordId", entity.ID), zap.Error(err))
+			continue
+		}
+		records = append(records, converted...)
+	}
+
+	origin := zone.Properties["absoluteName"]
+	if origin == "" {
+		origin = zone.Name
+	}
+
+	var buf bytes.Buffer
+	if err := zonefile.Render(&buf, origin, records); err != nil {
+		logger.Error("Error rendering zone file", zap.Int("zoneId", params.ID), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	logger.Info("ExportZoneHandler successful", zap.String("zone", origin), zap.Int("count", len(records)))
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
+// ImportZoneHandler compares a BIND zone file with the records of a zone and returns the plan of changes.
+// The plan is only applied through the record service when the apply query parameter is true.
+func (s *server) ImportZoneHandler(w http.ResponseWriter, r *http.Request) {
+	logger.Info("ImportZoneHandler started")
+
+	// Parse the zone id and the apply flag from the request
+	params, err := parseEntityParams(r)
+	if err != nil {
+		logger.Warn("Invalid request parameters", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	apply := false
+	if applyStr := r.URL.Query().Get("apply"); applyStr != "" {
+		if apply, err = strconv.ParseBool(applyStr); err != nil {
+			http.Error(w, "invalid apply value", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Get the zone and its records
+	zone, err := s.servicesFor(r).ZoneService.GetEntity(params.ID, false)
+	if err != nil {
+		handleZoneError(w, params.ID, err)
+		return
+	}
+	entities, err := s.servicesFor(r).ZoneService.GetZoneRecords(params.ID)
+	if err != nil {
+		handleZoneError(w, params.ID, err)
+		return
+	}
+
+	origin := zone.Properties["absoluteName"]
+	if origin == "" {
+		origin = zone.Name
+	}
+
+	// Parse the zone file from the request body and compare it with the zone
+	records, err := zonefile.Parse(http.MaxBytesReader(w, r.Body, maxZoneFileSize), origin)
+	if err != nil {
+		logger.Warn("Invalid zone file", zap.Error(err))
+		http.Error(w, fmt.Sprintf("invalid zone file: %v", err), http.StatusBadRequest)
+		return
+	}
+	plan, err := zonefile.NewPlan(origin, *entities, records)
+	if err != nil {
+		logger.Warn("Invalid zone file", zap.Error(err))
+		http.Error(w, fmt.Sprintf("invalid zone file: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if !apply {
+		logger.Info("ImportZoneHandler successful", zap.String("zone", origin), zap.Int("changes", len(plan.Changes)))
+		s.respond(w, plan, http.StatusOK)
+		return
+	}
+
+	// Check that the client may make every change of the plan before any of them is applied
+	if s.policy != nil {
+		for i := range plan.Changes {
+			for _, req := range zoneChangePolicyRequests(&plan.Changes[i]) {
+				if !s.authorize(w, r, req) {
+					return
+				}
+			}
+		}
+	}
+
+	// Apply the changes in order and stop at the first failure. The changes that were already applied are rolled back,
+	// so the zone is left as it was and the error of the failed change reports the outcome of the rollback.
+	viewId, err := s.viewId()
+	if err != nil {
+		logger.Error("Error converting viewId to int", zap.Error(err))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	j := jobFrom(r.Context())
+	j.setTotal(len(plan.Changes))
+	tx := services.NewTransaction("import of zone " + origin)
+	recordService := s.servicesFor(r).RecordService
+	for i := range plan.Changes {
+		change := &plan.Changes[i]
+		// A cancelled request fails the import before the next change
+		err := r.Context().Err()
+		if err == nil {
+			err = s.applyZoneChange(tx, recordService, change, viewId)
+		}
+		if err != nil {
+			err = tx.Fail(err)
+			logger.Error("Error applying zone import",
+				zap.String("zone", origin),
+				zap.String("action", change.Action),
+				zap.String("name", change.Name),
+				zap.Error(err))
+			change.Error = err.Error()
+			j.itemDone(change, true)
+			event := zoneChangeEvent(change)
+			event.Status = http.StatusInternalServerError
+			event.Error = change.Error
+			s.recordAudit(r, event)
+			s.respond(w, plan, http.StatusInternalServerError)
+			return
+		}
+		j.itemDone(change, false)
+	}
+	plan.Applied = true
+
+	// Record each applied change in the audit log
+	for i := range plan.Changes {
+		event := zoneChangeEvent(&plan.Changes[i])
+		event.Status = http.StatusOK
+		s.recordAudit(r, event)
+	}
+
+	logger.Info("ImportZoneHandler successful", zap.String("zone", origin), zap.Int("applied", len(plan.Changes)))
+	s.respond(w, plan, http.StatusOK)
+}
+
+// zoneChangePolicyRequests describes a change of an import plan for the policy. Updates are checked with the records
+// before and after the change, and host records with their addresses.
+func zoneChangePolicyRequests(change *zonefile.Change) []policy.Request {
+	action := map[string]string{
+		zonefile.ActionCreate: policy.CreateRecord,
+		zonefile.ActionUpdate: policy.UpdateRecord,
+		zonefile.ActionDelete: policy.DeleteRecord,
+	}[change.Action]
+
+	var requests []policy.Request
+	for _, records := range [][]zonefile.Record{change.Before, change.After} {
+		if len(records) == 0 {
+			continue
+		}
+		req := policy.Request{Action: action, Name: change.Name, RecordType: change.RecordType}
+		if change.RecordType == types.HOSTRECORD {
+			for _, record := range records {
+				req.Networks = append(req.Networks, record.Data)
+			}
+		}
+		requests = append(requests, req)
+	}
+	return requests
+}
+
+// applyZoneChange runs a single change of an import plan through the record service and registers the change
+// that undoes it with the transaction
+func (s *server) applyZoneChange(tx *services.Transaction, recordService services.RecordEntityService, change *zonefile.Change, viewId int) error {
+	// The reverse of a change restores the records the change started from
+	reverse := zonefile.Change{RecordType: change.RecordType, Name: change.Name, After: change.Before}
+
+	switch change.Action {
+	case zonefile.ActionDelete:
+		if err := recordService.DeleteEntity(change.RecordId); err != nil {
+			return err
+		}
+		reverse.Action = zonefile.ActionCreate
+		tx.OnRollback(services.RollbackTask(func() error {
+			parameters, err := reverse.Parameters()
+			if err != nil {
+				return err
+			}
+			_, err = recordService.CreateRecord(reverse.RecordType, parameters, viewId)
+			return err
+		}))
+		return nil
+	case zonefile.ActionCreate:
+		parameters, err := change.Parameters()
+		if err != nil {
+			return err
+		}
+		entity, err := recordService.CreateRecord(change.RecordType, parameters, viewId)
+		if err != nil {
+			return err
+		}
+		change.RecordId = entity.ID
+		tx.OnRollback(services.RollbackTask(func() error {
+			return recordService.DeleteEntity(entity.ID)
+		}))
+		return nil
+	default:
+		parameters, err := change.Parameters()
+		if err != nil {
+			return err
+		}
+		if _, err := recordService.UpdateRecord(change.RecordId, parameters); err != nil {
+			return err
+		}
+		reverse.Action = zonefile.ActionUpdate
+		tx.OnRollback(services.RollbackTask(func() error {
+			parameters, err := reverse.Parameters()
+			if err != nil {
+				return err
+			}
+			_, err = recordService.UpdateRecord(change.RecordId, parameters)
+			return err
+		}))
+		return nil
+	}
+}
+
+// zoneChangeEvent describes a change of a zone import for the audit log. The records before and after the change
+// are listed by their TTL and data.
+func zoneChangeEvent(change *zonefile.Change) audit.Event {
+	properties := func(records []zonefile.Record) map[string]string {
+		if len(records) == 0 {
+			return nil
+		}
+		data := make([]string, 0, len(records))
+		for _, record := range records {
+			data = append(data, record.Data)
+		}
+		return map[string]string{"ttl": strconv.Itoa(records[0].TTL), "data": strings.Join(data, ",")}
+	}
+	return audit.Event{
+		Action:     change.Action,
+		EntityType: change.RecordType,
+		EntityID:   change.RecordId,
+		Name:       change.Name,
+		Before:     properties(change.Before),
+		After:      properties(change.After),
+	}
+}
+
+// handleZoneError sets the HTTP response for errors returned by the zone service
+func handleZoneError(w http.ResponseWriter, zoneId int, err error) {
+	logger.Error("Error retrieving zone", zap.Int("zoneId", zoneId), zap.Error(err))
+
+	// Determine the type of error and set the HTTP response accordingly
+	switch e := err.(type) {
+	case *services.ErrEntityNotFound:
+		http.Error(w, e.Error(), http.StatusNotFound)
+	case *services.ErrEntityTypeMismatch:
+		http.Error(w, e.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,106 @@
+// Package audit records who changed which entities through the API.
+package audit
+
+import (
+	"time"
+)
+
+// The actions of audit events
+const (
+	Create = "create"
+	Update = "update"
+	Delete = "delete"
+)
+
+// The results of audit events
+const (
+	Success = "success"
+	Failure = "failure"
+)
+
+// Event is the record of a change, or of an attempted change, made through the API
+type Event struct {
+	Time       time.Time         `json:"time"`
+	Caller     string            `json:"caller"`
+	Account    string            `json:"account"`
+	Action     string            `json:"action"`
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	EntityType string            `json:"entityType,omitempty"`
+	EntityID   int               `json:"entityId,omitempty"`
+	Name       string            `json:"name,omitempty"`
+	Before     map[string]string `json:"before,omitempty"`
+	After      map[string]string `json:"after,omitempty"`
+	Status     int               `json:"status"`
+	Result     string            `json:"result"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Sink receives the audit events
+type Sink interface {
+	Record(event Event) error
+}
+
+// Querier is implemented by sinks that can look up the events they recorded
+type Querier interface {
+	Query(filter Filter) ([]Event, error)
+}
+
+// Filter selects audit events. Zero values match every event.
+type Filter struct {
+	Account    string
+	EntityID   int
+	EntityType string
+	Caller     string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+}
+
+// Matches checks whether the event meets the conditions of the filter
+func (f Filter) Matches(e Event) bool {
+	switch {
+	case f.Account != "" && e.Account != f.Account:
+		return false
+	case f.EntityID != 0 && e.EntityID != f.EntityID:
+		return false
+	case f.EntityType != "" && e.EntityType != f.EntityType:
+		return false
+	case f.Caller != "" && e.Caller != f.Caller:
+		return false
+	case !f.Since.IsZero() && e.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && e.Time.After(f.Until):
+		return false
+	}
+	return true
+}
+
+// recent collects the most recent matching events of a filter from events that are added in chronological order
+type recent struct {
+	filter Filter
+	events []Event
+}
+
+func (r *recent) add(e Event) {
+	if !r.filter.Matches(e) {
+		return
+	}
+	r.events = append(r.events, e)
+	if r.filter.Limit > 0 && len(r.events) > 2*r.filter.Limit {
+		r.events = append(r.events[:0], r.events[len(r.events)-r.filter.Limit:]...)
+	}
+}
+
+// result returns the collected events, most recent first
+func (r *recent) result() []Event {
+	events := r.events
+	if r.filter.Limit > 0 && len(events) > r.filter.Limit {
+		events = events[len(events)-r.filter.Limit:]
+	}
+	result := make([]Event, 0, len(events))
+	for i := len(events) - 1; i >= 0; i-- {
+		result = append(result, events[i])
+	}
+	return result
+}
@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileSink appends the audit events to a file as JSON lines
+type FileSink struct {
+	path   string
+	lock   sync.Mutex
+	file   *os.File
+	closed bool
+}
+
+// NewFileSink opens the file for appending, creating it if necessary
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open audit log: %v", err)
+	}
+	return &FileSink{path: path, file: file}, nil
+}
+
+// Record appends the event to the file, events recorded after the sink was closed are rejected
+func (s *FileSink) Record(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return fmt.Errorf("audit log is closed")
+	}
+	_, err = s.file.Write(line)
+	return err
+}
+
+// Query reads the file and returns the most recent events that match the filter.
+// The file is read through its own handle, so that events are recorded while it is read, and only up to its size
+// when the query started. Lines that cannot be decoded, e.g. a line cut short by a crash, are skipped.
+func (s *FileSink) Query(filter Filter) ([]Event, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	events := &recent{filter: filter}
+	scanner := bufio.NewScanner(io.LimitReader(file, info.Size()))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		events.add(event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return events.result(), nil
+}
+
+// Close closes the file, closing it again does nothing
+func (s *FileSink) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.file.Close()
+}
+
+// MemorySink keeps the most recent audit events in memory, it is used when no audit log is configured
+type MemorySink struct {
+	lock   sync.Mutex
+	size   int
+	events []Event
+}
+
+// NewMemorySink creates a sink that keeps up to size events
+func NewMemorySink(size int) *MemorySink {
+	return &MemorySink{size: size}
+}
+
+// Record keeps the event and drops the oldest event when the sink is full
+func (s *MemorySink) Record(event Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.events = append(s.events, event)
+	if len(s.events) > s.size {
+		s.events = append(s.events[:0], s.events[len(s.events)-s.size:]...)
+	}
+	return nil
+}
+
+// Query returns the most recent events that match the filter
+func (s *MemorySink) Query(filter Filter) ([]Event, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	events := &recent{filter: filter}
+	for _, event := range s.events {
+		events.add(event)
+	}
+	return events.result(), nil
+}
@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"dns-api-go/internal/common"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	file, err := NewFileSink(path)
+	common.CheckError(t, "NewFileSink", nil, err)
+	defer file.Close()
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Time: start, Caller: "jdoe", Action: Create, EntityType: "HostRecord", EntityID: 10, Result: Success},
+		{Time: start.Add(time.Minute), Caller: "ci", Action: Update, EntityType: "HostRecord", EntityID: 10, Before: map[string]string{"ttl": "300"}, After: map[string]string{"ttl": "600"}, Result: Success},
+		{Time: start.Add(2 * time.Minute), Caller: "jdoe", Account: "other", Action: Delete, EntityType: "MACAddress", EntityID: 20, Status: 404, Result: Failure},
+		{Time: start.Add(3 * time.Minute), Caller: "jdoe", Action: Delete, EntityType: "HostRecord", EntityID: 10, Result: Success},
+	}
+
+	for name, sink := range map[string]interface {
+		Sink
+		Querier
+	}{"file": file, "memory": NewMemorySink(3)} {
+		for _, event := range events {
+			common.CheckError(t, name+" Record", nil, sink.Record(event))
+		}
+
+		// The memory sink only keeps the last three events
+		tests := []struct {
+			filter         Filter
+			expectedFile   int
+			expectedMemory int
+		}{
+			{Filter{EntityID: 10}, 3, 2},
+			{Filter{Caller: "jdoe", Limit: 1}, 1, 1},
+			{Filter{Since: start.Add(90 * time.Second), Until: start.Add(150 * time.Second)}, 1, 1},
+			{Filter{EntityType: "MACAddress"}, 1, 1},
+			{Filter{Account: "other"}, 1, 1},
+		}
+		for _, tc := range tests {
+			expected := tc.expectedFile
+			if name == "memory" {
+				expected = tc.expectedMemory
+			}
+			result, err := sink.Query(tc.filter)
+			common.CheckError(t, name+" Query", nil, err)
+			common.CheckResponse(t, fmt.Sprintf("%s query %+v", name, tc.filter), expected, len(result))
+		}
+
+		// The most recent events come first
+		result, err := sink.Query(Filter{Caller: "jdoe"})
+		common.CheckError(t, name+" Query", nil, err)
+		common.CheckResponse(t, name+" Most recent", Delete, result[0].Action)
+		common.CheckResponse(t, name+" Most recent", "HostRecord", result[0].EntityType)
+	}
+
+	// Changes are kept in the file across restarts, and broken lines are skipped
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	common.CheckError(t, "OpenFile", nil, err)
+	f.WriteString(`{"time": "2024-03-01T12:10:00Z", "caller": "jd`)
+	f.Close()
+	reopened, err := NewFileSink(path)
+	common.CheckError(t, "NewFileSink", nil, err)
+	defer reopened.Close()
+	result, err := reopened.Query(Filter{})
+	common.CheckError(t, "Query", nil, err)
+	common.CheckResponse(t, "Events after restart", 4, len(result))
+	common.CheckResponse(t, "Before", map[string]string{"ttl": "300"}, result[2].Before)
+
+	// Queries read the file while events are recorded
+	busy, err := NewFileSink(filepath.Join(t.TempDir(), "busy.log"))
+	common.CheckError(t, "NewFileSink", nil, err)
+	defer busy.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			busy.Record(Event{Time: start, Caller: "ci", Action: Create, Result: Success})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		_, err := busy.Query(Filter{Caller: "ci"})
+		common.CheckError(t, "Query while recording", nil, err)
+	}
+	<-done
+	result, err = busy.Query(Filter{Caller: "ci"})
+	common.CheckError(t, "Query", nil, err)
+	common.CheckResponse(t, "Events recorded while querying", 50, len(result))
+
+	// Events recorded after the sink was closed are rejected, and the file can still be queried
+	common.CheckError(t, "Close", nil, busy.Close())
+	if err := busy.Record(Event{Time: start, Caller: "ci", Action: Create, Result: Success}); err == nil {
+		t.Error("Record after Close: expected an error")
+	}
+	result, err = busy.Query(Filter{Caller: "ci"})
+	common.CheckError(t, "Query after Close", nil, err)
+	common.CheckResponse(t, "Events after Close", 50, len(result))
+}
@@ -0,0 +1,56 @@
+package bluecat
+
+import (
+	"dns-api-go/internal/models"
+	"net/http"
+)
+
+// GetIP4Address returns the ipv4 address entity of the container with the address
+func (c *Client) GetIP4Address(containerId int, address string) (*models.Entity, error) {
+	params := newParams(map[string]int{"containerId": containerId})
+	params.Set("address", address)
+	return c.getEntity("/getIP4Address", params)
+}
+
+// AssignNextAvailableIP4Address assigns the next free address of the network to the mac address.
+// hostInfo is "hostname,viewId,reverseFlag,sameAsZoneFlag", or empty to assign the address without a host record.
+func (c *Client) AssignNextAvailableIP4Address(configurationId int, parentId int, action string, macAddress string, hostInfo string, properties map[string]string) (*models.Entity, error) {
+	params := newParams(map[string]int{"configurationId": configurationId, "parentId": parentId})
+	params.Set("action", action)
+	params.Set("macAddress", macAddress)
+	params.Set("hostInfo", hostInfo)
+	params.Set("properties", joinOptions(properties))
+
+	var bluecatEntity models.BluecatEntity
+	if err := c.call(http.MethodPost, "/assignNextAvailableIP4Address", params, nil, &bluecatEntity); err != nil {
+		return nil, err
+	}
+	if bluecatEntity.IsEmpty() {
+		return nil, ErrNotFound
+	}
+
+	entity := bluecatEntity.ToEntity()
+	return &entity, nil
+}
+
+// GetMACAddress returns the mac address entity of the configuration with the address
+func (c *Client) GetMACAddress(configurationId int, macAddress string) (*models.Entity, error) {
+	params := newParams(map[string]int{"configurationId": configurationId})
+	params.Set("macAddress", macAddress)
+	return c.getEntity("/getMACAddress", params)
+}
+
+// AddMACAddress adds a mac address to the configuration and returns its id
+func (c *Client) AddMACAddress(configurationId int, macAddress string, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"configurationId": configurationId})
+	params.Set("macAddress", macAddress)
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addMACAddress", params)
+}
+
+// AssociateMACAddressWithPool adds a mac address to a mac pool, the mac address is created if it does not exist
+func (c *Client) AssociateMACAddressWithPool(configurationId int, macAddress string, poolId int) error {
+	params := newParams(map[string]int{"configurationId": configurationId, "poolId": poolId})
+	params.Set("macAddress", macAddress)
+	return c.call(http.MethodPost, "/associateMACAddressWithPool", params, nil, nil)
+}
@@ -0,0 +1,170 @@
+// Package bluecat is a typed client of the BlueCat Address Manager REST APIs, the legacy v1 API and the
+// RESTful v2 API. It builds the API calls, decodes their responses and classifies their errors,
+// while sending the requests is left to a Requester.
+package bluecat
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"dns-api-go/logger"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Requester sends a request to the API and returns the body of a successful response
+type Requester interface {
+	MakeRequest(method, route, queryParam string, body io.Reader) ([]byte, error)
+}
+
+// The versions of the Address Manager API
+const (
+	V1 = "v1"
+	V2 = "v2"
+)
+
+// Versioned is implemented by requesters that send their requests to a specific version of the API,
+// requesters that do not implement it talk to the legacy v1 API
+type Versioned interface {
+	APIVersion() string
+}
+
+// API is the set of Address Manager calls used by the services.
+// Both versions of the API return entities in the shape of the legacy API, e.g. with the v1 type names and
+// properties, so that callers do not depend on the version of BAM.
+type API interface {
+	GetEntityById(id int, includeHA bool) (*models.Entity, error)
+	GetParent(entityId int) (*models.Entity, error)
+	GetEntityByName(parentId int, name string, entityType string, includeHA bool) (*models.Entity, error)
+	GetEntities(parentId int, entityType string, start int, count int, includeHA bool) ([]models.Entity, error)
+	SearchObjectByTypes(keyword string, types []string, start int, count int, includeHA bool) ([]models.Entity, error)
+	CustomSearch(objectType string, filters map[string]string, options []string, start int, count int) ([]models.Entity, error)
+	GetZonesByHint(containerId int, start int, count int, options map[string]string) ([]models.Entity, error)
+	GetIP4NetworksByHint(containerId int, start int, count int, options map[string]string) ([]models.Entity, error)
+	GetHostRecordsByHint(start int, count int, options map[string]string) ([]models.Entity, error)
+	GetAliasesByHint(start int, count int, options map[string]string) ([]models.Entity, error)
+	Update(entity *models.Entity) error
+	Delete(objectId int) error
+	GetSystemInfo() (map[string]string, error)
+
+	GetIP4Address(containerId int, address string) (*models.Entity, error)
+	AssignNextAvailableIP4Address(configurationId int, parentId int, action string, macAddress string, hostInfo string, properties map[string]string) (*models.Entity, error)
+	GetMACAddress(configurationId int, macAddress string) (*models.Entity, error)
+	AddMACAddress(configurationId int, macAddress string, properties map[string]string) (int, error)
+	AssociateMACAddressWithPool(configurationId int, macAddress string, poolId int) error
+
+	AddHostRecord(viewId int, absoluteName string, addresses []string, ttl int, properties map[string]string) (int, error)
+	AddAliasRecord(viewId int, absoluteName string, linkedRecordName string, ttl int, properties map[string]string) (int, error)
+	AddExternalHostRecord(viewId int, name string, properties map[string]string) (int, error)
+	AddMXRecord(viewId int, absoluteName string, linkedRecordName string, priority int, ttl int, properties map[string]string) (int, error)
+	AddTXTRecord(viewId int, absoluteName string, txt string, ttl int, properties map[string]string) (int, error)
+	AddSRVRecord(viewId int, absoluteName string, linkedRecordName string, priority int, weight int, port int, ttl int, properties map[string]string) (int, error)
+	AddGenericRecord(viewId int, absoluteName string, recordType string, rdata string, ttl int, properties map[string]string) (int, error)
+	AddHINFORecord(viewId int, absoluteName string, cpu string, os string, ttl int, properties map[string]string) (int, error)
+}
+
+// Client calls the legacy v1 API through a Requester
+type Client struct {
+	requester Requester
+}
+
+// NewClient creates a client of the API version of the requester that sends its requests with the requester
+func NewClient(requester Requester) API {
+	if v, ok := requester.(Versioned); ok && v.APIVersion() == V2 {
+		return &V2Client{requester: requester}
+	}
+	return &Client{requester: requester}
+}
+
+// AssignsHostRecords reports whether the API of the requester creates the host record of an assigned address along
+// with the address. The v2 API does not, so the host record has to be added after the address.
+func AssignsHostRecords(requester Requester) bool {
+	v, ok := requester.(Versioned)
+	return !ok || v.APIVersion() != V2
+}
+
+// call sends a request with the query parameters and decodes the JSON response into out, unless out is nil
+func (c *Client) call(method, route string, params url.Values, body io.Reader, out interface{}) error {
+	resp, err := c.requester.MakeRequest(method, route, params.Encode(), body)
+	if err != nil {
+		return err
+	}
+	return decode(route, resp, out)
+}
+
+// decode decodes the JSON response of a route into out, unless out is nil
+func decode(route string, resp []byte, out interface{}) error {
+	if out == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(resp, out); err != nil {
+		logger.Error("Error unmarshalling response", zap.String("route", route), zap.Error(err))
+		return &DecodeError{Route: route, Err: err}
+	}
+	return nil
+}
+
+// getEntity calls a route that returns a single entity, an empty entity is reported as ErrNotFound
+func (c *Client) getEntity(route string, params url.Values) (*models.Entity, error) {
+	var bluecatEntity models.BluecatEntity
+	if err := c.call(http.MethodGet, route, params, nil, &bluecatEntity); err != nil {
+		return nil, err
+	}
+	if bluecatEntity.IsEmpty() {
+		return nil, ErrNotFound
+	}
+
+	entity := bluecatEntity.ToEntity()
+	return &entity, nil
+}
+
+// getEntities calls a route that returns a list of entities
+func (c *Client) getEntities(route string, params url.Values) ([]models.Entity, error) {
+	var entitiesResp []models.BluecatEntity
+	if err := c.call(http.MethodGet, route, params, nil, &entitiesResp); err != nil {
+		return nil, err
+	}
+	return models.ConvertToEntities(entitiesResp), nil
+}
+
+// add calls a route that creates an entity and returns its id
+func (c *Client) add(route string, params url.Values) (int, error) {
+	var objectId int
+	if err := c.call(http.MethodPost, route, params, nil, &objectId); err != nil {
+		return -1, err
+	}
+	return objectId, nil
+}
+
+// newParams returns query parameters with the integer values set
+func newParams(ints map[string]int) url.Values {
+	params := url.Values{}
+	for key, value := range ints {
+		params.Set(key, strconv.Itoa(value))
+	}
+	return params
+}
+
+// joinOptions formats options or properties the way the API expects them, e.g. "hint=abc|overrideType=HostRecord"
+func joinOptions(options map[string]string) string {
+	return common.ConvertToSeparatedString(options, "|")
+}
+
+// DecodeError is returned when the response of a call cannot be decoded
+type DecodeError struct {
+	Route string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("error decoding response of %s: %v", e.Route, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,106 @@
+package bluecat
+
+import (
+	"dns-api-go/internal/common"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Setup phase: Initialize the logger
+	common.SetupLogger()
+
+	// Run the tests
+	code := m.Run()
+
+	os.Exit(code)
+}
+
+// requester records the request and returns a canned response
+type requester struct {
+	method, route string
+	query         url.Values
+	resp          []byte
+	err           error
+}
+
+func (r *requester) MakeRequest(method, route, queryParam string, _ io.Reader) ([]byte, error) {
+	r.method, r.route = method, route
+	query, err := url.ParseQuery(queryParam)
+	if err != nil {
+		return nil, err
+	}
+	r.query = query
+	return r.resp, r.err
+}
+
+func TestEscaping(t *testing.T) {
+	r := &requester{resp: []byte(`[]`)}
+	client := NewClient(r)
+
+	_, err := client.SearchObjectByTypes("a&b=c", []string{"HostRecord", "AliasRecord"}, 0, 10, false)
+	common.CheckError(t, "SearchObjectByTypes", nil, err)
+	common.CheckResponse(t, "Keyword", "a&b=c", r.query.Get("keyword"))
+	common.CheckResponse(t, "Types", "HostRecord,AliasRecord", r.query.Get("types"))
+
+	r.resp = []byte(`{"id": 5, "name": "www", "type": "HostRecord", "properties": "absoluteName=www.example.com|"}`)
+	entity, err := client.GetEntityByName(3, "www&x", "HostRecord", false)
+	common.CheckError(t, "GetEntityByName", nil, err)
+	common.CheckResponse(t, "Name", "www&x", r.query.Get("name"))
+	common.CheckResponse(t, "Entity", "www.example.com", entity.Properties["absoluteName"])
+
+	r.resp = []byte(`12`)
+	id, err := client.AddTXTRecord(4, "example.com", `v=spf1 include:_spf.example.com ~all`, -1, map[string]string{"comments": "a|b"})
+	common.CheckError(t, "AddTXTRecord", nil, err)
+	common.CheckResponse(t, "Record id", 12, id)
+	common.CheckResponse(t, "Method", "POST", r.method)
+	common.CheckResponse(t, "Route", "/addTXTRecord", r.route)
+	common.CheckResponse(t, "TXT", `v=spf1 include:_spf.example.com ~all`, r.query.Get("txt"))
+	common.CheckResponse(t, "TTL", "-1", r.query.Get("ttl"))
+
+	_, err = client.GetHostRecordsByHint(0, 10, map[string]string{"hint": "^www*"})
+	if err == nil {
+		t.Error("expected a decode error for a record id response")
+	}
+	common.CheckResponse(t, "Options", "hint=^www*", r.query.Get("options"))
+}
+
+func TestErrors(t *testing.T) {
+	r := &requester{resp: []byte(`{"id": 0, "name": null, "type": null, "properties": null}`)}
+	client := NewClient(r)
+
+	_, err := client.GetEntityById(1, false)
+	common.CheckResponse(t, "Empty entity", true, IsNotFound(err))
+
+	r.resp = []byte(`{invalid`)
+	_, err = client.GetEntityById(1, false)
+	var decodeErr *DecodeError
+	common.CheckResponse(t, "Invalid response", true, errors.As(err, &decodeErr))
+
+	// Errors of the requester are returned as they are
+	r.err = errors.New("boom")
+	_, err = client.GetEntityById(1, false)
+	common.CheckResponse(t, "Requester error", r.err, err)
+
+	tests := []struct {
+		name      string
+		err       error
+		notFound  bool
+		duplicate bool
+		transient bool
+	}{
+		{"Server fault", &StatusError{StatusCode: 503, Body: "Service Unavailable"}, false, false, true},
+		{"Missing entity", &StatusError{StatusCode: 500, Body: "Object was not found"}, true, false, false},
+		{"Duplicate entity", &StatusError{StatusCode: 500, Body: "Duplicate of another item"}, false, true, false},
+		{"Client error", &StatusError{StatusCode: 400, Body: "Bad request"}, false, false, false},
+		{"Other error", errors.New("connection refused"), false, false, false},
+	}
+	for _, tc := range tests {
+		common.CheckResponse(t, tc.name+" not found", tc.notFound, IsNotFound(tc.err))
+		common.CheckResponse(t, tc.name+" duplicate", tc.duplicate, IsDuplicate(tc.err))
+		common.CheckResponse(t, tc.name+" transient", tc.transient, IsTransient(tc.err))
+	}
+}
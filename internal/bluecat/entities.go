@@ -0,0 +1,116 @@
+package bluecat
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// GetEntityById returns the entity with the id
+func (c *Client) GetEntityById(id int, includeHA bool) (*models.Entity, error) {
+	params := newParams(map[string]int{"id": id})
+	params.Set("includeHA", strconv.FormatBool(includeHA))
+	return c.getEntity("/getEntityById", params)
+}
+
+// GetParent returns the parent entity of an entity
+func (c *Client) GetParent(entityId int) (*models.Entity, error) {
+	return c.getEntity("/getParent", newParams(map[string]int{"entityId": entityId}))
+}
+
+// GetEntityByName returns the child entity of a type with the name
+func (c *Client) GetEntityByName(parentId int, name string, entityType string, includeHA bool) (*models.Entity, error) {
+	params := newParams(map[string]int{"parentId": parentId})
+	params.Set("name", name)
+	params.Set("type", entityType)
+	params.Set("includeHA", strconv.FormatBool(includeHA))
+	return c.getEntity("/getEntityByName", params)
+}
+
+// GetEntities returns a page of the child entities of a type.
+// Note: The maximum value for count is 10.
+func (c *Client) GetEntities(parentId int, entityType string, start int, count int, includeHA bool) ([]models.Entity, error) {
+	params := newParams(map[string]int{"parentId": parentId, "start": start, "count": count})
+	params.Set("type", entityType)
+	params.Set("includeHA", strconv.FormatBool(includeHA))
+	return c.getEntities("/getEntities", params)
+}
+
+// SearchObjectByTypes returns a page of the entities of the types that match the keyword
+func (c *Client) SearchObjectByTypes(keyword string, types []string, start int, count int, includeHA bool) ([]models.Entity, error) {
+	params := newParams(map[string]int{"start": start, "count": count})
+	params.Set("keyword", keyword)
+	params.Set("types", strings.Join(types, ","))
+	params.Set("includeHA", strconv.FormatBool(includeHA))
+	return c.getEntities("/searchObjectByTypes", params)
+}
+
+// CustomSearch returns a page of the entities of a type that match all the filters
+func (c *Client) CustomSearch(objectType string, filters map[string]string, options []string, start int, count int) ([]models.Entity, error) {
+	params := newParams(map[string]int{"start": start, "count": count})
+	params.Set("type", objectType)
+	params.Set("includeHA", "false")
+	for key, value := range filters {
+		params.Add("filters", key+"="+value)
+	}
+	for _, option := range options {
+		params.Add("options", option)
+	}
+	return c.getEntities("/customSearch", params)
+}
+
+// GetZonesByHint returns a page of the zones of the container that match the options
+func (c *Client) GetZonesByHint(containerId int, start int, count int, options map[string]string) ([]models.Entity, error) {
+	return c.getByHint("/getZonesByHint", containerId, start, count, options)
+}
+
+// GetIP4NetworksByHint returns a page of the networks of the container that match the options
+func (c *Client) GetIP4NetworksByHint(containerId int, start int, count int, options map[string]string) ([]models.Entity, error) {
+	return c.getByHint("/getIP4NetworksByHint", containerId, start, count, options)
+}
+
+// GetHostRecordsByHint returns a page of the host records that match the options
+func (c *Client) GetHostRecordsByHint(start int, count int, options map[string]string) ([]models.Entity, error) {
+	params := newParams(map[string]int{"start": start, "count": count})
+	params.Set("options", joinOptions(options))
+	return c.getEntities("/getHostRecordsByHint", params)
+}
+
+// GetAliasesByHint returns a page of the alias records that match the options
+func (c *Client) GetAliasesByHint(start int, count int, options map[string]string) ([]models.Entity, error) {
+	params := newParams(map[string]int{"start": start, "count": count})
+	params.Set("options", joinOptions(options))
+	return c.getEntities("/getAliasesByHint", params)
+}
+
+// getByHint calls one of the hint routes that search a container
+func (c *Client) getByHint(route string, containerId int, start int, count int, options map[string]string) ([]models.Entity, error) {
+	params := newParams(map[string]int{"containerId": containerId, "start": start, "count": count})
+	params.Set("options", joinOptions(options))
+	return c.getEntities(route, params)
+}
+
+// Update replaces the name and properties of an entity
+func (c *Client) Update(entity *models.Entity) error {
+	body, err := entity.ToBluecatJSON()
+	if err != nil {
+		return err
+	}
+	return c.call(http.MethodPut, "/update", nil, strings.NewReader(string(body)), nil)
+}
+
+// Delete deletes an entity and its children
+func (c *Client) Delete(objectId int) error {
+	return c.call(http.MethodDelete, "/delete", newParams(map[string]int{"objectId": objectId}), nil, nil)
+}
+
+// GetSystemInfo returns the properties of the Address Manager server, e.g. its hostName and version
+func (c *Client) GetSystemInfo() (map[string]string, error) {
+	var info string
+	if err := c.call(http.MethodGet, "/getSystemInfo", nil, nil, &info); err != nil {
+		return nil, err
+	}
+	return common.ConvertToMap(info, "|"), nil
+}
@@ -0,0 +1,65 @@
+package bluecat
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrNotFound is returned when the API responds with an empty entity
+var ErrNotFound = errors.New("entity not found")
+
+// StatusError is returned when the API responds with a status code other than 2xx
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, Body: %s", e.StatusCode, e.Body)
+}
+
+// The legacy API reports most failures with a 500 and a message, these are the messages that are not server faults
+var (
+	notFoundMessages  = []string{"not found", "does not exist"}
+	duplicateMessages = []string{"duplicate of another item", "already exists"}
+)
+
+// IsNotFound checks whether the error reports an entity that does not exist
+func IsNotFound(err error) bool {
+	if errors.Is(err, ErrNotFound) {
+		return true
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusNotFound || containsAny(statusErr.Body, notFoundMessages))
+}
+
+// IsDuplicate checks whether the error reports an entity that conflicts with an existing one
+func IsDuplicate(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusConflict || containsAny(statusErr.Body, duplicateMessages))
+}
+
+// IsTransient checks whether the error is a server fault that may not happen again, as opposed to the API
+// rejecting the request
+func IsTransient(err error) bool {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return false
+	}
+	return statusErr.StatusCode >= http.StatusInternalServerError && !IsNotFound(err) && !IsDuplicate(err)
+}
+
+// containsAny checks whether the message contains one of the substrings, ignoring case
+func containsAny(message string, substrings []string) bool {
+	message = strings.ToLower(message)
+	for _, substring := range substrings {
+		if strings.Contains(message, substring) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package bluecat
+
+import "strings"
+
+// AddHostRecord adds a host record for the addresses to the view and returns its id
+func (c *Client) AddHostRecord(viewId int, absoluteName string, addresses []string, ttl int, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"viewId": viewId, "ttl": ttl})
+	params.Set("absoluteName", absoluteName)
+	params.Set("addresses", strings.Join(addresses, ","))
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addHostRecord", params)
+}
+
+// AddAliasRecord adds an alias record to the view and returns its id
+func (c *Client) AddAliasRecord(viewId int, absoluteName string, linkedRecordName string, ttl int, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"viewId": viewId, "ttl": ttl})
+	params.Set("absoluteName", absoluteName)
+	params.Set("linkedRecordName", linkedRecordName)
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addAliasRecord", params)
+}
+
+// AddExternalHostRecord adds an external host record to the view and returns its id
+func (c *Client) AddExternalHostRecord(viewId int, name string, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"viewId": viewId})
+	params.Set("name", name)
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addExternalHostRecord", params)
+}
+
+// AddMXRecord adds an MX record to the view and returns its id
+func (c *Client) AddMXRecord(viewId int, absoluteName string, linkedRecordName string, priority int, ttl int, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"viewId": viewId, "priority": priority, "ttl": ttl})
+	params.Set("absoluteName", absoluteName)
+	params.Set("linkedRecordName", linkedRecordName)
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addMXRecord", params)
+}
+
+// AddTXTRecord adds a TXT record to the view and returns its id
+func (c *Client) AddTXTRecord(viewId int, absoluteName string, txt string, ttl int, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"viewId": viewId, "ttl": ttl})
+	params.Set("absoluteName", absoluteName)
+	params.Set("txt", txt)
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addTXTRecord", params)
+}
+
+// AddSRVRecord adds an SRV record to the view and returns its id
+func (c *Client) AddSRVRecord(viewId int, absoluteName string, linkedRecordName string, priority int, weight int, port int, ttl int, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"viewId": viewId, "priority": priority, "weight": weight, "port": port, "ttl": ttl})
+	params.Set("absoluteName", absoluteName)
+	params.Set("linkedRecordName", linkedRecordName)
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addSRVRecord", params)
+}
+
+// AddGenericRecord adds a record of a type without a dedicated route to the view and returns its id
+func (c *Client) AddGenericRecord(viewId int, absoluteName string, recordType string, rdata string, ttl int, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"viewId": viewId, "ttl": ttl})
+	params.Set("absoluteName", absoluteName)
+	params.Set("type", recordType)
+	params.Set("rdata", rdata)
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addGenericRecord", params)
+}
+
+// AddHINFORecord adds an HINFO record describing the cpu and operating system of a host to the view and returns its id
+func (c *Client) AddHINFORecord(viewId int, absoluteName string, cpu string, os string, ttl int, properties map[string]string) (int, error) {
+	params := newParams(map[string]int{"viewId": viewId, "ttl": ttl})
+	params.Set("absoluteName", absoluteName)
+	params.Set("cpu", cpu)
+	params.Set("os", os)
+	params.Set("properties", joinOptions(properties))
+	return c.add("/addHINFORecord", params)
+}
@@ -0,0 +1,117 @@
+package bluecat
+
+import (
+	"bytes"
+	"dns-api-go/logger"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SessionClient logs in to and out of the API. Its requests authenticate with the credentials of the user rather
+// than with a session, so they are sent with the http client directly instead of through a Requester.
+type SessionClient struct {
+	client  *http.Client
+	baseUrl string
+	version string
+}
+
+// NewSessionClient creates a client that manages the sessions of an API version at the base url
+func NewSessionClient(client *http.Client, baseUrl string, version string) *SessionClient {
+	return &SessionClient{client: client, baseUrl: strings.TrimSuffix(baseUrl, "/"), version: version}
+}
+
+// Login starts a session and returns the value of the Authorization header of its requests
+func (c *SessionClient) Login(username, password string) (string, error) {
+	var req *http.Request
+	var err error
+	if c.version == V2 {
+		credentials, merr := json.Marshal(map[string]string{"username": username, "password": password})
+		if merr != nil {
+			return "", merr
+		}
+		req, err = http.NewRequest(http.MethodPost, c.baseUrl+"/api/v2/sessions", bytes.NewReader(credentials))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		params := url.Values{}
+		params.Set("username", username)
+		params.Set("password", password)
+		req, err = http.NewRequest(http.MethodGet, c.baseUrl+"/login?"+params.Encode(), nil)
+	}
+	if err != nil {
+		return "", err
+	}
+	logger.Debug("Logging in to bluecat", zap.String("username", username), zap.String("version", c.version))
+
+	body, err := c.send(req)
+	if err != nil {
+		logger.Error("Login failed", zap.Error(err))
+		return "", fmt.Errorf("login failed: %w", err)
+	}
+
+	if c.version == V2 {
+		// The session carries the credentials to send with the requests
+		var session struct {
+			BasicAuthenticationCredentials string `json:"basicAuthenticationCredentials"`
+		}
+		if err := json.Unmarshal(body, &session); err != nil || session.BasicAuthenticationCredentials == "" {
+			return "", fmt.Errorf("login failed: no credentials in session response")
+		}
+		logger.Debug("Created API session")
+		return "Basic " + session.BasicAuthenticationCredentials, nil
+	}
+
+	// Extract the token from the response body
+	token := strings.TrimPrefix(string(body), "\"Session Token-> ")
+	token = strings.TrimSuffix(token, " <- for User : "+username+"\"")
+	logger.Debug("Created API session")
+	return token, nil
+}
+
+// Logout ends the session of the Authorization header
+func (c *SessionClient) Logout(authorization string) error {
+	var req *http.Request
+	var err error
+	if c.version == V2 {
+		req, err = http.NewRequest(http.MethodPatch, c.baseUrl+"/api/v2/sessions/current", bytes.NewReader([]byte(`{"state": "LOGGED_OUT"}`)))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		req, err = http.NewRequest(http.MethodGet, c.baseUrl+"/logout", nil)
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+
+	if _, err := c.send(req); err != nil {
+		logger.Error("Logout failed", zap.Error(err))
+		return fmt.Errorf("logout failed: %w", err)
+	}
+	return nil
+}
+
+// send sends a request of the session and returns the body of a successful response
+func (c *SessionClient) send(req *http.Request) ([]byte, error) {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
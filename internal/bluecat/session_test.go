@@ -0,0 +1,66 @@
+package bluecat
+
+import (
+	"dns-api-go/internal/common"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionClient(t *testing.T) {
+	var logins, logouts []*http.Request
+	var lastBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/Services/REST/v1/login":
+			logins = append(logins, r)
+			if r.URL.Query().Get("password") != "p&ss=word" {
+				http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprintf(w, `"Session Token-> BAMAuthToken: abc <- for User : %s"`, r.URL.Query().Get("username"))
+		case "/Services/REST/v1/logout", "/api/v2/sessions/current":
+			logouts = append(logouts, r)
+			lastBody = string(body)
+		case "/api/v2/sessions":
+			logins = append(logins, r)
+			lastBody = string(body)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"basicAuthenticationCredentials": "dXNlcjp0b2tlbg=="}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	// The credentials of the legacy API are escaped in the query
+	v1 := NewSessionClient(ts.Client(), ts.URL+"/Services/REST/v1/", V1)
+	token, err := v1.Login("api user", "p&ss=word")
+	common.CheckError(t, "Login to v1", nil, err)
+	common.CheckResponse(t, "Login to v1", "BAMAuthToken: abc", token)
+	common.CheckResponse(t, "Login username", "api user", logins[0].URL.Query().Get("username"))
+
+	_, err = v1.Login("api user", "wrong")
+	if err == nil {
+		t.Error("Login with a wrong password: expected an error")
+	}
+
+	err = v1.Logout(token)
+	common.CheckError(t, "Logout of v1", nil, err)
+	common.CheckResponse(t, "Logout of v1", token, logouts[0].Header.Get("Authorization"))
+
+	// The v2 API takes the credentials in the body
+	v2 := NewSessionClient(ts.Client(), ts.URL, V2)
+	token, err = v2.Login("user", "p&ss=word")
+	common.CheckError(t, "Login to v2", nil, err)
+	common.CheckResponse(t, "Login to v2", "Basic dXNlcjp0b2tlbg==", token)
+	common.CheckResponse(t, "Login to v2 body", `{"password":"p\u0026ss=word","username":"user"}`, lastBody)
+
+	err = v2.Logout(token)
+	common.CheckError(t, "Logout of v2", nil, err)
+	common.CheckResponse(t, "Logout of v2", http.MethodPatch, logouts[1].Method)
+	common.CheckResponse(t, "Logout of v2", `{"state": "LOGGED_OUT"}`, lastBody)
+}
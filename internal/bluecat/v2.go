@@ -0,0 +1,467 @@
+package bluecat
+
+import (
+	"bytes"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// v2Prefix is the path of the v2 API. Its routes are relative to the server rather than to the base url of the
+// legacy API, and the HAL links of its resources already include the prefix.
+const v2Prefix = "/api/v2"
+
+// V2Client calls the RESTful v2 API through a Requester.
+// The v2 API serves collections of resources, e.g. /api/v2/zones/{id}/resourceRecords, that are paged with offset
+// and limit and searched with filter expressions. Resources link to themselves and to their parent with HAL links.
+type V2Client struct {
+	requester Requester
+}
+
+// v2Types are the v2 names of the types that were renamed from the legacy API
+var v2Types = map[string]string{
+	types.IP4ADDRESS: "IPv4Address",
+	types.IP4BLOCK:   "IPv4Block",
+	types.IP4NETWORK: "IPv4Network",
+	types.DHCP4RANGE: "IPv4DHCPRange",
+}
+
+// v2Collections are the collections that hold the resources of each type
+var v2Collections = map[string]string{
+	types.CONFIGURATION: "configurations",
+	types.VIEW:          "views",
+	types.ZONE:          "zones",
+	types.IP4BLOCK:      "blocks",
+	types.IP4NETWORK:    "networks",
+	types.IP4ADDRESS:    "addresses",
+	types.DHCP4RANGE:    "ranges",
+	types.MACADDRESS:    "macAddresses",
+	types.MACPOOL:       "macPools",
+	types.HOSTRECORD:    "resourceRecords",
+	types.CNAMERECORD:   "resourceRecords",
+	types.EXTERNALHOST:  "resourceRecords",
+	types.GENERICRECORD: "resourceRecords",
+	types.HINFORECORD:   "resourceRecords",
+	types.MXRECORD:      "resourceRecords",
+	types.SRVRECORD:     "resourceRecords",
+	types.TXTRECORD:     "resourceRecords",
+}
+
+// v2Type returns the v2 name of a type
+func v2Type(entityType string) string {
+	if name, ok := v2Types[entityType]; ok {
+		return name
+	}
+	return entityType
+}
+
+// v1Type returns the legacy name of a v2 type
+func v1Type(resourceType string) string {
+	for v1, v2 := range v2Types {
+		if v2 == resourceType {
+			return v1
+		}
+	}
+	return resourceType
+}
+
+// collection returns the collection of the resources of a type
+func collection(entityType string) (string, error) {
+	name, ok := v2Collections[entityType]
+	if !ok {
+		return "", fmt.Errorf("type %s is not supported by the v2 API", entityType)
+	}
+	return name, nil
+}
+
+// resource is a resource of the v2 API with the fields that are converted to entity properties
+type resource struct {
+	ID                int                    `json:"id"`
+	Type              string                 `json:"type"`
+	Name              *string                `json:"name"`
+	AbsoluteName      string                 `json:"absoluteName"`
+	TTL               *int                   `json:"ttl"`
+	Range             string                 `json:"range"`
+	Address           string                 `json:"address"`
+	State             string                 `json:"state"`
+	Text              string                 `json:"text"`
+	RecordType        string                 `json:"recordType"`
+	RData             string                 `json:"rdata"`
+	CPU               string                 `json:"cpu"`
+	OS                string                 `json:"os"`
+	Priority          *int                   `json:"priority"`
+	Weight            *int                   `json:"weight"`
+	Port              *int                   `json:"port"`
+	ReverseRecord     *bool                  `json:"reverseRecord"`
+	Addresses         []reference            `json:"addresses"`
+	LinkedRecord      *reference             `json:"linkedRecord"`
+	MACAddress        *reference             `json:"macAddress"`
+	UserDefinedFields map[string]interface{} `json:"userDefinedFields"`
+	Links             map[string]link        `json:"_links"`
+}
+
+// reference is a resource embedded in another resource, e.g. the linked record of an alias
+type reference struct {
+	ID           int    `json:"id,omitempty"`
+	Type         string `json:"type,omitempty"`
+	AbsoluteName string `json:"absoluteName,omitempty"`
+	Address      string `json:"address,omitempty"`
+}
+
+// link is a HAL link of a resource
+type link struct {
+	Href string `json:"href"`
+}
+
+// page is a page of a collection
+type page struct {
+	Count      int        `json:"count"`
+	TotalCount int        `json:"totalCount"`
+	Data       []resource `json:"data"`
+}
+
+// toEntity converts a resource to an entity with the type name and properties of the legacy API
+func (r *resource) toEntity() models.Entity {
+	properties := make(map[string]string)
+	for key, value := range r.UserDefinedFields {
+		properties[key] = fmt.Sprint(value)
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			properties[key] = value
+		}
+	}
+	setInt := func(key string, value *int) {
+		if value != nil {
+			properties[key] = strconv.Itoa(*value)
+		}
+	}
+
+	set("absoluteName", r.AbsoluteName)
+	set("CIDR", r.Range)
+	set("address", r.Address)
+	set("state", r.State)
+	set("txt", r.Text)
+	set("type", r.RecordType)
+	set("rdata", r.RData)
+	set("cpu", r.CPU)
+	set("os", r.OS)
+	setInt("ttl", r.TTL)
+	setInt("priority", r.Priority)
+	setInt("weight", r.Weight)
+	setInt("port", r.Port)
+	if r.ReverseRecord != nil {
+		properties["reverseRecord"] = strconv.FormatBool(*r.ReverseRecord)
+	}
+	if r.LinkedRecord != nil {
+		set("linkedRecordName", r.LinkedRecord.AbsoluteName)
+	}
+	if r.MACAddress != nil {
+		set("macAddress", r.MACAddress.Address)
+	}
+	if len(r.Addresses) > 0 {
+		addresses := make([]string, len(r.Addresses))
+		for i, address := range r.Addresses {
+			addresses[i] = address.Address
+		}
+		properties["addresses"] = strings.Join(addresses, ",")
+	}
+
+	var name string
+	if r.Name != nil {
+		name = *r.Name
+	}
+
+	return models.Entity{
+		ID:         r.ID,
+		Name:       name,
+		Type:       v1Type(r.Type),
+		Properties: properties,
+	}
+}
+
+// route returns the route of a HAL link of the resource
+func (r *resource) route(rel string) (string, error) {
+	l, ok := r.Links[rel]
+	if !ok || l.Href == "" {
+		return "", fmt.Errorf("%s %d has no %s link", r.Type, r.ID, rel)
+	}
+
+	// Links may be absolute urls, the requester only needs their path
+	u, err := url.Parse(l.Href)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s link of %s %d: %v", rel, r.Type, r.ID, err)
+	}
+	return u.Path, nil
+}
+
+// readOnlyProperties are the properties derived from fields that cannot be changed once a resource exists
+var readOnlyProperties = map[string]bool{
+	"absoluteName": true,
+	"CIDR":         true,
+	"address":      true,
+	"state":        true,
+	"macAddress":   true,
+}
+
+// setProperties writes entity properties into the fields of a resource.
+// Properties that have a field of their own are converted to its type, the other properties are kept as
+// user-defined fields.
+func setProperties(fields map[string]interface{}, properties map[string]string) error {
+	userDefinedFields, _ := fields["userDefinedFields"].(map[string]interface{})
+	if userDefinedFields == nil {
+		userDefinedFields = make(map[string]interface{})
+	}
+
+	for key, value := range properties {
+		switch key {
+		case "ttl":
+			if value == "" || value == "-1" {
+				delete(fields, "ttl")
+				continue
+			}
+			fallthrough
+		case "priority", "weight", "port":
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid %s '%s'", key, value)
+			}
+			fields[key] = number
+		case "reverseRecord":
+			fields[key] = value == "true"
+		case "txt":
+			fields["text"] = value
+		case "rdata", "cpu", "os":
+			fields[key] = value
+		case "type":
+			fields["recordType"] = value
+		case "linkedRecordName":
+			fields["linkedRecord"] = reference{AbsoluteName: value}
+		case "addresses":
+			fields["addresses"] = addressReferences(strings.Split(value, ","))
+		default:
+			if !readOnlyProperties[key] {
+				userDefinedFields[key] = value
+			}
+		}
+	}
+
+	if len(userDefinedFields) > 0 {
+		fields["userDefinedFields"] = userDefinedFields
+	}
+	return nil
+}
+
+// addressReferences returns the references of the addresses of a host record
+func addressReferences(addresses []string) []reference {
+	references := make([]reference, len(addresses))
+	for i, address := range addresses {
+		references[i] = reference{Address: strings.TrimSpace(address)}
+	}
+	return references
+}
+
+// call sends a request with the query parameters and the JSON encoded body, unless in is nil, and decodes the
+// JSON response into out, unless out is nil
+func (c *V2Client) call(method, route string, params url.Values, in interface{}, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("error encoding request to %s: %v", route, err)
+		}
+		body = bytes.NewReader(payload)
+	}
+
+	resp, err := c.requester.MakeRequest(method, route, params.Encode(), body)
+	if err != nil {
+		return err
+	}
+	return decode(route, resp, out)
+}
+
+// get returns the resource with the id
+func (c *V2Client) get(id int) (*resource, error) {
+	var r resource
+	if err := c.call(http.MethodGet, fmt.Sprintf("%s/entities/%d", v2Prefix, id), nil, nil, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// list returns a page of the resources of a collection that match the filter
+func (c *V2Client) list(route string, filter string, start int, count int) ([]models.Entity, error) {
+	params := newParams(map[string]int{"offset": start, "limit": count})
+	if filter != "" {
+		params.Set("filter", filter)
+	}
+
+	var p page
+	if err := c.call(http.MethodGet, route, params, nil, &p); err != nil {
+		return nil, err
+	}
+
+	entities := make([]models.Entity, len(p.Data))
+	for i := range p.Data {
+		entities[i] = p.Data[i].toEntity()
+	}
+	return entities, nil
+}
+
+// first returns the first resource of a collection that matches the filter, no match is reported as ErrNotFound
+func (c *V2Client) first(route string, filter string) (*models.Entity, error) {
+	entities, err := c.list(route, filter, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrNotFound
+	}
+	return &entities[0], nil
+}
+
+// add creates a resource in a collection and returns its id
+func (c *V2Client) add(route string, fields map[string]interface{}) (int, error) {
+	var r resource
+	if err := c.call(http.MethodPost, route, nil, fields, &r); err != nil {
+		return -1, err
+	}
+	return r.ID, nil
+}
+
+// modify replaces a resource with its fields as changed by the function
+func (c *V2Client) modify(id int, change func(fields map[string]interface{}) error) error {
+	var raw json.RawMessage
+	if err := c.call(http.MethodGet, fmt.Sprintf("%s/entities/%d", v2Prefix, id), nil, nil, &raw); err != nil {
+		return err
+	}
+
+	var r resource
+	var fields map[string]interface{}
+	if err := decode("resource", raw, &r); err != nil {
+		return err
+	}
+	if err := decode("resource", raw, &fields); err != nil {
+		return err
+	}
+	route, err := r.route("self")
+	if err != nil {
+		return err
+	}
+
+	delete(fields, "_links")
+	delete(fields, "_embedded")
+	if err := change(fields); err != nil {
+		return err
+	}
+	return c.call(http.MethodPut, route, nil, fields, nil)
+}
+
+// childRoute returns the route of the collection that holds the children of a type of the parent.
+// The parent 0 stands for the top level of the API, e.g. the configurations.
+func (c *V2Client) childRoute(parentId int, entityType string) (string, error) {
+	name, err := collection(entityType)
+	if err != nil {
+		return "", err
+	}
+	if parentId == 0 {
+		return v2Prefix + "/" + name, nil
+	}
+
+	parent, err := c.get(parentId)
+	if err != nil {
+		return "", err
+	}
+	route, err := parent.route("self")
+	if err != nil {
+		return "", err
+	}
+	return route + "/" + name, nil
+}
+
+// filter joins the conditions of a filter expression
+func filter(conditions ...string) string {
+	var nonEmpty []string
+	for _, condition := range conditions {
+		if condition != "" {
+			nonEmpty = append(nonEmpty, condition)
+		}
+	}
+	return strings.Join(nonEmpty, " and ")
+}
+
+// condition returns the condition of a filter expression that compares a field with an operator,
+// e.g. "name:startsWith('www')"
+func condition(field, operator string, values ...string) string {
+	quoted := make([]string, len(values))
+	for i, value := range values {
+		quoted[i] = "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+	}
+	return fmt.Sprintf("%s:%s(%s)", field, operator, strings.Join(quoted, ","))
+}
+
+// typeCondition returns the condition that matches resources of the types
+func typeCondition(entityTypes ...string) string {
+	names := make([]string, len(entityTypes))
+	for i, entityType := range entityTypes {
+		names[i] = v2Type(entityType)
+	}
+	if len(names) == 1 {
+		return condition("type", "eq", names...)
+	}
+	return condition("type", "in", names...)
+}
+
+// hintCondition converts a hint of the legacy API to conditions on a field.
+// A hint anchored with ^ must match the start of the field and one anchored with $ its end, while * matches
+// anything, e.g. "^www*" becomes "absoluteName:startsWith('www')".
+func hintCondition(field, hint string) string {
+	if hint == "" {
+		return ""
+	}
+
+	anchorStart := strings.HasPrefix(hint, "^")
+	anchorEnd := strings.HasSuffix(hint, "$")
+	segments := strings.Split(strings.TrimSuffix(strings.TrimPrefix(hint, "^"), "$"), "*")
+	if len(segments) == 1 && anchorStart && anchorEnd {
+		return condition(field, "eq", segments[0])
+	}
+
+	var conditions []string
+	for i, segment := range segments {
+		switch {
+		case segment == "":
+			continue
+		case i == 0 && anchorStart:
+			conditions = append(conditions, condition(field, "startsWith", segment))
+		case i == len(segments)-1 && anchorEnd:
+			conditions = append(conditions, condition(field, "endsWith", segment))
+		default:
+			conditions = append(conditions, condition(field, "contains", segment))
+		}
+	}
+	return filter(conditions...)
+}
+
+// equalConditions returns the conditions that match the fields with the values, in the order of the fields
+func equalConditions(values map[string]string) []string {
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	conditions := make([]string, len(fields))
+	for i, field := range fields {
+		conditions[i] = condition(field, "eq", values[field])
+	}
+	return conditions
+}
@@ -0,0 +1,86 @@
+package bluecat
+
+import (
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// GetIP4Address returns the ipv4 address entity of the container with the address
+func (c *V2Client) GetIP4Address(containerId int, address string) (*models.Entity, error) {
+	route, err := c.childRoute(containerId, types.IP4ADDRESS)
+	if err != nil {
+		return nil, err
+	}
+	return c.first(route, condition("address", "eq", address))
+}
+
+// AssignNextAvailableIP4Address assigns the next free address of the network to the mac address.
+// The v2 API assigns the next free address to an address that is added to a network without one. It cannot create
+// host records along with the address, so hostInfo must be empty and the host record is added by the caller.
+func (c *V2Client) AssignNextAvailableIP4Address(_ int, parentId int, action string, macAddress string, hostInfo string, properties map[string]string) (*models.Entity, error) {
+	if hostInfo != "" {
+		return nil, fmt.Errorf("the v2 API cannot create a host record along with an address")
+	}
+
+	fields := map[string]interface{}{
+		"type":  v2Type(types.IP4ADDRESS),
+		"state": strings.TrimPrefix(action, "MAKE_"),
+	}
+	if macAddress != "" {
+		fields["macAddress"] = reference{Address: macAddress}
+	}
+	if err := setProperties(fields, properties); err != nil {
+		return nil, err
+	}
+
+	var r resource
+	route := fmt.Sprintf("%s/networks/%d/addresses", v2Prefix, parentId)
+	if err := c.call(http.MethodPost, route, nil, fields, &r); err != nil {
+		return nil, err
+	}
+
+	entity := r.toEntity()
+	return &entity, nil
+}
+
+// GetMACAddress returns the mac address entity of the configuration with the address
+func (c *V2Client) GetMACAddress(configurationId int, macAddress string) (*models.Entity, error) {
+	route := fmt.Sprintf("%s/configurations/%d/macAddresses", v2Prefix, configurationId)
+	return c.first(route, condition("address", "eq", macAddress))
+}
+
+// AddMACAddress adds a mac address to the configuration and returns its id
+func (c *V2Client) AddMACAddress(configurationId int, macAddress string, properties map[string]string) (int, error) {
+	fields := map[string]interface{}{
+		"type":    types.MACADDRESS,
+		"address": macAddress,
+	}
+	if err := setProperties(fields, properties); err != nil {
+		return -1, err
+	}
+	return c.add(fmt.Sprintf("%s/configurations/%d/macAddresses", v2Prefix, configurationId), fields)
+}
+
+// AssociateMACAddressWithPool adds a mac address to a mac pool, the mac address is created if it does not exist
+func (c *V2Client) AssociateMACAddressWithPool(configurationId int, macAddress string, poolId int) error {
+	var macId int
+	entity, err := c.GetMACAddress(configurationId, macAddress)
+	switch {
+	case err == nil:
+		macId = entity.ID
+	case IsNotFound(err):
+		if macId, err = c.AddMACAddress(configurationId, macAddress, nil); err != nil {
+			return err
+		}
+	default:
+		return err
+	}
+
+	return c.modify(macId, func(fields map[string]interface{}) error {
+		fields["macPool"] = reference{ID: poolId, Type: types.MACPOOL}
+		return nil
+	})
+}
@@ -0,0 +1,168 @@
+package bluecat
+
+import (
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"fmt"
+	"net/http"
+)
+
+// GetEntityById returns the entity with the id
+func (c *V2Client) GetEntityById(id int, _ bool) (*models.Entity, error) {
+	r, err := c.get(id)
+	if err != nil {
+		return nil, err
+	}
+	entity := r.toEntity()
+	return &entity, nil
+}
+
+// GetParent returns the parent entity of an entity by following its up link
+func (c *V2Client) GetParent(entityId int) (*models.Entity, error) {
+	r, err := c.get(entityId)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := r.Links["up"]; !ok {
+		return nil, ErrNotFound
+	}
+	route, err := r.route("up")
+	if err != nil {
+		return nil, err
+	}
+
+	var parent resource
+	if err := c.call(http.MethodGet, route, nil, nil, &parent); err != nil {
+		return nil, err
+	}
+	entity := parent.toEntity()
+	return &entity, nil
+}
+
+// GetEntityByName returns the child entity of a type with the name
+func (c *V2Client) GetEntityByName(parentId int, name string, entityType string, _ bool) (*models.Entity, error) {
+	route, err := c.childRoute(parentId, entityType)
+	if err != nil {
+		return nil, err
+	}
+	return c.first(route, filter(typeCondition(entityType), condition("name", "eq", name)))
+}
+
+// GetEntities returns a page of the child entities of a type
+func (c *V2Client) GetEntities(parentId int, entityType string, start int, count int, _ bool) ([]models.Entity, error) {
+	route, err := c.childRoute(parentId, entityType)
+	if err != nil {
+		return nil, err
+	}
+	return c.list(route, typeCondition(entityType), start, count)
+}
+
+// SearchObjectByTypes returns a page of the entities of the types whose name contains the keyword.
+// Types that are held by different collections are searched one collection after the other.
+func (c *V2Client) SearchObjectByTypes(keyword string, entityTypes []string, start int, count int, _ bool) ([]models.Entity, error) {
+	var names []string
+	typesOf := map[string][]string{}
+	for _, entityType := range entityTypes {
+		name, err := collection(entityType)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := typesOf[name]; !ok {
+			names = append(names, name)
+		}
+		typesOf[name] = append(typesOf[name], entityType)
+	}
+
+	if len(names) == 1 {
+		return c.list(v2Prefix+"/"+names[0], filter(typeCondition(entityTypes...), condition("name", "contains", keyword)), start, count)
+	}
+
+	var entities []models.Entity
+	for _, name := range names {
+		found, err := c.list(v2Prefix+"/"+name, filter(typeCondition(typesOf[name]...), condition("name", "contains", keyword)), 0, start+count)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, found...)
+	}
+	if start >= len(entities) {
+		return []models.Entity{}, nil
+	}
+	if start+count < len(entities) {
+		entities = entities[:start+count]
+	}
+	return entities[start:], nil
+}
+
+// CustomSearch returns a page of the entities of a type whose fields equal the filters.
+// The options of the legacy API have no equivalent in the v2 API and are ignored.
+func (c *V2Client) CustomSearch(objectType string, filters map[string]string, _ []string, start int, count int) ([]models.Entity, error) {
+	name, err := collection(objectType)
+	if err != nil {
+		return nil, err
+	}
+	conditions := append([]string{typeCondition(objectType)}, equalConditions(filters)...)
+	return c.list(v2Prefix+"/"+name, filter(conditions...), start, count)
+}
+
+// GetZonesByHint returns a page of the zones of the view that match the hint option
+func (c *V2Client) GetZonesByHint(containerId int, start int, count int, options map[string]string) ([]models.Entity, error) {
+	route := fmt.Sprintf("%s/views/%d/zones", v2Prefix, containerId)
+	return c.list(route, hintCondition("absoluteName", options["hint"]), start, count)
+}
+
+// GetIP4NetworksByHint returns a page of the networks of the configuration that match the hint option
+func (c *V2Client) GetIP4NetworksByHint(containerId int, start int, count int, options map[string]string) ([]models.Entity, error) {
+	route := fmt.Sprintf("%s/configurations/%d/networks", v2Prefix, containerId)
+	return c.list(route, hintCondition("range", options["hint"]), start, count)
+}
+
+// GetHostRecordsByHint returns a page of the host records that match the hint option
+func (c *V2Client) GetHostRecordsByHint(start int, count int, options map[string]string) ([]models.Entity, error) {
+	return c.list(v2Prefix+"/resourceRecords", filter(typeCondition(types.HOSTRECORD), hintCondition("absoluteName", options["hint"])), start, count)
+}
+
+// GetAliasesByHint returns a page of the alias records that match the hint option
+func (c *V2Client) GetAliasesByHint(start int, count int, options map[string]string) ([]models.Entity, error) {
+	return c.list(v2Prefix+"/resourceRecords", filter(typeCondition(types.CNAMERECORD), hintCondition("absoluteName", options["hint"])), start, count)
+}
+
+// Update replaces the name and properties of an entity
+func (c *V2Client) Update(entity *models.Entity) error {
+	return c.modify(entity.ID, func(fields map[string]interface{}) error {
+		fields["name"] = entity.Name
+		return setProperties(fields, entity.Properties)
+	})
+}
+
+// Delete deletes an entity and its children
+func (c *V2Client) Delete(objectId int) error {
+	r, err := c.get(objectId)
+	if err != nil {
+		return err
+	}
+	route, err := r.route("self")
+	if err != nil {
+		return err
+	}
+	return c.call(http.MethodDelete, route, nil, nil, nil)
+}
+
+// GetSystemInfo returns the properties of the root of the API, e.g. the version of Address Manager
+func (c *V2Client) GetSystemInfo() (map[string]string, error) {
+	var root map[string]interface{}
+	if err := c.call(http.MethodGet, v2Prefix, nil, nil, &root); err != nil {
+		return nil, err
+	}
+
+	info := make(map[string]string)
+	for key, value := range root {
+		switch value.(type) {
+		case map[string]interface{}, []interface{}, nil:
+			// Skip the links and other nested values
+		default:
+			info[key] = fmt.Sprint(value)
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,95 @@
+package bluecat
+
+import (
+	"dns-api-go/internal/types"
+	"fmt"
+)
+
+// addRecord adds a resource record with the fields and properties to the view and returns its id.
+// The view finds the zone of the record from its absolute name.
+func (c *V2Client) addRecord(viewId int, fields map[string]interface{}, ttl int, properties map[string]string) (int, error) {
+	if ttl >= 0 {
+		fields["ttl"] = ttl
+	}
+	if err := setProperties(fields, properties); err != nil {
+		return -1, err
+	}
+	return c.add(fmt.Sprintf("%s/views/%d/resourceRecords", v2Prefix, viewId), fields)
+}
+
+// AddHostRecord adds a host record for the addresses to the view and returns its id
+func (c *V2Client) AddHostRecord(viewId int, absoluteName string, addresses []string, ttl int, properties map[string]string) (int, error) {
+	return c.addRecord(viewId, map[string]interface{}{
+		"type":         types.HOSTRECORD,
+		"absoluteName": absoluteName,
+		"addresses":    addressReferences(addresses),
+	}, ttl, properties)
+}
+
+// AddAliasRecord adds an alias record to the view and returns its id
+func (c *V2Client) AddAliasRecord(viewId int, absoluteName string, linkedRecordName string, ttl int, properties map[string]string) (int, error) {
+	return c.addRecord(viewId, map[string]interface{}{
+		"type":         types.CNAMERECORD,
+		"absoluteName": absoluteName,
+		"linkedRecord": reference{AbsoluteName: linkedRecordName},
+	}, ttl, properties)
+}
+
+// AddExternalHostRecord adds an external host record to the view and returns its id
+func (c *V2Client) AddExternalHostRecord(viewId int, name string, properties map[string]string) (int, error) {
+	return c.addRecord(viewId, map[string]interface{}{
+		"type": types.EXTERNALHOST,
+		"name": name,
+	}, -1, properties)
+}
+
+// AddMXRecord adds an MX record to the view and returns its id
+func (c *V2Client) AddMXRecord(viewId int, absoluteName string, linkedRecordName string, priority int, ttl int, properties map[string]string) (int, error) {
+	return c.addRecord(viewId, map[string]interface{}{
+		"type":         types.MXRECORD,
+		"absoluteName": absoluteName,
+		"linkedRecord": reference{AbsoluteName: linkedRecordName},
+		"priority":     priority,
+	}, ttl, properties)
+}
+
+// AddTXTRecord adds a TXT record to the view and returns its id
+func (c *V2Client) AddTXTRecord(viewId int, absoluteName string, txt string, ttl int, properties map[string]string) (int, error) {
+	return c.addRecord(viewId, map[string]interface{}{
+		"type":         types.TXTRECORD,
+		"absoluteName": absoluteName,
+		"text":         txt,
+	}, ttl, properties)
+}
+
+// AddSRVRecord adds an SRV record to the view and returns its id
+func (c *V2Client) AddSRVRecord(viewId int, absoluteName string, linkedRecordName string, priority int, weight int, port int, ttl int, properties map[string]string) (int, error) {
+	return c.addRecord(viewId, map[string]interface{}{
+		"type":         types.SRVRECORD,
+		"absoluteName": absoluteName,
+		"linkedRecord": reference{AbsoluteName: linkedRecordName},
+		"priority":     priority,
+		"weight":       weight,
+		"port":         port,
+	}, ttl, properties)
+}
+
+// AddGenericRecord adds a record of a type without a dedicated resource type to the view and returns its id
+func (c *V2Client) AddGenericRecord(viewId int, absoluteName string, recordType string, rdata string, ttl int, properties map[string]string) (int, error) {
+	return c.addRecord(viewId, map[string]interface{}{
+		"type":         types.GENERICRECORD,
+		"absoluteName": absoluteName,
+		"recordType":   recordType,
+		"rdata":        rdata,
+	}, ttl, properties)
+}
+
+// AddHINFORecord adds an HINFO record describing the cpu and operating system of a host to the view and returns its id
+func (c *V2Client) AddHINFORecord(viewId int, absoluteName string, cpu string, os string, ttl int, properties map[string]string) (int, error) {
+	return c.addRecord(viewId, map[string]interface{}{
+		"type":         types.HINFORECORD,
+		"absoluteName": absoluteName,
+		"cpu":          cpu,
+		"os":           os,
+	}, ttl, properties)
+}
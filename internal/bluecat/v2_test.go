@@ -0,0 +1,195 @@
+package bluecat
+
+import (
+	"dns-api-go/internal/common"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/url"
+	"testing"
+)
+
+// v2Requester answers the requests of the v2 API with canned responses by method and route
+type v2Requester struct {
+	responses map[string]string
+	requests  []string
+	queries   []url.Values
+	bodies    []map[string]interface{}
+}
+
+func (r *v2Requester) APIVersion() string {
+	return V2
+}
+
+func (r *v2Requester) MakeRequest(method, route, queryParam string, body io.Reader) ([]byte, error) {
+	request := method + " " + route
+	r.requests = append(r.requests, request)
+	query, err := url.ParseQuery(queryParam)
+	if err != nil {
+		return nil, err
+	}
+	r.queries = append(r.queries, query)
+
+	var fields map[string]interface{}
+	if body != nil {
+		if err := json.NewDecoder(body).Decode(&fields); err != nil {
+			return nil, err
+		}
+	}
+	r.bodies = append(r.bodies, fields)
+
+	resp, ok := r.responses[request]
+	if !ok {
+		return nil, &StatusError{StatusCode: 404, Body: `{"status":404,"reason":"Not Found"}`}
+	}
+	return []byte(resp), nil
+}
+
+const hostRecord = `{
+	"id": 12, "type": "HostRecord", "name": "www", "absoluteName": "www.example.com", "ttl": 300,
+	"addresses": [{"id": 30, "type": "IPv4Address", "address": "10.0.0.5"}, {"id": 31, "type": "IPv4Address", "address": "10.0.0.6"}],
+	"reverseRecord": true, "userDefinedFields": {"owner": "dns"},
+	"_links": {"self": {"href": "/api/v2/resourceRecords/12"}, "up": {"href": "https://bam.example.com/api/v2/zones/7"}}
+}`
+
+func TestV2Entities(t *testing.T) {
+	r := &v2Requester{responses: map[string]string{
+		"GET /api/v2/entities/12":                hostRecord,
+		"GET /api/v2/zones/7":                    `{"id": 7, "type": "Zone", "name": "example.com", "absoluteName": "example.com", "_links": {"self": {"href": "/api/v2/zones/7"}}}`,
+		"GET /api/v2/entities/3":                 `{"id": 3, "type": "View", "name": "default", "_links": {"self": {"href": "/api/v2/views/3"}}}`,
+		"GET /api/v2/views/3/resourceRecords":    `{"count": 1, "totalCount": 1, "data": [` + hostRecord + `]}`,
+		"GET /api/v2/configurations/1/networks":  `{"count": 1, "data": [{"id": 20, "type": "IPv4Network", "name": "lab", "range": "10.0.0.0/24"}]}`,
+		"PUT /api/v2/resourceRecords/12":         `{}`,
+		"DELETE /api/v2/resourceRecords/12":      ``,
+		"GET /api/v2/configurations/1/addresses": `{"count": 0, "data": []}`,
+		"GET /api/v2/entities/1":                 `{"id": 1, "type": "Configuration", "name": "default", "_links": {"self": {"href": "/api/v2/configurations/1"}}}`,
+		"GET /api/v2/views/3/zones":              `{"count": 0, "data": []}`,
+	}}
+	client := NewClient(r)
+	if _, ok := client.(*V2Client); !ok {
+		t.Fatalf("expected a v2 client for a v2 requester, got %T", client)
+	}
+
+	// Resources are converted to entities of the legacy API
+	entity, err := client.GetEntityById(12, false)
+	common.CheckError(t, "GetEntityById", nil, err)
+	common.CheckResponse(t, "Type", "HostRecord", entity.Type)
+	common.CheckResponse(t, "Properties", map[string]string{
+		"absoluteName":  "www.example.com",
+		"ttl":           "300",
+		"addresses":     "10.0.0.5,10.0.0.6",
+		"reverseRecord": "true",
+		"owner":         "dns",
+	}, entity.Properties)
+
+	networks, err := client.GetIP4NetworksByHint(1, 0, 10, map[string]string{"hint": "10.0.*"})
+	common.CheckError(t, "GetIP4NetworksByHint", nil, err)
+	common.CheckResponse(t, "Network type", "IP4Network", networks[0].Type)
+	common.CheckResponse(t, "Network CIDR", "10.0.0.0/24", networks[0].Properties["CIDR"])
+	common.CheckResponse(t, "Network filter", "range:contains('10.0.')", r.queries[len(r.queries)-1].Get("filter"))
+
+	// The parent is found by following the up link
+	parent, err := client.GetParent(12)
+	common.CheckError(t, "GetParent", nil, err)
+	common.CheckResponse(t, "Parent", 7, parent.ID)
+
+	// Children are listed from the collection below the self link of the parent
+	records, err := client.GetEntities(3, "HostRecord", 10, 5, false)
+	common.CheckError(t, "GetEntities", nil, err)
+	common.CheckResponse(t, "Records", 1, len(records))
+	query := r.queries[len(r.queries)-1]
+	common.CheckResponse(t, "Offset", "10", query.Get("offset"))
+	common.CheckResponse(t, "Limit", "5", query.Get("limit"))
+	common.CheckResponse(t, "Type filter", "type:eq('HostRecord')", query.Get("filter"))
+
+	_, err = client.GetEntityByName(3, "o'brien", "HostRecord", false)
+	common.CheckError(t, "GetEntityByName", nil, err)
+	common.CheckResponse(t, "Name filter", `type:eq('HostRecord') and name:eq('o\'brien')`, r.queries[len(r.queries)-1].Get("filter"))
+
+	_, err = client.GetZonesByHint(3, 0, 10, map[string]string{"hint": "^example*"})
+	common.CheckError(t, "GetZonesByHint", nil, err)
+	common.CheckResponse(t, "Zone filter", "absoluteName:startsWith('example')", r.queries[len(r.queries)-1].Get("filter"))
+
+	// A search without matches is reported as not found
+	_, err = client.GetIP4Address(1, "10.0.0.9")
+	common.CheckResponse(t, "Missing address", true, IsNotFound(err))
+
+	// Updates replace the resource at its self link with the changed fields
+	entity.Properties["ttl"] = "600"
+	entity.Properties["owner"] = "network"
+	entity.Properties["addresses"] = "10.0.0.7"
+	common.CheckError(t, "Update", nil, client.Update(entity))
+	common.CheckResponse(t, "Update request", "PUT /api/v2/resourceRecords/12", r.requests[len(r.requests)-1])
+	body := r.bodies[len(r.bodies)-1]
+	common.CheckResponse(t, "Updated ttl", float64(600), body["ttl"])
+	common.CheckResponse(t, "Updated fields", map[string]interface{}{"owner": "network"}, body["userDefinedFields"])
+	common.CheckResponse(t, "Updated addresses", []interface{}{map[string]interface{}{"address": "10.0.0.7"}}, body["addresses"])
+	if _, ok := body["_links"]; ok {
+		t.Error("expected the links to be left out of the update")
+	}
+
+	common.CheckError(t, "Delete", nil, client.Delete(12))
+	common.CheckResponse(t, "Delete request", "DELETE /api/v2/resourceRecords/12", r.requests[len(r.requests)-1])
+}
+
+func TestV2Create(t *testing.T) {
+	r := &v2Requester{responses: map[string]string{
+		"POST /api/v2/views/3/resourceRecords":       `{"id": 40, "type": "MXRecord"}`,
+		"GET /api/v2/configurations/1/macAddresses":  `{"count": 0, "data": []}`,
+		"POST /api/v2/configurations/1/macAddresses": `{"id": 50, "type": "MACAddress"}`,
+		"GET /api/v2/entities/50":                    `{"id": 50, "type": "MACAddress", "address": "AA-BB-CC-DD-EE-FF", "_links": {"self": {"href": "/api/v2/macAddresses/50"}}}`,
+		"PUT /api/v2/macAddresses/50":                `{}`,
+	}}
+	client := NewClient(r)
+
+	id, err := client.AddMXRecord(3, "example.com", "mail.example.com", 10, -1, map[string]string{"comments": "primary"})
+	common.CheckError(t, "AddMXRecord", nil, err)
+	common.CheckResponse(t, "Record id", 40, id)
+	common.CheckResponse(t, "Record body", map[string]interface{}{
+		"type":              "MXRecord",
+		"absoluteName":      "example.com",
+		"linkedRecord":      map[string]interface{}{"absoluteName": "mail.example.com"},
+		"priority":          float64(10),
+		"userDefinedFields": map[string]interface{}{"comments": "primary"},
+	}, r.bodies[len(r.bodies)-1])
+
+	// A mac address that does not exist yet is created before it is associated with the pool
+	err = client.AssociateMACAddressWithPool(1, "AA-BB-CC-DD-EE-FF", 9)
+	common.CheckError(t, "AssociateMACAddressWithPool", nil, err)
+	common.CheckResponse(t, "Requests", []string{
+		"POST /api/v2/views/3/resourceRecords",
+		"GET /api/v2/configurations/1/macAddresses",
+		"POST /api/v2/configurations/1/macAddresses",
+		"GET /api/v2/entities/50",
+		"PUT /api/v2/macAddresses/50",
+	}, r.requests)
+	common.CheckResponse(t, "MAC pool", map[string]interface{}{"id": float64(9), "type": "MACPool"}, r.bodies[len(r.bodies)-1]["macPool"])
+
+	// Conflicts are reported as duplicates
+	r.responses = map[string]string{}
+	_, err = client.AddTXTRecord(3, "example.com", "v=spf1 -all", 300, nil)
+	common.CheckResponse(t, "Missing view", true, IsNotFound(err))
+	common.CheckResponse(t, "Conflict", true, IsDuplicate(&StatusError{StatusCode: 409, Body: `{"status":409,"reason":"Conflict"}`}))
+
+	_, err = client.CustomSearch("Unknown", nil, nil, 0, 10)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected an unsupported type error, got %v", err)
+	}
+}
+
+func TestHintCondition(t *testing.T) {
+	tests := []struct {
+		hint     string
+		expected string
+	}{
+		{"", ""},
+		{"www", "name:contains('www')"},
+		{"^www", "name:startsWith('www')"},
+		{"com$", "name:endsWith('com')"},
+		{"^www.example.com$", "name:eq('www.example.com')"},
+		{"^www*example$", "name:startsWith('www') and name:endsWith('example')"},
+	}
+	for _, tc := range tests {
+		common.CheckResponse(t, tc.hint, tc.expected, hintCondition("name", tc.hint))
+	}
+}
@@ -28,12 +28,119 @@ import (
 type Config struct {
 	ListenAddress string
 	Token         string
+	Clients       []Client
+	OIDC          *OIDC
+	Policies      []PolicyRule
+	Audit         *Audit
+	Webhooks      *Webhooks
+	Idempotency   *Idempotency
+	Jobs          *Jobs
+	Shutdown      *Shutdown
+	Health        *Health
+	Tracing       *Tracing
 	ProxyBackend  *ProxyBackend
 	Bluecat       *Bluecat
 	LogLevel      string
 	Version       Version
 	Org           string
 	CIDRFile      string
+	Provider      string
+	Route53       *Route53
+}
+
+// Client is a client of the API with its own key and the scopes it is granted, e.g. "records:read".
+// The scope "records:*" grants all the scopes of records and "*" grants every scope.
+type Client struct {
+	Name   string
+	Key    string
+	Scopes []string
+}
+
+// OIDC configures the bearer tokens of an OpenID Connect provider that are accepted besides the client keys.
+// Tokens must be issued by Issuer for Audience and signed with a key of JWKSFile or JWKSURL. GroupScopes maps
+// the groups of the GroupsClaim, "groups" by default, to the scopes they grant.
+type OIDC struct {
+	Issuer      string
+	Audience    string
+	JWKSFile    string
+	JWKSURL     string
+	GroupsClaim string
+	GroupScopes map[string][]string
+}
+
+// PolicyRule allows or denies, depending on its Effect, the changes of names and networks that match all its
+// conditions. Clients lists the names of API clients and the subjects of bearer tokens, Actions the changes,
+// e.g. "records:create" or "ips:*", Suffixes the DNS names, e.g. "*.team.example.edu", RecordTypes the types
+// of records and CIDRs the networks, also by their names in the CIDR file. Empty conditions match anything.
+type PolicyRule struct {
+	Name        string
+	Effect      string
+	Clients     []string
+	Actions     []string
+	Suffixes    []string
+	RecordTypes []string
+	CIDRs       []string
+}
+
+// Audit configures the audit log of the changes made through the API.
+// File is the path of a JSON-lines file that the events are appended to. Without a file the most recent events
+// are only kept in memory.
+type Audit struct {
+	File string
+}
+
+// Webhooks configures the subscriptions to the change events of the API.
+// Failed deliveries are retried as configured by Retry, and then appended to DeadLetterFile as JSON lines.
+type Webhooks struct {
+	Subscriptions  []Webhook
+	Retry          *Retry
+	Timeout        string
+	DeadLetterFile string
+}
+
+// Webhook subscribes a URL to the events listed in Events, e.g. "record.created", or to every event when
+// Events is empty. The events are signed with the Secret.
+type Webhook struct {
+	URL    string
+	Secret string
+	Events []string
+}
+
+// Idempotency configures how long the responses to requests with an Idempotency-Key header are kept, as a
+// duration string
+type Idempotency struct {
+	Window string
+}
+
+// Jobs configures the background jobs of asynchronous requests, the number of jobs that run at the same time
+// and how long the jobs are kept after they finished, as a duration string
+type Jobs struct {
+	Workers   int
+	Retention string
+}
+
+// Shutdown configures how the server stops on SIGTERM or SIGINT. Delay is the time between failing the readiness
+// probe and draining the requests, Timeout is the time the requests and jobs get to finish, as duration strings.
+type Shutdown struct {
+	Delay   string
+	Timeout string
+}
+
+// Health configures how long the outcome of the readiness checks is kept, as a duration string
+type Health struct {
+	Interval string
+}
+
+// Tracing configures the export of the traces of the API requests and their bluecat calls. Exporter is "otlp" to
+// send the spans over OTLP/HTTP to the Endpoint, e.g. "localhost:4318", with the Headers, or "stdout" or "file" to
+// write them as JSON lines to stdout or to File. Tracing is disabled without an exporter.
+type Tracing struct {
+	Exporter    string
+	Endpoint    string
+	Insecure    bool
+	Headers     map[string]string
+	File        string
+	ServiceName string
 }
 
 type ProxyBackend struct {
@@ -42,12 +149,64 @@ type ProxyBackend struct {
 	BackendPrefix string
 }
 
+// Bluecat is the configuration of the BlueCat Address Manager.
+// APIVersion selects the legacy "v1" API, the default, or the RESTful "v2" API. BaseUrl is the url of the legacy
+// API, e.g. https://bam.example.edu/Services/REST/v1, or the url of the server with the v2 API.
 type Bluecat struct {
-	Account  string
-	BaseUrl  string
-	Username string
-	Password string
-	ViewId   string
+	Account    string
+	BaseUrl    string
+	Username   string
+	Password   string
+	ViewId     string
+	APIVersion string
+	Retry      *Retry
+	Cache      *Cache
+	Timeout    string
+	TLS        *TLS
+}
+
+// TLS configures the connections to BlueCat.
+// CAFile is a PEM bundle of the certificate authorities to trust instead of the system pool, and CertFile and
+// KeyFile hold the client certificate to present. InsecureSkipVerify disables certificate verification.
+type TLS struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// Retry configures retries of failed requests to BlueCat.
+// Sleep is the delay before the first retry as a duration string, and it doubles for the first Doubling retries.
+// GET requests are always retried, SafeRoutes lists the mutating routes that can be repeated without changing
+// the outcome and replaces the default list when set.
+type Retry struct {
+	Attempts   int
+	Doubling   int
+	Sleep      string
+	SafeRoutes []string
+}
+
+// Cache configures the caching of bluecat lookups.
+// ConfigurationTTL applies to the configuration ID, EntityTTL to the configurations, views, zones and networks
+// retrieved by ID and ReadThroughTTL to the other entities retrieved by ID, as duration strings.
+// Disabled turns the cache off.
+type Cache struct {
+	Disabled         bool
+	ConfigurationTTL string
+	EntityTTL        string
+	ReadThroughTTL   string
+}
+
+// Route53 is the configuration for serving zones and records from AWS Route 53
+// Endpoint can be set to point the client at a local stub of the Route 53 API.
+type Route53 struct {
+	Account         string
+	Region          string
+	Endpoint        string
+	AccessKeyId     string
+	SecretAccessKey string
+	HostedZoneIds   []string
 }
 
 // Version carries around the API version information
@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+)
+
+// MockRoute53 mocks the Route 53 API calls used by the Route 53 services.
+// Calling any other method of the interface panics.
+type MockRoute53 struct {
+	route53iface.Route53API
+	ListHostedZonesPagesFunc        func(input *route53.ListHostedZonesInput, fn func(*route53.ListHostedZonesOutput, bool) bool) error
+	ListResourceRecordSetsPagesFunc func(input *route53.ListResourceRecordSetsInput, fn func(*route53.ListResourceRecordSetsOutput, bool) bool) error
+	ChangeResourceRecordSetsFunc    func(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error)
+}
+
+func (m *MockRoute53) ListHostedZonesPages(input *route53.ListHostedZonesInput, fn func(*route53.ListHostedZonesOutput, bool) bool) error {
+	if m.ListHostedZonesPagesFunc != nil {
+		return m.ListHostedZonesPagesFunc(input, fn)
+	}
+
+	return errors.New("ListHostedZonesPages not mocked")
+}
+
+func (m *MockRoute53) ListResourceRecordSetsPages(input *route53.ListResourceRecordSetsInput, fn func(*route53.ListResourceRecordSetsOutput, bool) bool) error {
+	if m.ListResourceRecordSetsPagesFunc != nil {
+		return m.ListResourceRecordSetsPagesFunc(input, fn)
+	}
+
+	return errors.New("ListResourceRecordSetsPages not mocked")
+}
+
+func (m *MockRoute53) ChangeResourceRecordSets(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+	if m.ChangeResourceRecordSetsFunc != nil {
+		return m.ChangeResourceRecordSetsFunc(input)
+	}
+
+	return nil, errors.New("ChangeResourceRecordSets not mocked")
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"crypto/sha256"
 	"dns-api-go/internal/common"
+	"encoding/hex"
 	"encoding/json"
 )
 
@@ -22,3 +24,11 @@ func (e Entity) ToBluecatJSON() ([]byte, error) {
 	}
 	return json.Marshal(bluecatEntity)
 }
+
+// ETag returns a strong entity tag of the content of the entity for conditional requests.
+// Properties are encoded in the order of their keys, so equal entities always have the same tag.
+func (e Entity) ETag() string {
+	content, _ := json.Marshal(e)
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
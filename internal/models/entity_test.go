@@ -46,3 +46,15 @@ func TestToBluecatJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestETag(t *testing.T) {
+	entity := Entity{ID: 1, Name: "www", Type: "HostRecord", Properties: map[string]string{"ttl": "300", "absoluteName": "www.example.com"}}
+	same := Entity{ID: 1, Name: "www", Type: "HostRecord", Properties: map[string]string{"absoluteName": "www.example.com", "ttl": "300"}}
+	changed := Entity{ID: 1, Name: "www", Type: "HostRecord", Properties: map[string]string{"absoluteName": "www.example.com", "ttl": "600"}}
+
+	common.CheckResponse(t, "Equal entities", entity.ETag(), same.ETag())
+	if entity.ETag() == changed.ETag() {
+		t.Error("expected a different tag for a changed entity")
+	}
+	common.CheckResponse(t, "Quoted tag", 34, len(entity.ETag()))
+}
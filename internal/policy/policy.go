@@ -0,0 +1,240 @@
+// Package policy decides which names and networks the clients of the API may change.
+package policy
+
+import (
+	"dns-api-go/internal/common"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The actions that policies control
+const (
+	CreateRecord = "records:create"
+	UpdateRecord = "records:update"
+	DeleteRecord = "records:delete"
+	AssignIp     = "ips:assign"
+	ReleaseIp    = "ips:release"
+)
+
+// Request is a change that a client requests.
+// Networks holds the addresses and the CIDRs of the networks that the change touches.
+type Request struct {
+	Client     string
+	Action     string
+	Name       string
+	RecordType string
+	Networks   []string
+}
+
+func (r Request) String() string {
+	var parts []string
+	for _, part := range []string{r.Action, r.RecordType, r.Name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(r.Networks) > 0 {
+		parts = append(parts, "in "+strings.Join(r.Networks, ", "))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ErrDenied is returned when a request is denied by a rule, or no rule allows it
+type ErrDenied struct {
+	Request Request
+	Rule    string
+}
+
+func (e *ErrDenied) Error() string {
+	if e.Rule != "" {
+		return fmt.Sprintf("policy rule '%s' denies client '%s' to %s", e.Rule, e.Request.Client, e.Request)
+	}
+	return fmt.Sprintf("no policy rule allows client '%s' to %s", e.Request.Client, e.Request)
+}
+
+// rule is a parsed policy rule. Empty conditions match every request.
+type rule struct {
+	name        string
+	allow       bool
+	clients     []string
+	actions     []string
+	suffixes    []string
+	recordTypes []string
+	networks    []*net.IPNet
+}
+
+// Engine evaluates the rules of a policy in order, the first rule that matches a request allows or denies it.
+// Requests that no rule matches are denied. A nil Engine allows every request.
+type Engine struct {
+	rules []rule
+}
+
+// New parses the rules of a policy. The CIDRs of the rules can also name the networks of the CIDR file, which
+// maps CIDRs to their names.
+func New(rules []common.PolicyRule, cidrNames map[string]string) (*Engine, error) {
+	e := &Engine{}
+	for i, r := range rules {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+
+		parsed := rule{
+			name:        name,
+			clients:     r.Clients,
+			actions:     r.Actions,
+			recordTypes: r.RecordTypes,
+		}
+		switch strings.ToLower(r.Effect) {
+		case "", "allow":
+			parsed.allow = true
+		case "deny":
+		default:
+			return nil, fmt.Errorf("invalid effect '%s' of policy rule '%s'", r.Effect, name)
+		}
+
+		for _, suffix := range r.Suffixes {
+			parsed.suffixes = append(parsed.suffixes, normalizeName(suffix))
+		}
+		for _, cidr := range r.CIDRs {
+			network, err := parseNetwork(cidr, cidrNames)
+			if err != nil {
+				return nil, fmt.Errorf("invalid network of policy rule '%s': %v", name, err)
+			}
+			parsed.networks = append(parsed.networks, network)
+		}
+
+		e.rules = append(e.rules, parsed)
+	}
+	return e, nil
+}
+
+// Evaluate returns an ErrDenied if the policy does not allow the request
+func (e *Engine) Evaluate(req Request) error {
+	if e == nil {
+		return nil
+	}
+	for _, r := range e.rules {
+		if !r.matches(req) {
+			continue
+		}
+		if r.allow {
+			return nil
+		}
+		return &ErrDenied{Request: req, Rule: r.name}
+	}
+	return &ErrDenied{Request: req}
+}
+
+// matches checks whether the request meets all the conditions of the rule.
+// A condition on an attribute that the request does not have, e.g. a suffix for the release of an address,
+// does not match.
+func (r rule) matches(req Request) bool {
+	if len(r.clients) > 0 && !common.Contains(r.clients, req.Client) && !common.Contains(r.clients, "*") {
+		return false
+	}
+	if len(r.actions) > 0 && !matchesAction(r.actions, req.Action) {
+		return false
+	}
+	if len(r.recordTypes) > 0 && !containsFold(r.recordTypes, req.RecordType) {
+		return false
+	}
+	if len(r.suffixes) > 0 && !matchesSuffix(r.suffixes, normalizeName(req.Name)) {
+		return false
+	}
+	if len(r.networks) > 0 {
+		if len(req.Networks) == 0 {
+			return false
+		}
+		for _, n := range req.Networks {
+			if !containsNetwork(r.networks, n) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesAction checks whether the action is one of the actions, which can be "*" or wildcards like "ips:*"
+func matchesAction(actions []string, action string) bool {
+	resource, _, _ := strings.Cut(action, ":")
+	for _, a := range actions {
+		if a == "*" || a == action || a == resource+":*" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesSuffix checks whether the name is below one of the suffixes. "*.team.example.edu" matches the names
+// below team.example.edu, and "team.example.edu" also matches team.example.edu itself.
+func matchesSuffix(suffixes []string, name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, suffix := range suffixes {
+		if domain, ok := strings.CutPrefix(suffix, "*."); ok {
+			if strings.HasSuffix(name, "."+domain) {
+				return true
+			}
+			continue
+		}
+		if name == suffix || strings.HasSuffix(name, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsNetwork checks whether the address or network lies within one of the networks
+func containsNetwork(networks []*net.IPNet, value string) bool {
+	n, err := parseNetwork(value, nil)
+	if err != nil {
+		return false
+	}
+	ones, bits := n.Mask.Size()
+	for _, network := range networks {
+		networkOnes, networkBits := network.Mask.Size()
+		if network.Contains(n.IP) && bits == networkBits && ones >= networkOnes {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetwork parses an address, a CIDR or the name of a network of the CIDR file
+func parseNetwork(value string, cidrNames map[string]string) (*net.IPNet, error) {
+	value = strings.TrimSpace(value)
+	if ip := net.ParseIP(value); ip != nil {
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network, nil
+	}
+	for cidr, name := range cidrNames {
+		if name == value {
+			return parseNetwork(cidr, nil)
+		}
+	}
+	return nil, fmt.Errorf("unknown network '%s'", value)
+}
+
+// normalizeName lowercases a DNS name and removes its trailing dot
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// containsFold checks whether the slice contains the value regardless of case
+func containsFold(slice []string, value string) bool {
+	for _, s := range slice {
+		if strings.EqualFold(s, value) {
+			return true
+		}
+	}
+	return false
+}
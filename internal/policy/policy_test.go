@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"dns-api-go/internal/common"
+	"errors"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	engine, err := New([]common.PolicyRule{
+		{Name: "admins", Clients: []string{"admin"}},
+		{Name: "prod hosts", Effect: "deny", Actions: []string{"records:*"}, Suffixes: []string{"prod.team.example.edu"}, RecordTypes: []string{"HostRecord"}},
+		{Name: "team records", Clients: []string{"team"}, Actions: []string{CreateRecord, DeleteRecord}, Suffixes: []string{"*.team.example.edu"}},
+		{Name: "team ips", Clients: []string{"team"}, Actions: []string{"ips:*"}, CIDRs: []string{"prod-01-subnet", "10.1.0.0/16"}},
+	}, map[string]string{"192.168.16.0/22": "prod-01-subnet"})
+	common.CheckError(t, "New", nil, err)
+
+	tests := []struct {
+		name         string
+		request      Request
+		expectedRule string
+		allowed      bool
+	}{
+		{"Admin", Request{Client: "admin", Action: DeleteRecord, Name: "team.example.edu", RecordType: "HostRecord"}, "", true},
+		{"Record below suffix", Request{Client: "team", Action: CreateRecord, Name: "WWW.Team.Example.Edu.", RecordType: "CNAMERecord"}, "", true},
+		{"Host record below suffix", Request{Client: "team", Action: CreateRecord, Name: "db.team.example.edu", RecordType: "HostRecord", Networks: []string{"10.1.2.3"}}, "", true},
+		{"Denied subtree", Request{Client: "team", Action: CreateRecord, Name: "db.prod.team.example.edu", RecordType: "HostRecord"}, "prod hosts", false},
+		{"Apex below wildcard", Request{Client: "team", Action: CreateRecord, Name: "team.example.edu", RecordType: "TXTRecord"}, "", false},
+		{"Other suffix", Request{Client: "team", Action: CreateRecord, Name: "www.other.example.edu", RecordType: "HostRecord"}, "", false},
+		{"Lookalike suffix", Request{Client: "team", Action: CreateRecord, Name: "evilteam.example.edu", RecordType: "TXTRecord"}, "", false},
+		{"Network from CIDR file", Request{Client: "team", Action: AssignIp, Name: "db.team.example.edu", Networks: []string{"192.168.16.0/24"}}, "", true},
+		{"Release in own network", Request{Client: "team", Action: ReleaseIp, Networks: []string{"10.1.200.7"}}, "", true},
+		{"Wider network", Request{Client: "team", Action: AssignIp, Networks: []string{"192.168.0.0/16"}}, "", false},
+		{"Other network", Request{Client: "team", Action: ReleaseIp, Networks: []string{"10.2.0.1"}}, "", false},
+		{"Without network", Request{Client: "team", Action: ReleaseIp}, "", false},
+		{"Other client", Request{Client: "other", Action: CreateRecord, Name: "www.team.example.edu", RecordType: "TXTRecord"}, "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := engine.Evaluate(tc.request)
+			if tc.allowed {
+				common.CheckError(t, "Evaluate", nil, err)
+				return
+			}
+			var denied *ErrDenied
+			if !errors.As(err, &denied) {
+				t.Fatalf("expected the request to be denied, got %v", err)
+			}
+			common.CheckResponse(t, "Rule", tc.expectedRule, denied.Rule)
+		})
+	}
+
+	// Without a policy every request is allowed
+	var none *Engine
+	common.CheckError(t, "Evaluate", nil, none.Evaluate(Request{Client: "other", Action: ReleaseIp}))
+
+	for _, r := range []common.PolicyRule{{Effect: "maybe"}, {CIDRs: []string{"prod-99-subnet"}}} {
+		if _, err := New([]common.PolicyRule{r}, nil); err == nil {
+			t.Errorf("expected an error for policy rule %+v", r)
+		}
+	}
+}
+
+func TestDeniedMessage(t *testing.T) {
+	err := &ErrDenied{Request: Request{Client: "team", Action: CreateRecord, Name: "www.example.edu", RecordType: "HostRecord", Networks: []string{"10.0.0.5"}}}
+	common.CheckResponse(t, "Message", "no policy rule allows client 'team' to records:create HostRecord www.example.edu in 10.0.0.5", err.Error())
+	err.Rule = "prod hosts"
+	common.CheckResponse(t, "Message", "policy rule 'prod hosts' denies client 'team' to records:create HostRecord www.example.edu in 10.0.0.5", err.Error())
+}
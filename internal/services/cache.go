@@ -0,0 +1,245 @@
+package services
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/interfaces"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"fmt"
+	"github.com/patrickmn/go-cache"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dns_api",
+		Subsystem: "bluecat_cache",
+		Name:      "hits_total",
+		Help:      "Number of bluecat lookups served from the cache.",
+	}, []string{"lookup"})
+	cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "dns_api",
+		Subsystem: "bluecat_cache",
+		Name:      "misses_total",
+		Help:      "Number of bluecat lookups that were not found in the cache.",
+	}, []string{"lookup"})
+)
+
+// CACHEDTYPES are the types of the entities that rarely change and are cached for longer than the other entities
+var CACHEDTYPES = []string{
+	types.CONFIGURATION,
+	types.VIEW,
+	types.ZONE,
+	types.IP4NETWORK,
+}
+
+// LookupCache caches the configuration ID, the entities retrieved by ID, the entities of CACHEDTYPES for
+// longer than the others, and the zones and networks found by hint for the read-through TTL. The entities are
+// invalidated when they are changed through UpdateEntity or DeleteEntityByID, and the hint lookups when
+// records are created or entities are changed or deleted. A nil LookupCache caches nothing.
+type LookupCache struct {
+	cache            *cache.Cache
+	configurationTTL time.Duration
+	entityTTL        time.Duration
+	readThroughTTL   time.Duration
+}
+
+// NewLookupCache creates a lookup cache from the configuration, the defaults apply to the unset values.
+// nil is returned when caching is disabled.
+func NewLookupCache(c *common.Cache) (*LookupCache, error) {
+	if c == nil {
+		c = &common.Cache{}
+	}
+	if c.Disabled {
+		return nil, nil
+	}
+
+	lc := &LookupCache{
+		configurationTTL: time.Hour,
+		entityTTL:        5 * time.Minute,
+		readThroughTTL:   30 * time.Second,
+	}
+	for _, ttl := range []struct {
+		value string
+		dest  *time.Duration
+	}{
+		{c.ConfigurationTTL, &lc.configurationTTL},
+		{c.EntityTTL, &lc.entityTTL},
+		{c.ReadThroughTTL, &lc.readThroughTTL},
+	} {
+		if ttl.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(ttl.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache ttl '%s': %v", ttl.value, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("cache ttl must be positive")
+		}
+		*ttl.dest = d
+	}
+
+	lc.cache = cache.New(lc.entityTTL, 10*time.Minute)
+	return lc, nil
+}
+
+// cachingServer is implemented by servers that cache their bluecat lookups
+type cachingServer interface {
+	LookupCache() *LookupCache
+}
+
+// lookupCache returns the lookup cache of the server, or nil when the server does not cache its lookups
+func lookupCache(server interfaces.ServerInterface) *LookupCache {
+	if s, ok := server.(cachingServer); ok {
+		return s.LookupCache()
+	}
+	return nil
+}
+
+const (
+	configIdKey = "configId"
+	hintsPrefix = "hints/"
+)
+
+// entityKey is the key of an entity in the cache
+func entityKey(id int, includeHA bool) string {
+	return fmt.Sprintf("entity/%d/%t", id, includeHA)
+}
+
+// hintsKey is the key of the entities of a type found by hint in the cache
+func hintsKey(entityType string, start int, count int, options map[string]string) string {
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s%s/%d/%d", hintsPrefix, entityType, start, count)
+	for _, key := range keys {
+		fmt.Fprintf(&b, "|%s=%s", key, options[key])
+	}
+	return b.String()
+}
+
+// configId returns the cached configuration ID
+func (lc *LookupCache) configId() (int, bool) {
+	if lc == nil {
+		return 0, false
+	}
+	value, ok := lc.get("configuration", configIdKey)
+	if !ok {
+		return 0, false
+	}
+	return value.(int), true
+}
+
+// setConfigId caches the configuration ID
+func (lc *LookupCache) setConfigId(configId int) {
+	if lc == nil {
+		return
+	}
+	lc.cache.Set(configIdKey, configId, lc.configurationTTL)
+}
+
+// entity returns a copy of a cached entity
+func (lc *LookupCache) entity(id int, includeHA bool) (*models.Entity, bool) {
+	if lc == nil {
+		return nil, false
+	}
+	value, ok := lc.get("entity", entityKey(id, includeHA))
+	if !ok {
+		return nil, false
+	}
+	return copyEntity(value.(*models.Entity)), true
+}
+
+// setEntity caches a copy of an entity
+func (lc *LookupCache) setEntity(entity *models.Entity, includeHA bool) {
+	if lc == nil {
+		return
+	}
+	ttl := lc.readThroughTTL
+	if common.Contains(CACHEDTYPES, entity.Type) {
+		ttl = lc.entityTTL
+	}
+	lc.cache.Set(entityKey(entity.ID, includeHA), copyEntity(entity), ttl)
+}
+
+// Invalidate removes an entity from the cache, e.g. before checking a precondition against the entity
+// that another instance of the API may have changed
+func (lc *LookupCache) Invalidate(id int) {
+	if lc == nil {
+		return
+	}
+	lc.cache.Delete(entityKey(id, false))
+	lc.cache.Delete(entityKey(id, true))
+}
+
+// hints returns copies of the cached entities of a type found by hint
+func (lc *LookupCache) hints(entityType string, start int, count int, options map[string]string) ([]models.Entity, bool) {
+	if lc == nil {
+		return nil, false
+	}
+	value, ok := lc.get("hint", hintsKey(entityType, start, count, options))
+	if !ok {
+		return nil, false
+	}
+	return copyEntities(value.([]models.Entity)), true
+}
+
+// setHints caches copies of the entities of a type found by hint
+func (lc *LookupCache) setHints(entityType string, start int, count int, options map[string]string, entities []models.Entity) {
+	if lc == nil {
+		return
+	}
+	lc.cache.Set(hintsKey(entityType, start, count, options), copyEntities(entities), lc.readThroughTTL)
+}
+
+// invalidateHints removes the entities found by hint from the cache
+func (lc *LookupCache) invalidateHints() {
+	if lc == nil {
+		return
+	}
+	for key := range lc.cache.Items() {
+		if strings.HasPrefix(key, hintsPrefix) {
+			lc.cache.Delete(key)
+		}
+	}
+}
+
+// get looks up a key and counts the hit or miss of the lookup
+func (lc *LookupCache) get(lookup string, key string) (interface{}, bool) {
+	value, ok := lc.cache.Get(key)
+	if ok {
+		cacheHits.WithLabelValues(lookup).Inc()
+	} else {
+		cacheMisses.WithLabelValues(lookup).Inc()
+	}
+	return value, ok
+}
+
+// copyEntity copies an entity, so that changes to the properties of the copy do not reach the cache
+func copyEntity(entity *models.Entity) *models.Entity {
+	properties := make(map[string]string, len(entity.Properties))
+	for key, value := range entity.Properties {
+		properties[key] = value
+	}
+	c := *entity
+	c.Properties = properties
+	return &c
+}
+
+// copyEntities copies a list of entities with copyEntity
+func copyEntities(entities []models.Entity) []models.Entity {
+	c := make([]models.Entity, len(entities))
+	for i := range entities {
+		c[i] = *copyEntity(&entities[i])
+	}
+	return c
+}
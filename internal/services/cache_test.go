@@ -0,0 +1,119 @@
+package services
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/mocks"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"io"
+	"testing"
+)
+
+// cachingMockServer is a mock server with a lookup cache
+type cachingMockServer struct {
+	mocks.MockServer
+	lookups *LookupCache
+}
+
+func (s *cachingMockServer) LookupCache() *LookupCache {
+	return s.lookups
+}
+
+func TestLookupCache(t *testing.T) {
+	lookups, err := NewLookupCache(&common.Cache{EntityTTL: "1m"})
+	common.CheckError(t, "NewLookupCache", nil, err)
+
+	requests := map[string]int{}
+	server := &cachingMockServer{lookups: lookups}
+	server.MakeRequestFunc = func(method, route, queryParam string, body io.Reader) ([]byte, error) {
+		requests[method+" "+route]++
+		switch route {
+		case "/getEntities":
+			return []byte(`[{"id": 1, "name": "default", "type": "Configuration", "properties": ""}]`), nil
+		case "/getZonesByHint", "/getIP4NetworksByHint":
+			return []byte(`[{"id": 5, "name": "example.com", "type": "Zone", "properties": "absoluteName=example.com|"}]`), nil
+		case "/getEntityById":
+			if queryParam == "id=5&includeHA=false" {
+				return []byte(`{"id": 5, "name": "example.com", "type": "Zone", "properties": "absoluteName=example.com|"}`), nil
+			}
+			return []byte(`{"id": 6, "name": "www", "type": "HostRecord", "properties": "absoluteName=www.example.com|"}`), nil
+		}
+		return []byte(`null`), nil
+	}
+
+	hits := testutil.ToFloat64(cacheHits.WithLabelValues("configuration"))
+	for i := 0; i < 3; i++ {
+		configId, err := GetConfigID(server)
+		common.CheckError(t, "GetConfigID", nil, err)
+		common.CheckResponse(t, "Configuration ID", 1, configId)
+	}
+	common.CheckResponse(t, "Configuration requests", 1, requests["GET /getEntities"])
+	common.CheckResponse(t, "Configuration hits", hits+2, testutil.ToFloat64(cacheHits.WithLabelValues("configuration")))
+
+	// Zones are cached, and changes to the returned entity do not reach the cache
+	zone, err := GetEntityByID(server, 5, false, nil)
+	common.CheckError(t, "GetEntityByID", nil, err)
+	zone.Properties["absoluteName"] = "changed.com"
+	zone, err = GetEntityByID(server, 5, false, nil)
+	common.CheckError(t, "GetEntityByID", nil, err)
+	common.CheckResponse(t, "Cached zone", "example.com", zone.Properties["absoluteName"])
+	common.CheckResponse(t, "Zone requests", 1, requests["GET /getEntityById"])
+
+	// Other entities are read through the cache as well
+	for i := 0; i < 2; i++ {
+		_, err = GetEntityByID(server, 6, false, nil)
+		common.CheckError(t, "GetEntityByID", nil, err)
+	}
+	common.CheckResponse(t, "Record requests", 2, requests["GET /getEntityById"])
+
+	// Updates and deletes invalidate the cached entity
+	common.CheckError(t, "UpdateEntity", nil, UpdateEntity(server, zone))
+	_, err = GetEntityByID(server, 5, false, nil)
+	common.CheckError(t, "GetEntityByID", nil, err)
+	common.CheckResponse(t, "Requests after update", 3, requests["GET /getEntityById"])
+
+	common.CheckError(t, "DeleteEntityByID", nil, DeleteEntityByID(server, 6, nil))
+	common.CheckResponse(t, "Requests for delete", 3, requests["GET /getEntityById"])
+	_, err = GetEntityByID(server, 6, false, nil)
+	common.CheckError(t, "GetEntityByID", nil, err)
+	common.CheckResponse(t, "Requests after delete", 4, requests["GET /getEntityById"])
+
+	// Zones and networks found by hint are cached by type and options until a record is deleted
+	zoneService, networkService := NewZoneService(server), NewNetworkService(server)
+	hint := map[string]string{"hint": "example"}
+	for i := 0; i < 2; i++ {
+		zones, err := zoneService.GetEntitiesByHint(0, 10, hint)
+		common.CheckError(t, "GetEntitiesByHint", nil, err)
+		(*zones)[0].Properties["absoluteName"] = "changed.com"
+	}
+	zones, err := zoneService.GetEntitiesByHint(0, 10, hint)
+	common.CheckError(t, "GetEntitiesByHint", nil, err)
+	common.CheckResponse(t, "Cached zones", "example.com", (*zones)[0].Properties["absoluteName"])
+	common.CheckResponse(t, "Zone hint requests", 1, requests["GET /getZonesByHint"])
+	_, err = zoneService.GetEntitiesByHint(0, 10, map[string]string{"hint": "other"})
+	common.CheckError(t, "GetEntitiesByHint", nil, err)
+	_, err = networkService.GetEntitiesByHint(0, 10, hint)
+	common.CheckError(t, "GetEntitiesByHint", nil, err)
+	common.CheckResponse(t, "Zone hint requests for other options", 2, requests["GET /getZonesByHint"])
+	common.CheckResponse(t, "Network hint requests", 1, requests["GET /getIP4NetworksByHint"])
+
+	common.CheckError(t, "DeleteEntityByID", nil, DeleteEntityByID(server, 6, nil))
+	_, err = zoneService.GetEntitiesByHint(0, 10, hint)
+	common.CheckError(t, "GetEntitiesByHint", nil, err)
+	common.CheckResponse(t, "Zone hint requests after delete", 3, requests["GET /getZonesByHint"])
+
+	// Servers without a cache and a disabled cache always ask bluecat
+	lookups, err = NewLookupCache(&common.Cache{Disabled: true})
+	common.CheckError(t, "Disabled cache", nil, err)
+	server.lookups = lookups
+	for i := 0; i < 2; i++ {
+		_, err := GetConfigID(server)
+		common.CheckError(t, "GetConfigID", nil, err)
+	}
+	common.CheckResponse(t, "Uncached configuration requests", 3, requests["GET /getEntities"])
+
+	for _, c := range []*common.Cache{{EntityTTL: "soon"}, {ConfigurationTTL: "-1m"}} {
+		if _, err := NewLookupCache(c); err == nil {
+			t.Errorf("expected an error for cache configuration %+v", *c)
+		}
+	}
+}
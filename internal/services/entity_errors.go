@@ -31,6 +31,15 @@ func (e *ErrEntityTypeMismatch) Error() string {
 		strings.Join(e.ExpectedTypes, ", "), e.ActualType)
 }
 
+// ErrAmbiguousID indicates that more than one entity has the ID, so that the entity cannot be told apart
+type ErrAmbiguousID struct {
+	ID int
+}
+
+func (e *ErrAmbiguousID) Error() string {
+	return fmt.Sprintf("more than one entity has the id %d", e.ID)
+}
+
 // ErrEntityAlreadyExists indicates the entity already exists
 type ErrEntityAlreadyExists struct {
 	EntityID string
@@ -39,3 +48,22 @@ type ErrEntityAlreadyExists struct {
 func (e *ErrEntityAlreadyExists) Error() string {
 	return fmt.Sprintf("entity already exists: %s", e.EntityID)
 }
+
+// ErrTransactionFailed is returned when a step of a multi-step operation failed and the previous steps were rolled back
+type ErrTransactionFailed struct {
+	Operation   string
+	Changes     int
+	Err         error
+	RollbackErr error
+}
+
+func (e *ErrTransactionFailed) Error() string {
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("%s failed: %v; rollback of %d change(s) failed: %v", e.Operation, e.Err, e.Changes, e.RollbackErr)
+	}
+	return fmt.Sprintf("%s failed: %v; %d change(s) rolled back", e.Operation, e.Err, e.Changes)
+}
+
+func (e *ErrTransactionFailed) Unwrap() error {
+	return e.Err
+}
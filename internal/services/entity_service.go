@@ -1,13 +1,11 @@
 package services
 
 import (
+	"dns-api-go/internal/bluecat"
 	"dns-api-go/internal/interfaces"
 	"dns-api-go/internal/models"
 	"dns-api-go/logger"
-	"encoding/json"
-	"fmt"
 	"go.uber.org/zap"
-	"net/url"
 )
 
 type BaseEntityService interface {
@@ -60,36 +58,12 @@ func (es *BaseService) CustomSearch(start int, count int, filters map[string]str
 		zap.Any("options", options),
 		zap.String("objectType", objectType))
 
-	// Construct route and query parameters
-	route := "/customSearch"
-	queryParams := url.Values{}
-	queryParams.Set("start", fmt.Sprintf("%d", start))
-	queryParams.Set("count", fmt.Sprintf("%d", count))
-	queryParams.Set("type", objectType)
-	queryParams.Set("includeHA", "false")
-	for key, value := range filters {
-		queryParams.Add("filters", fmt.Sprintf("%s=%s", key, value))
-	}
-	for _, option := range options {
-		queryParams.Add("options", option)
-	}
-
 	// Send http request to bluecat
-	resp, err := es.server.MakeRequest("GET", route, queryParams.Encode(), nil)
+	entities, err := bluecat.NewClient(es.server).CustomSearch(objectType, filters, options, start, count)
 	if err != nil {
 		return nil, err
 	}
 
-	// Unmarshal the response
-	var entitiesResp []models.BluecatEntity
-	if err := json.Unmarshal(resp, &entitiesResp); err != nil {
-		logger.Error("Error unmarshalling entities response", zap.Error(err))
-		return nil, err
-	}
-
-	// For each entity response, convert it to an entity
-	entities := models.ConvertToEntities(entitiesResp)
-
 	logger.Info("CustomSearch successful", zap.Int("count", len(entities)))
 	return &entities, nil
 }
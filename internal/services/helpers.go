@@ -1,21 +1,26 @@
 package services
 
 import (
+	"dns-api-go/internal/bluecat"
 	"dns-api-go/internal/common"
 	"dns-api-go/internal/interfaces"
 	"dns-api-go/internal/models"
 	"dns-api-go/internal/types"
 	"dns-api-go/logger"
-	"encoding/json"
 	"fmt"
 	"go.uber.org/zap"
-	"strings"
 )
 
-// GetConfigID retrieves the configuration ID from Bluecat.
+// GetConfigID retrieves the configuration ID from Bluecat, or from the lookup cache of the server.
 func GetConfigID(server interfaces.ServerInterface) (int, error) {
 	logger.Info("GetConfigID started")
 
+	lookups := lookupCache(server)
+	if configId, ok := lookups.configId(); ok {
+		logger.Info("GetConfigID successful", zap.Int("configId", configId), zap.Bool("cached", true))
+		return configId, nil
+	}
+
 	containers, err := GetEntities(server, 0, 1, 0, types.CONFIGURATION, false)
 	if err != nil {
 		return 0, err
@@ -24,6 +29,7 @@ func GetConfigID(server interfaces.ServerInterface) (int, error) {
 		return 0, fmt.Errorf("failed to retrieve containerId")
 	}
 	configId := (*containers)[0].ID
+	lookups.setConfigId(configId)
 
 	logger.Info("GetConfigID successful", zap.Int("configId", configId))
 	return configId, nil
@@ -34,61 +40,41 @@ func GetParentID(server interfaces.ServerInterface, entityId int) (int, error) {
 	logger.Info("GetParentID started", zap.Int("entityId", entityId))
 
 	// Send http request to bluecat
-	route, params := "/getParent", fmt.Sprintf("entityId=%d", entityId)
-	resp, err := server.MakeRequest("GET", route, params, nil)
+	parentEntity, err := bluecat.NewClient(server).GetParent(entityId)
 	if err != nil {
+		if bluecat.IsNotFound(err) {
+			logger.Info("Entity not found", zap.Int("entity id", entityId))
+			return -1, &ErrEntityNotFound{}
+		}
 		logger.Error("Error getting parent ID", zap.Error(err), zap.Int("entityId", entityId))
 		return -1, err
 	}
 
-	// Unmarshal the response
-	var bluecatEntity models.BluecatEntity
-	if err := json.Unmarshal(resp, &bluecatEntity); err != nil {
-		logger.Error("Error unmarshalling entity response", zap.Error(err))
-		return -1, err
-	}
-
-	// Check if the response represents an empty entity
-	if bluecatEntity.IsEmpty() {
-		logger.Info("Entity not found", zap.Int("entity id", entityId))
-		return -1, &ErrEntityNotFound{}
-	}
-
-	// Convert BluecatEntity to Entity
-	parentEntity := bluecatEntity.ToEntity()
-
 	logger.Info("GetParentID successful", zap.Int("parentId", parentEntity.ID))
 	return parentEntity.ID, nil
 }
 
 // GetEntityByID Retrieves an entity by ID from bluecat
+// Every entity is read through the lookup cache of the server: entities of the CACHEDTYPES are cached for the
+// entity TTL and all others for the shorter read-through TTL, 30 seconds by default.
 func GetEntityByID(server interfaces.ServerInterface, id int, includeHA bool, expectedTypes []string) (*models.Entity, error) {
-	// Send http request to bluecat
-	route, params := "/getEntityById", fmt.Sprintf("id=%d&includeHA=%t", id, includeHA)
-	resp, err := server.MakeRequest("GET", route, params, nil)
-
-	// Check for errors when sending request
-	if err != nil {
-		logger.Error("Error getting entity by ID", zap.Error(err), zap.Int("id", id))
-		return nil, err
-	}
-	logger.Info("Received response for GetEntityByID", zap.ByteString("response", resp))
-
-	// Unmarshal the response
-	var bluecatEntity models.BluecatEntity
-	if err := json.Unmarshal(resp, &bluecatEntity); err != nil {
-		logger.Error("Error unmarshalling entity response", zap.Error(err))
-		return nil, err
-	}
-	// Check if the response represents an empty entity
-	if bluecatEntity.IsEmpty() {
-		logger.Info("Entity not found", zap.Int("id", id))
-		return nil, &ErrEntityNotFound{}
+	lookups := lookupCache(server)
+	entity, ok := lookups.entity(id, includeHA)
+	if !ok {
+		// Send http request to bluecat
+		var err error
+		entity, err = bluecat.NewClient(server).GetEntityById(id, includeHA)
+		if err != nil {
+			if bluecat.IsNotFound(err) {
+				logger.Info("Entity not found", zap.Int("id", id))
+				return nil, &ErrEntityNotFound{}
+			}
+			logger.Error("Error getting entity by ID", zap.Error(err), zap.Int("id", id))
+			return nil, err
+		}
+		lookups.setEntity(entity, includeHA)
 	}
 
-	// Convert BluecatEntity to Entity
-	entity := bluecatEntity.ToEntity()
-
 	// Check if the entity type is one of the expected types
 	if len(expectedTypes) > 0 && !common.Contains(expectedTypes, entity.Type) {
 		logger.Error("Entity type does not match expected types",
@@ -100,13 +86,18 @@ func GetEntityByID(server interfaces.ServerInterface, id int, includeHA bool, ex
 	logger.Info("GetEntityByID successful",
 		zap.Int("entityID", entity.ID),
 		zap.String("entityType", entity.Type))
-	return &entity, nil
+	return entity, nil
 }
 
 var ALLOWDELETE = []string{
 	types.HOSTRECORD,
 	types.EXTERNALHOST,
 	types.CNAMERECORD,
+	types.MXRECORD,
+	types.TXTRECORD,
+	types.SRVRECORD,
+	types.GENERICRECORD,
+	types.HINFORECORD,
 	types.IP4ADDRESS,
 	types.MACADDRESS,
 	types.MACPOOL,
@@ -137,10 +128,10 @@ func DeleteEntityByID(server interfaces.ServerInterface, id int, expectedTypes [
 	}
 
 	// Send http request to bluecat
-	route, params := "/delete", fmt.Sprintf("objectId=%d", id)
-	_, err = server.MakeRequest("DELETE", route, params, nil)
-
-	// Check for errors while sending request
+	err = bluecat.NewClient(server).Delete(id)
+	lookups := lookupCache(server)
+	lookups.Invalidate(id)
+	lookups.invalidateHints()
 	if err != nil {
 		logger.Error("Error deleting entity", zap.Error(err), zap.Int("id", id))
 		return err
@@ -154,19 +145,11 @@ func DeleteEntityByID(server interfaces.ServerInterface, id int, expectedTypes [
 func UpdateEntity(server interfaces.ServerInterface, entity *models.Entity) error {
 	logger.Info("UpdateEntity started", zap.Int("entityID", entity.ID))
 
-	bluecatEntityJSON, err := entity.ToBluecatJSON()
-	if err != nil {
-		logger.Error("Error marshalling entity to JSON for Bluecat", zap.Error(err))
-	}
-
-	// Create an io.Reader from the JSON string
-	body := strings.NewReader(string(bluecatEntityJSON))
-
 	// Send http request to bluecat
-	route := "/update"
-	_, err = server.MakeRequest("PUT", route, "", body)
-
-	// Check for errors when sending request
+	err := bluecat.NewClient(server).Update(entity)
+	lookups := lookupCache(server)
+	lookups.Invalidate(entity.ID)
+	lookups.invalidateHints()
 	if err != nil {
 		logger.Error("Error updating entity", zap.Error(err), zap.Int("entityID", entity.ID))
 		return err
@@ -176,40 +159,35 @@ func UpdateEntity(server interfaces.ServerInterface, entity *models.Entity) erro
 	return nil
 }
 
-// GetEntitiesByHintHelper retrieves entities by hint, given a specific route.
+// hintLister is a bluecat call that searches the entities of a container by hint
+type hintLister func(client bluecat.API, containerId int, start int, count int, options map[string]string) ([]models.Entity, error)
+
+// GetEntitiesByHintHelper retrieves entities by hint, given a specific bluecat call.
 // Many of the entity retrieval functions in across the different services use this helper function because they share the same logic
-func GetEntitiesByHintHelper(server interfaces.ServerInterface, route string, start int, count int, options map[string]string) (*[]models.Entity, error) {
+// The entities are read through the lookup cache of the server for the read-through TTL, keyed by their type.
+func GetEntitiesByHintHelper(server interfaces.ServerInterface, entityType string, list hintLister, start int, count int, options map[string]string) (*[]models.Entity, error) {
 	logger.Info("GetEntitiesByHint started",
 		zap.Int("start", start),
 		zap.Int("count", count),
 		zap.Any("options", options))
 
-	// Use Configuration ID as the container ID
-	containerId, err := GetConfigID(server)
-	if err != nil {
-		return nil, err
-	}
-
-	// Construct the request parameters
-	params := fmt.Sprintf("containerId=%d&start=%d&count=%d", containerId, start, count)
-	params += "&options=" + common.ConvertToSeparatedString(options, "|")
-
-	// Use the configuration ID to call the Bluecat API to get entities
-	resp, err := server.MakeRequest("GET", route, params, nil)
-	if err != nil {
-		return nil, err
-	}
+	lookups := lookupCache(server)
+	entities, ok := lookups.hints(entityType, start, count, options)
+	if !ok {
+		// Use Configuration ID as the container ID
+		containerId, err := GetConfigID(server)
+		if err != nil {
+			return nil, err
+		}
 
-	// Unmarshal the response
-	var entitiesResp []models.BluecatEntity
-	if err := json.Unmarshal(resp, &entitiesResp); err != nil {
-		logger.Error("Error unmarshalling entities response", zap.Error(err))
-		return nil, err
+		// Use the configuration ID to call the Bluecat API to get entities
+		entities, err = list(bluecat.NewClient(server), containerId, start, count, options)
+		if err != nil {
+			return nil, err
+		}
+		lookups.setHints(entityType, start, count, options, entities)
 	}
 
-	// For each entity response, convert it to an entity
-	entities := models.ConvertToEntities(entitiesResp)
-
 	logger.Info("GetEntitiesByHint successful", zap.Int("count", len(entities)))
 	return &entities, nil
 }
@@ -225,26 +203,11 @@ func GetEntities(server interfaces.ServerInterface, start int, count int, parent
 		zap.Bool("includeHA", includeHA))
 
 	// Send http request to bluecat
-	route := "/getEntities"
-	params := fmt.Sprintf("start=%d&count=%d&parentId=%d&type=%s&includeHA=%t",
-		start, count, parentId, entityType, includeHA)
-	resp, err := server.MakeRequest("GET", route, params, nil)
-
-	// Check for errors when sending request
+	entities, err := bluecat.NewClient(server).GetEntities(parentId, entityType, start, count, includeHA)
 	if err != nil {
 		return nil, err
 	}
 
-	// Unmarshal the response
-	var entitiesResp []models.BluecatEntity
-	if err := json.Unmarshal(resp, &entitiesResp); err != nil {
-		logger.Error("Error unmarshalling entities response", zap.Error(err))
-		return nil, err
-	}
-
-	// For each entity response, convert it to an entity
-	entities := models.ConvertToEntities(entitiesResp)
-
 	logger.Info("GetEntities successful", zap.Int("count", len(entities)))
 	return &entities, nil
 }
@@ -253,32 +216,17 @@ func GetEntityByName(server interfaces.ServerInterface, name string, entityType
 	logger.Info("GetEntityByName started", zap.String("name", name), zap.String("entityType", entityType))
 
 	// Send http request to bluecat
-	route := "/getEntityByName"
-	params := fmt.Sprintf("name=%s&type=%s&includeHA=%t&parentId=%d", name, entityType, includeHA, parentId)
-
-	resp, err := server.MakeRequest("GET", route, params, nil)
+	entity, err := bluecat.NewClient(server).GetEntityByName(parentId, name, entityType, includeHA)
 	if err != nil {
+		if bluecat.IsNotFound(err) {
+			logger.Info("Entity not found", zap.String("name", name))
+			return nil, &ErrEntityNotFound{}
+		}
 		return nil, err
 	}
 
-	// Unmarshal the response
-	var bluecatEntity models.BluecatEntity
-	if err := json.Unmarshal(resp, &bluecatEntity); err != nil {
-		logger.Error("Error unmarshalling entity response", zap.Error(err))
-		return nil, err
-	}
-
-	// Check if the response represents an empty entity
-	if bluecatEntity.IsEmpty() {
-		logger.Info("Entity not found", zap.String("name", name))
-		return nil, &ErrEntityNotFound{}
-	}
-
-	// Convert BluecatEntity to Entity
-	entity := bluecatEntity.ToEntity()
-
 	logger.Info("GetEntityByName successful", zap.Int("entityID", entity.ID))
-	return &entity, nil
+	return entity, nil
 }
 
 func searchObjectByTypes(server interfaces.ServerInterface, keyword string, start int, count int, includeHA bool, types []string) (*[]models.Entity, error) {
@@ -288,30 +236,12 @@ func searchObjectByTypes(server interfaces.ServerInterface, keyword string, star
 		zap.Int("count", count),
 		zap.Strings("types", types))
 
-	// Convert types array to a comma-separated string
-	typesStr := strings.Join(types, ",")
-
 	// Send http request to bluecat
-	route := "/searchObjectByTypes"
-	params := fmt.Sprintf("keyword=%s&start=%d&count=%d&includeHA=%t&types=%s",
-		keyword, start, count, includeHA, typesStr)
-	resp, err := server.MakeRequest("GET", route, params, nil)
-
-	// Check for errors when sending request
+	entities, err := bluecat.NewClient(server).SearchObjectByTypes(keyword, types, start, count, includeHA)
 	if err != nil {
 		return nil, err
 	}
 
-	// Unmarshal the response
-	var entitiesResp []models.BluecatEntity
-	if err := json.Unmarshal(resp, &entitiesResp); err != nil {
-		logger.Error("Error unmarshalling entities response", zap.Error(err))
-		return nil, err
-	}
-
-	// For each entity response, convert it to an entity
-	entities := models.ConvertToEntities(entitiesResp)
-
 	logger.Info("searchObjectByTypes successful", zap.Int("count", len(entities)))
 	return &entities, nil
 }
@@ -1,15 +1,14 @@
 package services
 
 import (
-	"dns-api-go/internal/common"
+	"dns-api-go/internal/bluecat"
 	"dns-api-go/internal/interfaces"
 	"dns-api-go/internal/models"
 	"dns-api-go/internal/types"
 	"dns-api-go/logger"
-	"encoding/json"
 	"fmt"
 	"go.uber.org/zap"
-	"net/url"
+	"strconv"
 )
 
 type IpAddressEntityService interface {
@@ -38,31 +37,17 @@ func (ips *IpAddressService) GetIpAddress(address string) (*models.Entity, error
 	}
 
 	// Send http request to bluecat
-	route, params := "/getIP4Address", fmt.Sprintf("address=%s&containerId=%d", address, containerId)
-	resp, err := ips.server.MakeRequest("GET", route, params, nil)
+	entity, err := bluecat.NewClient(ips.server).GetIP4Address(containerId, address)
 	if err != nil {
+		if bluecat.IsNotFound(err) {
+			logger.Info("Entity not found", zap.String("ip address", address))
+			return nil, &ErrEntityNotFound{}
+		}
 		return nil, err
 	}
-	logger.Info("Received response for GetIpAddress", zap.ByteString("response", resp))
-
-	// Unmarshal the response
-	var bluecatEntity models.BluecatEntity
-	if err := json.Unmarshal(resp, &bluecatEntity); err != nil {
-		logger.Error("Error unmarshalling entity response", zap.Error(err))
-		return nil, err
-	}
-
-	// Check if the response represents an empty entity
-	if bluecatEntity.IsEmpty() {
-		logger.Info("Entity not found", zap.String("ip address", address))
-		return nil, &ErrEntityNotFound{}
-	}
-
-	// Convert BluecatEntity to Entity
-	entity := bluecatEntity.ToEntity()
 
 	logger.Info("GetIpAddress successfull", zap.String("ip address", address))
-	return &entity, nil
+	return entity, nil
 }
 
 // DeleteIpAddress deletes an ip address from bluecat
@@ -86,7 +71,8 @@ func (ips *IpAddressService) DeleteIpAddress(address string) error {
 	return nil
 }
 
-// AssignIpAddress assigns the next available ipv4 address to a mac address in bluecat
+// AssignIpAddress assigns the next available ipv4 address to a mac address in bluecat. When a hostname is given, the
+// address is linked to a host record of the hostname and released again if the host record cannot be added.
 func (ips *IpAddressService) AssignIpAddress(action string, macAddress string, parentId int, hostInfo map[string]string, properties map[string]string) (*models.Entity, error) {
 	logger.Info("AssignIpAddress started", zap.String("action", action), zap.String("mac address", macAddress))
 
@@ -96,36 +82,43 @@ func (ips *IpAddressService) AssignIpAddress(action string, macAddress string, p
 		return nil, err
 	}
 
-	// Create hostInfo string
-	hostInfoString := fmt.Sprintf("%s,%s,%s,%s",
-		hostInfo["hostname"],
-		hostInfo["viewId"],
-		hostInfo["reverseFlag"],
-		hostInfo["sameAsZoneFlag"])
+	// Create hostInfo string, bluecat only creates a linked host record when a hostname is given. The host record is
+	// added after the address when the API of bluecat cannot create it along with the address.
+	hostname := hostInfo["hostname"]
+	addHostRecord := hostname != "" && !bluecat.AssignsHostRecords(ips.server)
+	var hostInfoString string
+	if hostname != "" && !addHostRecord {
+		hostInfoString = fmt.Sprintf("%s,%s,%s,%s",
+			hostname,
+			hostInfo["viewId"],
+			hostInfo["reverseFlag"],
+			hostInfo["sameAsZoneFlag"])
+	}
 
-	// Create properties string
-	propertiesString := common.ConvertToSeparatedString(properties, "|")
+	tx := NewTransaction("assign ip address to " + hostname)
+	client := bluecat.NewClient(ips.server)
 
 	// Send http request to bluecat
-	route := "/assignNextAvailableIP4Address"
-	params := fmt.Sprintf("action=%s&configurationId=%d&hostInfo=%s&macAddress=%s&parentId=%d&properties=%s",
-		action, configId, url.QueryEscape(hostInfoString), url.QueryEscape(macAddress), parentId, url.QueryEscape(propertiesString))
-	resp, err := ips.server.MakeRequest("POST", route, params, nil)
+	entity, err := client.AssignNextAvailableIP4Address(configId, parentId, action, macAddress, hostInfoString, properties)
 	if err != nil {
 		return nil, err
 	}
-	logger.Info("Received response for AssignIpAddress", zap.ByteString("response", resp))
 
-	// Unmarshal the response
-	var bluecatEntity models.BluecatEntity
-	if err := json.Unmarshal(resp, &bluecatEntity); err != nil {
-		logger.Error("Error unmarshalling entity response", zap.Error(err))
-		return nil, err
+	if addHostRecord {
+		tx.OnRollback(RollbackTask(func() error {
+			return DeleteEntityByID(ips.server, entity.ID, []string{types.IP4ADDRESS})
+		}))
+
+		viewId, err := strconv.Atoi(hostInfo["viewId"])
+		if err != nil {
+			return nil, tx.Fail(fmt.Errorf("invalid view id '%s'", hostInfo["viewId"]))
+		}
+		reverse := map[string]string{"reverseRecord": hostInfo["reverseFlag"]}
+		if _, err := client.AddHostRecord(viewId, hostname, []string{entity.Properties["address"]}, -1, reverse); err != nil {
+			return nil, tx.Fail(err)
+		}
 	}
 
-	// Convert BluecatEntity to Entity
-	entity := bluecatEntity.ToEntity()
-
 	logger.Info("AssignIpAddress successfull", zap.Int("entity id", entity.ID))
-	return &entity, nil
+	return entity, nil
 }
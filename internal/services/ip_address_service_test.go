@@ -0,0 +1,60 @@
+package services
+
+import (
+	"dns-api-go/internal/bluecat"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/mocks"
+	"io"
+	"testing"
+)
+
+// v2Server answers the requests of the v2 API with canned responses by method and route
+type v2Server struct {
+	mocks.MockServer
+	responses map[string]string
+	requests  []string
+}
+
+func (s *v2Server) APIVersion() string {
+	return bluecat.V2
+}
+
+func (s *v2Server) MakeRequest(method, route, _ string, _ io.Reader) ([]byte, error) {
+	request := method + " " + route
+	s.requests = append(s.requests, request)
+	resp, ok := s.responses[request]
+	if !ok {
+		return nil, &bluecat.StatusError{StatusCode: 404, Body: `{"status":404,"reason":"Not Found"}`}
+	}
+	return []byte(resp), nil
+}
+
+func TestAssignIpAddressV2(t *testing.T) {
+	address := `{"id": 40, "type": "IPv4Address", "name": "host1", "address": "10.0.0.2", "_links": {"self": {"href": "/api/v2/addresses/40"}}}`
+	hostInfo := map[string]string{"hostname": "host1.example.com", "viewId": "3", "reverseFlag": "true", "sameAsZoneFlag": "false"}
+
+	// The address is released again when its host record cannot be added
+	server := &v2Server{responses: map[string]string{
+		"GET /api/v2/configurations":        `{"count": 1, "data": [{"id": 1, "type": "Configuration", "name": "test", "_links": {"self": {"href": "/api/v2/configurations/1"}}}]}`,
+		"POST /api/v2/networks/5/addresses": address,
+		"GET /api/v2/entities/40":           address,
+		"DELETE /api/v2/addresses/40":       `{}`,
+	}}
+	_, err := NewIpAddressService(server).AssignIpAddress("MAKE_STATIC", "00:11:22:33:44:55", 5, hostInfo, nil)
+	e, ok := err.(*ErrTransactionFailed)
+	if !ok {
+		t.Fatalf("expected a transaction error, got %v", err)
+	}
+	common.CheckResponse(t, "Rollback error", nil, e.RollbackErr)
+	common.CheckResponse(t, "Released address", "DELETE /api/v2/addresses/40", server.requests[len(server.requests)-1])
+
+	// The host record is added after the address
+	server.requests = nil
+	server.responses["POST /api/v2/views/3/resourceRecords"] = `{"id": 60, "type": "HostRecord"}`
+	entity, err := NewIpAddressService(server).AssignIpAddress("MAKE_STATIC", "00:11:22:33:44:55", 5, hostInfo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	common.CheckResponse(t, "Assigned address", "10.0.0.2", entity.Properties["address"])
+	common.CheckResponse(t, "Added host record", "POST /api/v2/views/3/resourceRecords", server.requests[len(server.requests)-1])
+}
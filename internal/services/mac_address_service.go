@@ -1,14 +1,12 @@
 package services
 
 import (
-	"dns-api-go/internal/common"
+	"dns-api-go/internal/bluecat"
 	"dns-api-go/internal/interfaces"
 	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
 	"dns-api-go/logger"
-	"encoding/json"
-	"fmt"
 	"go.uber.org/zap"
-	"net/url"
 )
 
 type MacAddressEntityService interface {
@@ -37,34 +35,21 @@ func (ms *MacAddressService) GetMacAddress(macAddress string) (*models.Entity, e
 	}
 
 	// Send http request to bluecat
-	route, params := "/getMACAddress", fmt.Sprintf("configurationId=%d&macAddress=%s", configId, url.QueryEscape(macAddress))
-	resp, err := ms.server.MakeRequest("GET", route, params, nil)
+	entity, err := bluecat.NewClient(ms.server).GetMACAddress(configId, macAddress)
 	if err != nil {
+		if bluecat.IsNotFound(err) {
+			logger.Info("Entity not found", zap.String("macAddress", macAddress))
+			return nil, &ErrEntityNotFound{}
+		}
 		return nil, err
 	}
-	logger.Info("Received response for GetMacAddress", zap.ByteString("response", resp))
-
-	// Unmarshal the response
-	var bluecatEntity models.BluecatEntity
-	if err := json.Unmarshal(resp, &bluecatEntity); err != nil {
-		logger.Error("Error unmarshalling entity response", zap.Error(err))
-		return nil, err
-	}
-
-	// Check if the response represents an empty entity
-	if bluecatEntity.IsEmpty() {
-		logger.Info("Entity not found", zap.String("macAddress", macAddress))
-		return nil, &ErrEntityNotFound{}
-	}
-
-	// Convert BluecatEntity to Entity
-	entity := bluecatEntity.ToEntity()
 
 	logger.Info("GetMacAddress successful", zap.String("macAddress", macAddress))
-	return &entity, nil
+	return entity, nil
 }
 
-// CreateMacAddress Creates a mac address entity in bluecat
+// CreateMacAddress Creates a mac address entity in bluecat and associates it with its pool.
+// The mac address is removed again if the association fails.
 func (ms *MacAddressService) CreateMacAddress(mac models.Mac) (int, error) {
 	logger.Info("CreateMacAddress started", zap.Any("mac", mac))
 
@@ -82,19 +67,25 @@ func (ms *MacAddressService) CreateMacAddress(mac models.Mac) (int, error) {
 		return -1, err
 	}
 
+	tx := NewTransaction("create mac address " + mac.Address)
+
 	// Add mac address to bluecat
 	objectId, err := ms.AddMacAddress(mac, configId)
 	if err != nil {
-		return -1, err
+		return -1, tx.Fail(err)
 	}
+	tx.OnRollback(RollbackTask(func() error {
+		return DeleteEntityByID(ms.server, objectId, []string{types.MACADDRESS})
+	}))
 
 	// Associate mac address with a pool if PoolId exists
 	if mac.PoolId != 0 {
 		if err := ms.AssociateMacAddress(mac, configId); err != nil {
-			return -1, err
+			return -1, tx.Fail(err)
 		}
 	}
 
+	logger.Info("CreateMacAddress successful", zap.String("macAddress", mac.Address), zap.Int("objectId", objectId))
 	return objectId, nil
 }
 
@@ -103,22 +94,15 @@ func (ms *MacAddressService) AddMacAddress(mac models.Mac, configId int) (int, e
 	logger.Info("AddMacAddress started", zap.Any("mac", mac))
 
 	// Send request to bluecat
-	route, params := "/addMACAddress", fmt.Sprintf("configurationId=%d&macAddress=%s",
-		configId, url.QueryEscape(mac.Address))
-	params += "&properties=" + url.QueryEscape(common.ConvertToSeparatedString(mac.Properties, "|"))
-	resp, err := ms.server.MakeRequest("POST", route, params, nil)
+	objectId, err := bluecat.NewClient(ms.server).AddMACAddress(configId, mac.Address, mac.Properties)
 	if err != nil {
-		return -1, err
-	}
-	logger.Info("Received response for AddMacAddress", zap.ByteString("response", resp))
-
-	// Unmarshal the response to get the object iD
-	var objectId int
-	if err := json.Unmarshal(resp, &objectId); err != nil {
-		logger.Error("Error unmarshalling objectId", zap.Error(err))
+		if bluecat.IsDuplicate(err) {
+			return -1, &ErrEntityAlreadyExists{EntityID: mac.Address}
+		}
 		return -1, err
 	}
 
+	logger.Info("AddMacAddress successful", zap.String("macAddress", mac.Address), zap.Int("objectId", objectId))
 	return objectId, nil
 }
 
@@ -127,14 +111,11 @@ func (ms *MacAddressService) AssociateMacAddress(mac models.Mac, configId int) e
 	logger.Info("AssociateMacAddress started", zap.String("macAddress", mac.Address), zap.Int("poolId", mac.PoolId))
 
 	// Send request to bluecat
-	route, params := "/associateMACAddressWithPool", fmt.Sprintf("configurationId=%d&macAddress=%s&poolId=%d",
-		configId, url.QueryEscape(mac.Address), mac.PoolId)
-	resp, err := ms.server.MakeRequest("POST", route, params, nil)
-	if err != nil {
+	if err := bluecat.NewClient(ms.server).AssociateMACAddressWithPool(configId, mac.Address, mac.PoolId); err != nil {
 		return &PoolIDError{PoolID: mac.PoolId, Err: err}
 	}
 
-	logger.Info("Received response for AssociateMacAddress", zap.ByteString("response", resp))
+	logger.Info("AssociateMacAddress successful", zap.String("macAddress", mac.Address), zap.Int("poolId", mac.PoolId))
 	return nil
 }
 
@@ -158,6 +139,7 @@ func (ms *MacAddressService) UpdateMacAddress(newMac models.Mac) error {
 		if err := ms.AssociateMacAddress(newMac, configId); err != nil {
 			return err
 		}
+		lookupCache(ms.server).Invalidate(entity.ID)
 	}
 
 	// Return early if newProperties is empty
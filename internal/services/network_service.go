@@ -1,6 +1,7 @@
 package services
 
 import (
+    "dns-api-go/internal/bluecat"
     "dns-api-go/internal/interfaces"
     "dns-api-go/internal/models"
     "dns-api-go/internal/types"
@@ -30,8 +31,7 @@ func (ns *NetworkService) GetEntitiesByHint(start int, count int, options map[st
         zap.Int("count", count),
         zap.Any("options", options))
 
-    route := "/getIP4NetworksByHint"
-    networks, err := GetEntitiesByHintHelper(ns.server, route, start, count, options)
+    networks, err := GetEntitiesByHintHelper(ns.server, types.IP4NETWORK, bluecat.API.GetIP4NetworksByHint, start, count, options)
     if err != nil {
         return nil, err
     }
@@ -0,0 +1,31 @@
+package services
+
+import "fmt"
+
+// ErrInvalidRecordUpdate indicates the requested change is not valid for the record type
+type ErrInvalidRecordUpdate struct {
+	RecordType string
+	Reason     string
+}
+
+func (e *ErrInvalidRecordUpdate) Error() string {
+	return fmt.Sprintf("invalid update for %s: %s", e.RecordType, e.Reason)
+}
+
+// ErrRecordTypeNotSupported indicates the record type cannot be managed by the DNS provider
+type ErrRecordTypeNotSupported struct {
+	RecordType string
+}
+
+func (e *ErrRecordTypeNotSupported) Error() string {
+	return fmt.Sprintf("record type %s is not supported by this provider", e.RecordType)
+}
+
+// ErrNoMatchingZone indicates there is no zone that can hold the record name
+type ErrNoMatchingZone struct {
+	Name string
+}
+
+func (e *ErrNoMatchingZone) Error() string {
+	return fmt.Sprintf("no zone found for %s", e.Name)
+}
@@ -1,14 +1,15 @@
 package services
 
 import (
+	"dns-api-go/internal/bluecat"
 	"dns-api-go/internal/common"
 	"dns-api-go/internal/interfaces"
 	"dns-api-go/internal/models"
 	"dns-api-go/internal/types"
 	"dns-api-go/logger"
-	"encoding/json"
 	"fmt"
 	"go.uber.org/zap"
+	"net"
 	"strings"
 )
 
@@ -16,9 +17,35 @@ type RecordEntityService interface {
 	GetEntity(recordId int, includeHA bool) (*models.Entity, error)
 	GetRecordsByType(recordType string, parameters map[string]interface{}, viewId int) (*[]models.Entity, error)
 	CreateRecord(recordType string, parameters map[string]interface{}, viewId int) (*models.Entity, error)
+	UpdateRecord(recordId int, parameters map[string]interface{}) (*models.Entity, error)
 	DeleteEntity(recordId int) error
 }
 
+// SUPPORTEDRECORDS lists the record types that can be managed through the RecordService
+var SUPPORTEDRECORDS = []string{
+	types.HOSTRECORD,
+	types.CNAMERECORD,
+	types.EXTERNALHOST,
+	types.MXRECORD,
+	types.TXTRECORD,
+	types.SRVRECORD,
+	types.GENERICRECORD,
+	types.HINFORECORD,
+}
+
+// recordDataFields lists the fields of the record data that an update can change for each record type
+var recordDataFields = map[string][]string{
+	types.MXRECORD:      {"priority"},
+	types.SRVRECORD:     {"priority", "weight", "port"},
+	types.TXTRECORD:     {"txt"},
+	types.GENERICRECORD: {"rdata"},
+	types.HINFORECORD:   {"cpu", "os"},
+}
+
+// RESERVEDRECORDPROPERTIES lists the properties of a record that are set through their own parameters, so that
+// they cannot bypass the validation of the parameters when passed as properties
+var RESERVEDRECORDPROPERTIES = []string{"absoluteName", "addresses", "linkedRecordName", "ttl", "type", "priority", "weight", "port", "txt", "rdata", "cpu", "os"}
+
 type RecordService struct {
 	server interfaces.ServerInterface
 }
@@ -32,7 +59,7 @@ func (rs *RecordService) GetEntity(recordId int, includeHA bool) (*models.Entity
 	logger.Info("RecordService GetEntity started", zap.Int("recordId", recordId))
 
 	// Call EntityGetter
-	entity, err := GetEntityByID(rs.server, recordId, includeHA, []string{types.CNAMERECORD, types.HOSTRECORD, types.EXTERNALHOST})
+	entity, err := GetEntityByID(rs.server, recordId, includeHA, SUPPORTEDRECORDS)
 	if err != nil {
 		return nil, err
 	}
@@ -47,7 +74,7 @@ func (rs *RecordService) DeleteEntity(recordId int) error {
 	logger.Info("RecordService DeleteEntity started", zap.Int("recordId", recordId))
 
 	// Call EntityDeleter
-	err := DeleteEntityByID(rs.server, recordId, []string{types.CNAMERECORD, types.HOSTRECORD, types.EXTERNALHOST})
+	err := DeleteEntityByID(rs.server, recordId, SUPPORTEDRECORDS)
 	if err != nil {
 		return err
 	}
@@ -92,6 +119,22 @@ func (rs *RecordService) GetRecordsByType(recordType string, parameters map[stri
 		}
 
 		entities, err = rs.getExternalRecord(name, keyword, start, count, false, viewId)
+	case types.MXRECORD, types.TXTRECORD, types.SRVRECORD, types.GENERICRECORD, types.HINFORECORD:
+		// Validate the parameters
+		name, ok := parameters["name"].(string)
+		if !ok {
+			name = ""
+		}
+		keyword, ok := parameters["keyword"].(string)
+		if !ok {
+			keyword = ""
+		}
+		options, ok := parameters["options"].(map[string]string)
+		if !ok {
+			options = map[string]string{}
+		}
+
+		entities, err = rs.searchRecords(recordType, name, keyword, options["hint"], start, count)
 	default:
 		return nil, fmt.Errorf("invalid record type")
 	}
@@ -104,39 +147,22 @@ func (rs *RecordService) GetRecordsByType(recordType string, parameters map[stri
 }
 
 func (rs *RecordService) getHostOrAliasRecordsByHint(recordType string, start int, count int, options map[string]string) (*[]models.Entity, error) {
-	// Define route and parameter map
-	var route string
+	// Send request to bluecat
+	client := bluecat.NewClient(rs.server)
+	var entities []models.Entity
+	var err error
 	switch recordType {
 	case types.HOSTRECORD:
-		route = "/getHostRecordsByHint"
+		entities, err = client.GetHostRecordsByHint(start, count, options)
 	case types.CNAMERECORD:
-		route = "/getAliasesByHint"
+		entities, err = client.GetAliasesByHint(start, count, options)
 	default:
 		return nil, fmt.Errorf("invalid record type")
 	}
-	paramsMap := map[string]string{
-		"count":   fmt.Sprintf("%d", count),
-		"start":   fmt.Sprintf("%d", start),
-		"options": common.ConvertToSeparatedString(options, "&"),
-	}
-
-	// Send request to bluecat
-	params := common.ConvertToSeparatedString(paramsMap, "&")
-	resp, err := rs.server.MakeRequest("GET", route, params, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	// Unmarshal the response
-	var entitiesResp []models.BluecatEntity
-	if err := json.Unmarshal(resp, &entitiesResp); err != nil {
-		logger.Error("Error unmarshalling entities response", zap.Error(err))
-		return nil, err
-	}
-
-	// For each entity response, convert it to an entity
-	entities := models.ConvertToEntities(entitiesResp)
-
 	return &entities, nil
 }
 
@@ -165,6 +191,74 @@ func (rs *RecordService) getExternalRecord(name string, keyword string, start in
 	}
 }
 
+// searchRecords searches for records of a type that has no dedicated lookup route in bluecat.
+// When name is set, only records with a matching absolute name are returned.
+func (rs *RecordService) searchRecords(recordType string, name string, keyword string, hint string, start int, count int) (*[]models.Entity, error) {
+	// Bluecat searches on the record name, which is the first label of the absolute name
+	switch {
+	case name != "":
+		keyword = strings.SplitN(name, ".", 2)[0]
+	case keyword != "":
+	case hint != "":
+		keyword = hint
+	default:
+		keyword = "*"
+	}
+
+	entities, err := searchObjectByTypes(rs.server, keyword, start, count, false, []string{recordType})
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		return entities, nil
+	}
+
+	// Filter out records that only share the first label with the requested name
+	matches := []models.Entity{}
+	for _, entity := range *entities {
+		if entity.Properties["absoluteName"] == name {
+			matches = append(matches, entity)
+		}
+	}
+	return &matches, nil
+}
+
+// isDuplicateRecord checks whether one of the entities has the same data as the record being created.
+// Several MX, TXT, SRV and generic records can share a name, so the record data is compared as well.
+func isDuplicateRecord(recordType string, entities []models.Entity, parameters map[string]interface{}) bool {
+	var keys []string
+	switch recordType {
+	case types.MXRECORD:
+		keys = []string{"linkedRecordName"}
+	case types.TXTRECORD:
+		keys = []string{"txt"}
+	case types.SRVRECORD:
+		keys = []string{"linkedRecordName", "port"}
+	case types.GENERICRECORD:
+		keys = []string{"type", "rdata"}
+	case types.HINFORECORD:
+		keys = []string{"cpu", "os"}
+	}
+
+	for _, entity := range entities {
+		if entity.Properties["absoluteName"] != parameters["absoluteName"] {
+			continue
+		}
+
+		duplicate := true
+		for _, key := range keys {
+			if entity.Properties[key] != fmt.Sprintf("%v", parameters[key]) {
+				duplicate = false
+				break
+			}
+		}
+		if duplicate {
+			return true
+		}
+	}
+	return false
+}
+
 func (rs *RecordService) CreateRecord(recordType string, parameters map[string]interface{}, viewId int) (*models.Entity, error) {
 	logger.Info("Create Record started", zap.String("recordType", recordType))
 
@@ -179,7 +273,13 @@ func (rs *RecordService) CreateRecord(recordType string, parameters map[string]i
 	// matches the name of the record being created.
 	entities, err := rs.GetRecordsByType(recordType, checkRecordParams, viewId)
 	logger.Info("Entities", zap.Any("entities", entities))
-	if err == nil && len(*entities) > 0 {
+	if err == nil && common.Contains([]string{types.MXRECORD, types.TXTRECORD, types.SRVRECORD, types.GENERICRECORD, types.HINFORECORD}, recordType) {
+		if isDuplicateRecord(recordType, *entities, parameters) {
+			// Entity already exists, return custom error
+			logger.Error("Record already exists", zap.String("recordType", recordType))
+			return nil, &ErrEntityAlreadyExists{EntityID: parameters["name"].(string)}
+		}
+	} else if err == nil && len(*entities) > 0 {
 		entity := (*entities)[0]
 		// For host/alias records, the absolute name must be retrieved from the properties field of the first entity
 		// and checked to see if it matches the "name" parameter that is passed in.
@@ -200,44 +300,37 @@ func (rs *RecordService) CreateRecord(recordType string, parameters map[string]i
 		}
 	}
 
-	var route string
-	var paramsMap map[string]string
-
-	// Set the route and properties map according to the record type
+	// Add the record to bluecat with the route of its type
+	client := bluecat.NewClient(rs.server)
+	var recordId int
 	switch recordType {
 	case types.HOSTRECORD:
-		route, paramsMap, err = prepCreateHostParams(parameters, viewId)
-		if err != nil {
-			return nil, err
-		}
+		recordId, err = addHostRecord(client, parameters, viewId)
 	case types.CNAMERECORD:
-		route, paramsMap, err = prepCreateCNAMEParams(parameters, viewId)
-		if err != nil {
-			return nil, err
-		}
+		recordId, err = addAliasRecord(client, parameters, viewId)
 	case types.EXTERNALHOST:
-		route, paramsMap, err = prepCreateExternalParams(parameters, viewId)
-		if err != nil {
-			return nil, err
-		}
+		recordId, err = addExternalHostRecord(client, parameters, viewId)
+	case types.MXRECORD:
+		recordId, err = addMXRecord(client, parameters, viewId)
+	case types.TXTRECORD:
+		recordId, err = addTXTRecord(client, parameters, viewId)
+	case types.SRVRECORD:
+		recordId, err = addSRVRecord(client, parameters, viewId)
+	case types.GENERICRECORD:
+		recordId, err = addGenericRecord(client, parameters, viewId)
+	case types.HINFORECORD:
+		recordId, err = addHINFORecord(client, parameters, viewId)
 	default:
 		return nil, fmt.Errorf("invalid record type")
 	}
-
-	// Send request to bluecat
-	params := common.ConvertToSeparatedString(paramsMap, "&")
-	resp, err := rs.server.MakeRequest("POST", route, params, nil)
 	if err != nil {
 		logger.Info("Error code", zap.Error(err))
+		if bluecat.IsDuplicate(err) {
+			return nil, &ErrEntityAlreadyExists{EntityID: parameters["name"].(string)}
+		}
 		return nil, err
 	}
-
-	// Unmarshal the response to get the object iD
-	var recordId int
-	if err := json.Unmarshal(resp, &recordId); err != nil {
-		logger.Error("Error unmarshalling recordId", zap.Error(err))
-		return nil, err
-	}
+	lookupCache(rs.server).invalidateHints()
 
 	// Get the new entity details
 	entity, err := rs.GetEntity(recordId, true)
@@ -248,85 +341,399 @@ func (rs *RecordService) CreateRecord(recordType string, parameters map[string]i
 	return entity, nil
 }
 
-func prepCreateHostParams(parameters map[string]interface{}, viewId int) (string, map[string]string, error) {
+func addHostRecord(client bluecat.API, parameters map[string]interface{}, viewId int) (int, error) {
 	// Validate parameters
 	absoluteName, ok := parameters["absoluteName"].(string)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for absoluteName")
+		return -1, fmt.Errorf("invalid type for absoluteName")
 	}
 	addresses, ok := parameters["addresses"].([]string)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for addresses")
+		return -1, fmt.Errorf("invalid type for addresses")
+	}
+	properties, ok := parameters["properties"].(map[string]string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for properties")
+	}
+	ttl, ok := parameters["ttl"].(int)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for ttl")
+	}
+
+	return client.AddHostRecord(viewId, absoluteName, addresses, ttl, properties)
+}
+
+func addAliasRecord(client bluecat.API, parameters map[string]interface{}, viewId int) (int, error) {
+	// Validate parameters
+	absoluteName, ok := parameters["absoluteName"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for absoluteName")
+	}
+	linkedRecordName, ok := parameters["linkedRecordName"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for linkedRecordName")
 	}
 	properties, ok := parameters["properties"].(map[string]string)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for properties")
+		return -1, fmt.Errorf("invalid type for properties")
 	}
 	ttl, ok := parameters["ttl"].(int)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for ttl")
+		return -1, fmt.Errorf("invalid type for ttl")
 	}
 
-	// Define route and parameter map
-	route := "/addHostRecord"
-	paramsMap := map[string]string{
-		"absoluteName": absoluteName,
-		"addresses":    strings.Join(addresses, ","),
-		"properties":   common.ConvertToSeparatedString(properties, "|"),
-		"ttl":          fmt.Sprintf("%d", ttl),
-		"viewId":       fmt.Sprintf("%d", viewId),
+	return client.AddAliasRecord(viewId, absoluteName, linkedRecordName, ttl, properties)
+}
+
+func addExternalHostRecord(client bluecat.API, parameters map[string]interface{}, viewId int) (int, error) {
+	// Validate parameters
+	name, ok := parameters["name"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for name")
+	}
+	properties, ok := parameters["properties"].(map[string]string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for properties")
 	}
-	return route, paramsMap, nil
+
+	return client.AddExternalHostRecord(viewId, name, properties)
 }
 
-func prepCreateCNAMEParams(parameters map[string]interface{}, viewId int) (string, map[string]string, error) {
+func addMXRecord(client bluecat.API, parameters map[string]interface{}, viewId int) (int, error) {
 	// Validate parameters
 	absoluteName, ok := parameters["absoluteName"].(string)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for absoluteName")
+		return -1, fmt.Errorf("invalid type for absoluteName")
 	}
 	linkedRecordName, ok := parameters["linkedRecordName"].(string)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for linkedRecordName")
+		return -1, fmt.Errorf("invalid type for linkedRecordName")
+	}
+	priority, ok := parameters["priority"].(int)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for priority")
 	}
 	properties, ok := parameters["properties"].(map[string]string)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for properties")
+		return -1, fmt.Errorf("invalid type for properties")
 	}
 	ttl, ok := parameters["ttl"].(int)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for ttl")
+		return -1, fmt.Errorf("invalid type for ttl")
 	}
 
-	// Define route and parameter map
-	route := "/addAliasRecord"
-	paramsMap := map[string]string{
-		"absoluteName":     absoluteName,
-		"linkedRecordName": linkedRecordName,
-		"properties":       common.ConvertToSeparatedString(properties, "|"),
-		"ttl":              fmt.Sprintf("%d", ttl),
-		"viewId":           fmt.Sprintf("%d", viewId),
+	return client.AddMXRecord(viewId, absoluteName, linkedRecordName, priority, ttl, properties)
+}
+
+func addTXTRecord(client bluecat.API, parameters map[string]interface{}, viewId int) (int, error) {
+	// Validate parameters
+	absoluteName, ok := parameters["absoluteName"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for absoluteName")
+	}
+	txt, ok := parameters["txt"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for txt")
 	}
-	return route, paramsMap, nil
+	properties, ok := parameters["properties"].(map[string]string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for properties")
+	}
+	ttl, ok := parameters["ttl"].(int)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for ttl")
+	}
+
+	return client.AddTXTRecord(viewId, absoluteName, txt, ttl, properties)
 }
 
-func prepCreateExternalParams(parameters map[string]interface{}, viewId int) (string, map[string]string, error) {
+func addSRVRecord(client bluecat.API, parameters map[string]interface{}, viewId int) (int, error) {
 	// Validate parameters
-	name, ok := parameters["name"].(string)
+	absoluteName, ok := parameters["absoluteName"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for absoluteName")
+	}
+	linkedRecordName, ok := parameters["linkedRecordName"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for linkedRecordName")
+	}
+	priority, ok := parameters["priority"].(int)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for priority")
+	}
+	weight, ok := parameters["weight"].(int)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for name")
+		return -1, fmt.Errorf("invalid type for weight")
+	}
+	port, ok := parameters["port"].(int)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for port")
 	}
 	properties, ok := parameters["properties"].(map[string]string)
 	if !ok {
-		return "", nil, fmt.Errorf("invalid type for properties")
+		return -1, fmt.Errorf("invalid type for properties")
+	}
+	ttl, ok := parameters["ttl"].(int)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for ttl")
 	}
 
-	// Define route and parameter map
-	route := "/addExternalHostRecord"
-	paramsMap := map[string]string{
-		"name":       name,
-		"properties": common.ConvertToSeparatedString(properties, "|"),
-		"viewId":     fmt.Sprintf("%d", viewId),
+	return client.AddSRVRecord(viewId, absoluteName, linkedRecordName, priority, weight, port, ttl, properties)
+}
+
+func addGenericRecord(client bluecat.API, parameters map[string]interface{}, viewId int) (int, error) {
+	// Validate parameters
+	absoluteName, ok := parameters["absoluteName"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for absoluteName")
+	}
+	genericType, ok := parameters["type"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for type")
+	}
+	rdata, ok := parameters["rdata"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for rdata")
+	}
+	properties, ok := parameters["properties"].(map[string]string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for properties")
+	}
+	ttl, ok := parameters["ttl"].(int)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for ttl")
+	}
+
+	return client.AddGenericRecord(viewId, absoluteName, genericType, rdata, ttl, properties)
+}
+
+func addHINFORecord(client bluecat.API, parameters map[string]interface{}, viewId int) (int, error) {
+	// Validate parameters
+	absoluteName, ok := parameters["absoluteName"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for absoluteName")
+	}
+	cpu, ok := parameters["cpu"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for cpu")
+	}
+	os, ok := parameters["os"].(string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for os")
+	}
+	properties, ok := parameters["properties"].(map[string]string)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for properties")
+	}
+	ttl, ok := parameters["ttl"].(int)
+	if !ok {
+		return -1, fmt.Errorf("invalid type for ttl")
+	}
+
+	return client.AddHINFORecord(viewId, absoluteName, cpu, os, ttl, properties)
+}
+
+// UpdateRecord applies a partial update to an existing record in bluecat.
+// Only the keys present in parameters are changed: "ttl", "addresses", "linkedRecordName", "properties" and the
+// fields of the record data listed in recordDataFields, e.g. "priority" or "txt".
+// Which keys are accepted depends on the type of the record being updated.
+func (rs *RecordService) UpdateRecord(recordId int, parameters map[string]interface{}) (*models.Entity, error) {
+	logger.Info("UpdateRecord started", zap.Int("recordId", recordId), zap.Any("parameters", parameters))
+
+	// Get the current state of the record
+	entity, err := rs.GetEntity(recordId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	// Merge new properties into the existing entity properties
+	if err := mergeRecordProperties(entity, parameters); err != nil {
+		return nil, err
+	}
+
+	// Apply the changes allowed for the record type
+	switch entity.Type {
+	case types.HOSTRECORD:
+		err = prepUpdateHostRecord(entity, parameters)
+	case types.CNAMERECORD, types.MXRECORD, types.SRVRECORD:
+		err = prepUpdateLinkedRecord(entity, parameters)
+	case types.TXTRECORD, types.GENERICRECORD, types.HINFORECORD:
+		err = prepUpdateDataRecord(entity, parameters)
+	case types.EXTERNALHOST:
+		err = prepUpdateExternalRecord(entity, parameters)
+	default:
+		return nil, &ErrRecordTypeNotSupported{RecordType: entity.Type}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := applyDataFieldUpdates(entity, parameters); err != nil {
+		return nil, err
+	}
+
+	// Update entity in bluecat
+	if err := UpdateEntity(rs.server, entity); err != nil {
+		return nil, err
+	}
+
+	// Get the updated entity details
+	updated, err := rs.GetEntity(recordId, true)
+	if err != nil {
+		return nil, err
 	}
-	return route, paramsMap, nil
+
+	logger.Info("UpdateRecord successful", zap.Int("recordId", recordId))
+	return updated, nil
+}
+
+// mergeRecordProperties merges the properties parameter into the entity properties. The reserved properties are
+// rejected, they are changed through their own parameters.
+func mergeRecordProperties(entity *models.Entity, parameters map[string]interface{}) error {
+	value, ok := parameters["properties"]
+	if !ok {
+		return nil
+	}
+	properties, ok := value.(map[string]string)
+	if !ok {
+		return fmt.Errorf("invalid type for properties")
+	}
+	for key := range properties {
+		if common.Contains(RESERVEDRECORDPROPERTIES, key) {
+			return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: fmt.Sprintf("%s cannot be set in properties", key)}
+		}
+	}
+	for key, value := range properties {
+		entity.Properties[key] = value
+	}
+	return nil
+}
+
+func prepUpdateHostRecord(entity *models.Entity, parameters map[string]interface{}) error {
+	if _, ok := parameters["ttl"]; ok {
+		if err := applyTtlUpdate(entity, parameters); err != nil {
+			return err
+		}
+	}
+
+	if value, ok := parameters["addresses"]; ok {
+		addresses, ok := value.([]string)
+		if !ok {
+			return fmt.Errorf("invalid type for addresses")
+		}
+		if len(addresses) == 0 {
+			return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: "addresses cannot be empty"}
+		}
+		for _, address := range addresses {
+			if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+				return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: fmt.Sprintf("invalid IPv4 address '%s'", address)}
+			}
+		}
+		entity.Properties["addresses"] = strings.Join(addresses, ",")
+	}
+
+	return nil
+}
+
+func prepUpdateLinkedRecord(entity *models.Entity, parameters map[string]interface{}) error {
+	if _, ok := parameters["ttl"]; ok {
+		if err := applyTtlUpdate(entity, parameters); err != nil {
+			return err
+		}
+	}
+
+	if value, ok := parameters["linkedRecordName"]; ok {
+		linkedRecordName, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("invalid type for linkedRecordName")
+		}
+		if linkedRecordName == "" {
+			return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: "linked record name cannot be empty"}
+		}
+		entity.Properties["linkedRecordName"] = linkedRecordName
+	}
+
+	return nil
+}
+
+func prepUpdateDataRecord(entity *models.Entity, parameters map[string]interface{}) error {
+	if _, ok := parameters["ttl"]; ok {
+		if err := applyTtlUpdate(entity, parameters); err != nil {
+			return err
+		}
+	}
+
+	// The record data is changed through its own fields, e.g. txt or rdata
+	if _, ok := parameters["addresses"]; ok {
+		return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: "target cannot be set"}
+	}
+	if _, ok := parameters["linkedRecordName"]; ok {
+		return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: "target cannot be set"}
+	}
+
+	return nil
+}
+
+func prepUpdateExternalRecord(entity *models.Entity, parameters map[string]interface{}) error {
+	// External host records only carry a name and properties in bluecat
+	if _, ok := parameters["ttl"]; ok {
+		return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: "ttl cannot be set"}
+	}
+	if _, ok := parameters["addresses"]; ok {
+		return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: "target cannot be set"}
+	}
+	if _, ok := parameters["linkedRecordName"]; ok {
+		return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: "target cannot be set"}
+	}
+
+	return nil
+}
+
+// applyDataFieldUpdates validates the fields of the record data in the parameters and sets them on the entity
+// properties. Fields that the record type does not have are rejected.
+func applyDataFieldUpdates(entity *models.Entity, parameters map[string]interface{}) error {
+	allowed := recordDataFields[entity.Type]
+	for _, key := range []string{"priority", "weight", "port", "txt", "rdata", "cpu", "os"} {
+		value, ok := parameters[key]
+		if !ok {
+			continue
+		}
+		if !common.Contains(allowed, key) {
+			return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: fmt.Sprintf("%s cannot be set", key)}
+		}
+
+		switch key {
+		case "priority", "weight", "port":
+			number, ok := value.(int)
+			if !ok {
+				return fmt.Errorf("invalid type for %s", key)
+			}
+			if number < 0 || number > 65535 || (key == "port" && number == 0) {
+				return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: fmt.Sprintf("%s %d is out of range", key, number)}
+			}
+			entity.Properties[key] = fmt.Sprintf("%d", number)
+		default:
+			text, ok := value.(string)
+			if !ok {
+				return fmt.Errorf("invalid type for %s", key)
+			}
+			if text == "" {
+				return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: fmt.Sprintf("%s cannot be empty", key)}
+			}
+			entity.Properties[key] = text
+		}
+	}
+	return nil
+}
+
+// applyTtlUpdate validates the ttl parameter and sets it on the entity properties
+func applyTtlUpdate(entity *models.Entity, parameters map[string]interface{}) error {
+	ttl, ok := parameters["ttl"].(int)
+	if !ok {
+		return fmt.Errorf("invalid type for ttl")
+	}
+	if ttl < 0 {
+		return &ErrInvalidRecordUpdate{RecordType: entity.Type, Reason: "ttl cannot be negative"}
+	}
+	entity.Properties["ttl"] = fmt.Sprintf("%d", ttl)
+	return nil
 }
@@ -0,0 +1,376 @@
+package services
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/mocks"
+	"dns-api-go/internal/models"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestUpdateRecord(t *testing.T) {
+	hostRecord := []byte(`{
+		"id": 1,
+		"name": "www",
+		"type": "HostRecord",
+		"properties": "absoluteName=www.example.com|addresses=10.0.0.1|ttl=300"
+	}`)
+	aliasRecord := []byte(`{
+		"id": 2,
+		"name": "alias",
+		"type": "AliasRecord",
+		"properties": "absoluteName=alias.example.com|linkedRecordName=www.example.com|ttl=300"
+	}`)
+	externalRecord := []byte(`{
+		"id": 3,
+		"name": "external.example.com",
+		"type": "ExternalHostRecord",
+		"properties": ""
+	}`)
+
+	srvRecord := []byte(`{
+		"id": 4,
+		"name": "_sip._tcp",
+		"type": "SRVRecord",
+		"properties": "absoluteName=_sip._tcp.example.com|linkedRecordName=sip.example.com|priority=10|weight=5|port=5060"
+	}`)
+
+	tests := []struct {
+		name               string
+		recordId           int
+		parameters         map[string]interface{}
+		mockGetEntityResp  []byte
+		mockUpdateError    error
+		expectedProperties map[string]string
+		expectedError      error
+	}{
+		{
+			name:     "Update host record addresses and ttl",
+			recordId: 1,
+			parameters: map[string]interface{}{
+				"addresses":        []string{"10.0.0.2", "10.0.0.3"},
+				"linkedRecordName": "10.0.0.2,10.0.0.3",
+				"ttl":              600,
+			},
+			mockGetEntityResp: hostRecord,
+			expectedProperties: map[string]string{
+				"absoluteName": "www.example.com",
+				"addresses":    "10.0.0.2,10.0.0.3",
+				"ttl":          "600",
+			},
+			expectedError: nil,
+		},
+		{
+			name:     "Update alias record linked name and properties",
+			recordId: 2,
+			parameters: map[string]interface{}{
+				"addresses":        []string{"web.example.com"},
+				"linkedRecordName": "web.example.com",
+				"properties":       map[string]string{"comments": "moved"},
+			},
+			mockGetEntityResp: aliasRecord,
+			expectedProperties: map[string]string{
+				"absoluteName":     "alias.example.com",
+				"linkedRecordName": "web.example.com",
+				"ttl":              "300",
+				"comments":         "moved",
+			},
+			expectedError: nil,
+		},
+		{
+			name:     "Update SRV record port and weight",
+			recordId: 4,
+			parameters: map[string]interface{}{
+				"port":   5061,
+				"weight": 10,
+			},
+			mockGetEntityResp: srvRecord,
+			expectedProperties: map[string]string{
+				"absoluteName":     "_sip._tcp.example.com",
+				"linkedRecordName": "sip.example.com",
+				"priority":         "10",
+				"weight":           "10",
+				"port":             "5061",
+			},
+			expectedError: nil,
+		},
+		{
+			name:     "Port of SRV record out of range",
+			recordId: 4,
+			parameters: map[string]interface{}{
+				"port": 70000,
+			},
+			mockGetEntityResp: srvRecord,
+			expectedError:     &ErrInvalidRecordUpdate{RecordType: "SRVRecord", Reason: "port 70000 is out of range"},
+		},
+		{
+			name:     "Text of host record not allowed",
+			recordId: 1,
+			parameters: map[string]interface{}{
+				"txt": "v=spf1 -all",
+			},
+			mockGetEntityResp: hostRecord,
+			expectedError:     &ErrInvalidRecordUpdate{RecordType: "HostRecord", Reason: "txt cannot be set"},
+		},
+		{
+			name:     "Invalid host record address",
+			recordId: 1,
+			parameters: map[string]interface{}{
+				"addresses": []string{"not-an-ip"},
+			},
+			mockGetEntityResp: hostRecord,
+			expectedError:     &ErrInvalidRecordUpdate{RecordType: "HostRecord", Reason: "invalid IPv4 address 'not-an-ip'"},
+		},
+		{
+			name:     "Addresses in properties not allowed",
+			recordId: 1,
+			parameters: map[string]interface{}{
+				"properties": map[string]string{"comments": "moved", "addresses": "not-an-ip"},
+			},
+			mockGetEntityResp: hostRecord,
+			expectedError:     &ErrInvalidRecordUpdate{RecordType: "HostRecord", Reason: "addresses cannot be set in properties"},
+		},
+		{
+			name:     "Ttl in properties not allowed",
+			recordId: 2,
+			parameters: map[string]interface{}{
+				"properties": map[string]string{"ttl": "-5"},
+			},
+			mockGetEntityResp: aliasRecord,
+			expectedError:     &ErrInvalidRecordUpdate{RecordType: "AliasRecord", Reason: "ttl cannot be set in properties"},
+		},
+		{
+			name:     "Negative ttl",
+			recordId: 2,
+			parameters: map[string]interface{}{
+				"ttl": -5,
+			},
+			mockGetEntityResp: aliasRecord,
+			expectedError:     &ErrInvalidRecordUpdate{RecordType: "AliasRecord", Reason: "ttl cannot be negative"},
+		},
+		{
+			name:     "External record ttl not allowed",
+			recordId: 3,
+			parameters: map[string]interface{}{
+				"ttl": 300,
+			},
+			mockGetEntityResp: externalRecord,
+			expectedError:     &ErrInvalidRecordUpdate{RecordType: "ExternalHostRecord", Reason: "ttl cannot be set"},
+		},
+		{
+			name:     "Record not found",
+			recordId: 999,
+			parameters: map[string]interface{}{
+				"ttl": 300,
+			},
+			mockGetEntityResp: []byte(`{"id": 0, "name": null, "type": null, "properties": null}`),
+			expectedError:     &ErrEntityNotFound{},
+		},
+		{
+			name:     "Update request error",
+			recordId: 1,
+			parameters: map[string]interface{}{
+				"ttl": 300,
+			},
+			mockGetEntityResp: hostRecord,
+			mockUpdateError:   errors.New("Simulating MakeRequest error"),
+			expectedError:     errors.New("Simulating MakeRequest error"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var updated *models.BluecatEntity
+			mockServer := &mocks.MockServer{
+				MakeRequestFunc: func(method, route, queryParam string, body io.Reader) ([]byte, error) {
+					if strings.Contains(route, "getEntityById") {
+						// Return the updated entity once the update has been sent
+						if updated != nil {
+							return json.Marshal(updated)
+						}
+						return tc.mockGetEntityResp, nil
+					} else if strings.Contains(route, "update") {
+						if tc.mockUpdateError != nil {
+							return nil, tc.mockUpdateError
+						}
+						updated = &models.BluecatEntity{}
+						if err := json.NewDecoder(body).Decode(updated); err != nil {
+							return nil, err
+						}
+						return nil, nil
+					} else {
+						return nil, errors.New("unexpected route")
+					}
+				},
+			}
+
+			recordService := NewRecordService(mockServer)
+			entity, err := recordService.UpdateRecord(tc.recordId, tc.parameters)
+
+			common.CheckError(t, tc.name, tc.expectedError, err)
+			if tc.expectedError == nil {
+				common.CheckResponse(t, tc.name, tc.expectedProperties, entity.Properties)
+			}
+		})
+	}
+}
+
+func TestCreateRecordTypes(t *testing.T) {
+	baseParameters := func(extra map[string]interface{}) map[string]interface{} {
+		parameters := map[string]interface{}{
+			"absoluteName": "example.com",
+			"name":         "example.com",
+			"properties":   map[string]string{},
+			"ttl":          300,
+		}
+		for key, value := range extra {
+			parameters[key] = value
+		}
+		return parameters
+	}
+
+	tests := []struct {
+		name           string
+		recordType     string
+		parameters     map[string]interface{}
+		mockSearchResp []byte
+		expectedRoute  string
+		expectedParams []string
+		expectedError  error
+	}{
+		{
+			name:       "Create MX record",
+			recordType: "MXRecord",
+			parameters: baseParameters(map[string]interface{}{
+				"linkedRecordName": "mail.example.com",
+				"priority":         10,
+			}),
+			mockSearchResp: []byte(`[]`),
+			expectedRoute:  "/addMXRecord",
+			expectedParams: []string{"linkedRecordName=mail.example.com", "priority=10", "viewId=5"},
+			expectedError:  nil,
+		},
+		{
+			name:       "Create TXT record",
+			recordType: "TXTRecord",
+			parameters: baseParameters(map[string]interface{}{
+				"txt": "v=spf1 -all",
+			}),
+			mockSearchResp: []byte(`[]`),
+			expectedRoute:  "/addTXTRecord",
+			expectedParams: []string{"txt=v%3Dspf1+-all"},
+			expectedError:  nil,
+		},
+		{
+			name:       "Create SRV record",
+			recordType: "SRVRecord",
+			parameters: baseParameters(map[string]interface{}{
+				"absoluteName":     "_sip._tcp.example.com",
+				"name":             "_sip._tcp.example.com",
+				"linkedRecordName": "sip.example.com",
+				"priority":         10,
+				"weight":           5,
+				"port":             5060,
+			}),
+			mockSearchResp: []byte(`[]`),
+			expectedRoute:  "/addSRVRecord",
+			expectedParams: []string{"linkedRecordName=sip.example.com", "port=5060", "weight=5", "priority=10"},
+			expectedError:  nil,
+		},
+		{
+			name:       "Create generic record",
+			recordType: "GenericRecord",
+			parameters: baseParameters(map[string]interface{}{
+				"type":  "CAA",
+				"rdata": "0 issue letsencrypt.org",
+			}),
+			mockSearchResp: []byte(`[]`),
+			expectedRoute:  "/addGenericRecord",
+			expectedParams: []string{"type=CAA", "rdata=0+issue+letsencrypt.org"},
+			expectedError:  nil,
+		},
+		{
+			name:       "Create HINFO record",
+			recordType: "HINFORecord",
+			parameters: baseParameters(map[string]interface{}{
+				"cpu": "x86_64",
+				"os":  "Linux",
+			}),
+			mockSearchResp: []byte(`[]`),
+			expectedRoute:  "/addHINFORecord",
+			expectedParams: []string{"cpu=x86_64", "os=Linux"},
+			expectedError:  nil,
+		},
+		{
+			name:       "Duplicate MX record",
+			recordType: "MXRecord",
+			parameters: baseParameters(map[string]interface{}{
+				"linkedRecordName": "mail.example.com",
+				"priority":         10,
+			}),
+			mockSearchResp: []byte(`[{
+				"id": 7,
+				"name": "",
+				"type": "MXRecord",
+				"properties": "absoluteName=example.com|linkedRecordName=mail.example.com|priority=10"
+			}]`),
+			expectedError: &ErrEntityAlreadyExists{EntityID: "example.com"},
+		},
+		{
+			name:       "Second MX record with a different exchanger",
+			recordType: "MXRecord",
+			parameters: baseParameters(map[string]interface{}{
+				"linkedRecordName": "mail2.example.com",
+				"priority":         20,
+			}),
+			mockSearchResp: []byte(`[{
+				"id": 7,
+				"name": "",
+				"type": "MXRecord",
+				"properties": "absoluteName=example.com|linkedRecordName=mail.example.com|priority=10"
+			}]`),
+			expectedRoute:  "/addMXRecord",
+			expectedParams: []string{"linkedRecordName=mail2.example.com", "priority=20"},
+			expectedError:  nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var createRoute, createParams string
+			mockServer := &mocks.MockServer{
+				MakeRequestFunc: func(method, route, queryParam string, body io.Reader) ([]byte, error) {
+					switch {
+					case strings.Contains(route, "searchObjectByTypes"):
+						return tc.mockSearchResp, nil
+					case strings.HasPrefix(route, "/add"):
+						createRoute, createParams = route, queryParam
+						return []byte(`8`), nil
+					case strings.Contains(route, "getEntityById"):
+						return []byte(`{"id": 8, "name": "", "type": "` + tc.recordType + `", "properties": ""}`), nil
+					default:
+						return nil, errors.New("unexpected route")
+					}
+				},
+			}
+
+			recordService := NewRecordService(mockServer)
+			entity, err := recordService.CreateRecord(tc.recordType, tc.parameters, 5)
+
+			common.CheckError(t, tc.name, tc.expectedError, err)
+			if tc.expectedError != nil {
+				return
+			}
+			common.CheckResponse(t, tc.name, 8, entity.ID)
+			common.CheckResponse(t, tc.name, tc.expectedRoute, createRoute)
+			for _, param := range tc.expectedParams {
+				if !strings.Contains(createParams, param) {
+					t.Errorf("%s: expected %s in params %s", tc.name, param, createParams)
+				}
+			}
+		})
+	}
+}
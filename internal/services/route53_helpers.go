@@ -0,0 +1,395 @@
+package services
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"dns-api-go/logger"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"go.uber.org/zap"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// route53Provider holds the Route 53 client and the hosted zones shared by the Route 53 services
+type route53Provider struct {
+	client        route53iface.Route53API
+	hostedZoneIds []string
+}
+
+// route53Record is a single record managed through the API.
+// Route 53 groups every value for a name and type into one resource record set. Host and alias records map
+// to a whole record set, while MX, TXT, SRV and generic records map to one value of the record set.
+type route53Record struct {
+	zone  *route53.HostedZone
+	rrset *route53.ResourceRecordSet
+	value string
+}
+
+// route53Id derives a stable, positive entity ID from the given parts, since Route 53 has no numeric IDs.
+// The ID is a 53 bit hash, which JSON clients read back exactly, and records whose IDs collide are not found by ID.
+func route53Id(parts ...string) int {
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(parts, "|")))
+	id := int(h.Sum64() & (1<<53 - 1))
+	if id == 0 {
+		id = 1
+	}
+	return id
+}
+
+// route53ZoneId returns the entity ID for a hosted zone
+func route53ZoneId(zone *route53.HostedZone) int {
+	return route53Id(strings.TrimPrefix(aws.StringValue(zone.Id), "/hostedzone/"))
+}
+
+// normalizeRoute53Name converts a Route 53 name into an absolute name without the trailing dot
+func normalizeRoute53Name(name string) string {
+	name = strings.ReplaceAll(name, `\052`, "*")
+	return strings.TrimSuffix(name, ".")
+}
+
+// unmanagedRoute53Types lists the record set types that Route 53 maintains for a hosted zone. They are neither
+// listed nor changed through the API, so that the SOA and the name servers of a zone are not changed by accident.
+var unmanagedRoute53Types = []string{route53.RRTypeSoa, route53.RRTypeNs}
+
+// perValueRecordType returns true if the record type maps to a single value of a record set
+func perValueRecordType(recordType string) bool {
+	return recordType == types.MXRECORD || recordType == types.TXTRECORD ||
+		recordType == types.SRVRECORD || recordType == types.GENERICRECORD
+}
+
+// recordTypeFromRoute53 maps a Route 53 record set type to the record type used by the API
+func recordTypeFromRoute53(rrType string) string {
+	switch rrType {
+	case route53.RRTypeA:
+		return types.HOSTRECORD
+	case route53.RRTypeCname:
+		return types.CNAMERECORD
+	case route53.RRTypeMx:
+		return types.MXRECORD
+	case route53.RRTypeTxt:
+		return types.TXTRECORD
+	case route53.RRTypeSrv:
+		return types.SRVRECORD
+	default:
+		return types.GENERICRECORD
+	}
+}
+
+// route53TypeFromRecord maps an API record type to a Route 53 record set type
+func route53TypeFromRecord(recordType string, genericType string) (string, error) {
+	switch recordType {
+	case types.HOSTRECORD:
+		return route53.RRTypeA, nil
+	case types.CNAMERECORD:
+		return route53.RRTypeCname, nil
+	case types.MXRECORD:
+		return route53.RRTypeMx, nil
+	case types.TXTRECORD:
+		return route53.RRTypeTxt, nil
+	case types.SRVRECORD:
+		return route53.RRTypeSrv, nil
+	case types.GENERICRECORD:
+		if genericType == "" {
+			return "", fmt.Errorf("missing type of the generic record")
+		}
+		rrType := strings.ToUpper(genericType)
+		if common.Contains(unmanagedRoute53Types, rrType) {
+			return "", &ErrRecordTypeNotSupported{RecordType: rrType}
+		}
+		return rrType, nil
+	default:
+		return "", &ErrRecordTypeNotSupported{RecordType: recordType}
+	}
+}
+
+// quoteTxt encodes text as one or more quoted character strings of at most 255 characters
+func quoteTxt(text string) string {
+	text = strings.ReplaceAll(text, `\`, `\\`)
+	text = strings.ReplaceAll(text, `"`, `\"`)
+
+	var chunks []string
+	for len(text) > 255 {
+		// Avoid splitting an escape sequence
+		cut := 255
+		if text[cut-1] == '\\' {
+			cut--
+		}
+		chunks = append(chunks, `"`+text[:cut]+`"`)
+		text = text[cut:]
+	}
+	chunks = append(chunks, `"`+text+`"`)
+	return strings.Join(chunks, " ")
+}
+
+// unquoteTxt decodes one or more quoted character strings into a single string
+func unquoteTxt(value string) string {
+	var b strings.Builder
+	inQuotes := false
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\\' && i+1 < len(value):
+			i++
+			b.WriteByte(value[i])
+		case c == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && c == ' ':
+			// separator between character strings
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// id returns the entity ID of the record. The ID of a record that maps to one value of a record set is derived
+// from the value, as Route 53 keeps no order or identity for the values of a record set. Changing the value
+// changes the ID, and UpdateRecord returns the record with its new ID.
+func (r *route53Record) id() int {
+	return route53Id(aws.StringValue(r.zone.Id), normalizeRoute53Name(aws.StringValue(r.rrset.Name)),
+		aws.StringValue(r.rrset.Type), r.value)
+}
+
+// toEntity converts the record into an entity with the same properties bluecat uses for the record type
+func (r *route53Record) toEntity() models.Entity {
+	absoluteName := normalizeRoute53Name(aws.StringValue(r.rrset.Name))
+	zoneName := normalizeRoute53Name(aws.StringValue(r.zone.Name))
+	rrType := aws.StringValue(r.rrset.Type)
+	recordType := recordTypeFromRoute53(rrType)
+
+	// The entity name is relative to the zone, as in bluecat
+	name := ""
+	if absoluteName != zoneName {
+		name = strings.TrimSuffix(absoluteName, "."+zoneName)
+	}
+
+	properties := map[string]string{
+		"absoluteName": absoluteName,
+		"ttl":          strconv.FormatInt(aws.Int64Value(r.rrset.TTL), 10),
+		"hostedZoneId": strings.TrimPrefix(aws.StringValue(r.zone.Id), "/hostedzone/"),
+	}
+
+	fields := strings.Fields(r.value)
+	switch recordType {
+	case types.HOSTRECORD:
+		var addresses []string
+		for _, rr := range r.rrset.ResourceRecords {
+			addresses = append(addresses, aws.StringValue(rr.Value))
+		}
+		properties["addresses"] = strings.Join(addresses, ",")
+	case types.CNAMERECORD:
+		if len(r.rrset.ResourceRecords) > 0 {
+			properties["linkedRecordName"] = normalizeRoute53Name(aws.StringValue(r.rrset.ResourceRecords[0].Value))
+		}
+	case types.MXRECORD:
+		if len(fields) == 2 {
+			properties["priority"] = fields[0]
+			properties["linkedRecordName"] = normalizeRoute53Name(fields[1])
+		}
+	case types.TXTRECORD:
+		properties["txt"] = unquoteTxt(r.value)
+	case types.SRVRECORD:
+		if len(fields) == 4 {
+			properties["priority"] = fields[0]
+			properties["weight"] = fields[1]
+			properties["port"] = fields[2]
+			properties["linkedRecordName"] = normalizeRoute53Name(fields[3])
+		}
+	case types.GENERICRECORD:
+		properties["type"] = rrType
+		properties["rdata"] = r.value
+	}
+
+	return models.Entity{
+		ID:         r.id(),
+		Name:       name,
+		Type:       recordType,
+		Properties: properties,
+	}
+}
+
+// route53Value builds the record set value for a single value record from the entity properties
+func route53Value(recordType string, properties map[string]string) (string, error) {
+	switch recordType {
+	case types.MXRECORD:
+		if properties["linkedRecordName"] == "" {
+			return "", fmt.Errorf("invalid type for linkedRecordName")
+		}
+		return fmt.Sprintf("%s %s.", properties["priority"], strings.TrimSuffix(properties["linkedRecordName"], ".")), nil
+	case types.TXTRECORD:
+		return quoteTxt(properties["txt"]), nil
+	case types.SRVRECORD:
+		if properties["linkedRecordName"] == "" {
+			return "", fmt.Errorf("invalid type for linkedRecordName")
+		}
+		return fmt.Sprintf("%s %s %s %s.", properties["priority"], properties["weight"], properties["port"],
+			strings.TrimSuffix(properties["linkedRecordName"], ".")), nil
+	case types.GENERICRECORD:
+		if properties["rdata"] == "" {
+			return "", fmt.Errorf("invalid type for rdata")
+		}
+		return properties["rdata"], nil
+	default:
+		return "", &ErrRecordTypeNotSupported{RecordType: recordType}
+	}
+}
+
+// listZones lists the hosted zones served by the provider, sorted by name
+func (p *route53Provider) listZones() ([]*route53.HostedZone, error) {
+	var zones []*route53.HostedZone
+	err := p.client.ListHostedZonesPages(&route53.ListHostedZonesInput{}, func(page *route53.ListHostedZonesOutput, lastPage bool) bool {
+		for _, zone := range page.HostedZones {
+			// Only serve the configured hosted zones if any are configured
+			zoneId := strings.TrimPrefix(aws.StringValue(zone.Id), "/hostedzone/")
+			if len(p.hostedZoneIds) == 0 || common.Contains(p.hostedZoneIds, zoneId) {
+				zones = append(zones, zone)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		logger.Error("Error listing hosted zones", zap.Error(err))
+		return nil, err
+	}
+
+	sort.Slice(zones, func(i, j int) bool {
+		return aws.StringValue(zones[i].Name) < aws.StringValue(zones[j].Name)
+	})
+	return zones, nil
+}
+
+// listRecords lists the records of a hosted zone.
+// Alias targets, record sets using a routing policy and the SOA and NS record sets are not managed by the API
+// and are left out, so they cannot be found by ID to be updated or deleted either.
+func (p *route53Provider) listRecords(zone *route53.HostedZone) ([]route53Record, error) {
+	var records []route53Record
+	input := &route53.ListResourceRecordSetsInput{HostedZoneId: zone.Id}
+	err := p.client.ListResourceRecordSetsPages(input, func(page *route53.ListResourceRecordSetsOutput, lastPage bool) bool {
+		for _, rrset := range page.ResourceRecordSets {
+			rrType := aws.StringValue(rrset.Type)
+			if rrset.AliasTarget != nil || rrset.SetIdentifier != nil || common.Contains(unmanagedRoute53Types, rrType) {
+				continue
+			}
+
+			if perValueRecordType(recordTypeFromRoute53(rrType)) {
+				for _, rr := range rrset.ResourceRecords {
+					records = append(records, route53Record{zone: zone, rrset: rrset, value: aws.StringValue(rr.Value)})
+				}
+			} else {
+				records = append(records, route53Record{zone: zone, rrset: rrset})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		logger.Error("Error listing resource record sets", zap.Error(err), zap.String("hostedZoneId", aws.StringValue(zone.Id)))
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// findRecord finds a record by its entity ID across all hosted zones
+func (p *route53Provider) findRecord(recordId int) (*route53Record, error) {
+	zones, err := p.listZones()
+	if err != nil {
+		return nil, err
+	}
+
+	var match *route53Record
+	for _, zone := range zones {
+		records, err := p.listRecords(zone)
+		if err != nil {
+			return nil, err
+		}
+		for i := range records {
+			if records[i].id() != recordId {
+				continue
+			}
+			// Records with the same ID cannot be told apart, so neither of them is changed
+			if match != nil {
+				logger.Error("Records have the same ID", zap.Int("recordId", recordId),
+					zap.String("name", aws.StringValue(match.rrset.Name)), zap.String("other", aws.StringValue(records[i].rrset.Name)))
+				return nil, &ErrAmbiguousID{ID: recordId}
+			}
+			match = &records[i]
+		}
+	}
+
+	if match == nil {
+		return nil, &ErrEntityNotFound{}
+	}
+	return match, nil
+}
+
+// findRecordSet returns the record set with the given name and type in a hosted zone, or nil if there is none
+func (p *route53Provider) findRecordSet(zone *route53.HostedZone, absoluteName string, rrType string) (*route53.ResourceRecordSet, error) {
+	records, err := p.listRecords(zone)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, record := range records {
+		if normalizeRoute53Name(aws.StringValue(record.rrset.Name)) == absoluteName && aws.StringValue(record.rrset.Type) == rrType {
+			return record.rrset, nil
+		}
+	}
+	return nil, nil
+}
+
+// zoneForName returns the hosted zone with the longest name that contains the absolute name
+func (p *route53Provider) zoneForName(absoluteName string) (*route53.HostedZone, error) {
+	zones, err := p.listZones()
+	if err != nil {
+		return nil, err
+	}
+
+	var match *route53.HostedZone
+	for _, zone := range zones {
+		zoneName := normalizeRoute53Name(aws.StringValue(zone.Name))
+		if absoluteName == zoneName || strings.HasSuffix(absoluteName, "."+zoneName) {
+			if match == nil || len(zoneName) > len(normalizeRoute53Name(aws.StringValue(match.Name))) {
+				match = zone
+			}
+		}
+	}
+
+	if match == nil {
+		return nil, &ErrNoMatchingZone{Name: absoluteName}
+	}
+	return match, nil
+}
+
+// changeRecordSet submits a single change for a record set in a hosted zone
+func (p *route53Provider) changeRecordSet(zone *route53.HostedZone, action string, rrset *route53.ResourceRecordSet) error {
+	logger.Info("Changing resource record set",
+		zap.String("hostedZoneId", aws.StringValue(zone.Id)),
+		zap.String("action", action),
+		zap.String("name", aws.StringValue(rrset.Name)),
+		zap.String("type", aws.StringValue(rrset.Type)))
+
+	_, err := p.client.ChangeResourceRecordSets(&route53.ChangeResourceRecordSetsInput{
+		HostedZoneId: zone.Id,
+		ChangeBatch: &route53.ChangeBatch{
+			Changes: []*route53.Change{
+				{
+					Action:            aws.String(action),
+					ResourceRecordSet: rrset,
+				},
+			},
+		},
+	})
+	if err != nil {
+		logger.Error("Error changing resource record set", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
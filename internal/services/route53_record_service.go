@@ -0,0 +1,305 @@
+package services
+
+import (
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"dns-api-go/logger"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+)
+
+// Route53RecordService manages DNS records in AWS Route 53 hosted zones
+type Route53RecordService struct {
+	provider *route53Provider
+}
+
+// NewRoute53RecordService Constructor for Route53RecordService
+// If hostedZoneIds is empty, all hosted zones visible to the client are served.
+func NewRoute53RecordService(client route53iface.Route53API, hostedZoneIds []string) *Route53RecordService {
+	return &Route53RecordService{provider: &route53Provider{client: client, hostedZoneIds: hostedZoneIds}}
+}
+
+func (rs *Route53RecordService) GetEntity(recordId int, includeHA bool) (*models.Entity, error) {
+	logger.Info("Route53RecordService GetEntity started", zap.Int("recordId", recordId))
+
+	record, err := rs.provider.findRecord(recordId)
+	if err != nil {
+		return nil, err
+	}
+	entity := record.toEntity()
+
+	logger.Info("GetEntity successful",
+		zap.Int("entityId", entity.ID),
+		zap.String("entityType", entity.Type))
+	return &entity, nil
+}
+
+func (rs *Route53RecordService) GetRecordsByType(recordType string, parameters map[string]interface{}, viewId int) (*[]models.Entity, error) {
+	logger.Info("Route53RecordService GetRecordsByType started", zap.String("recordType", recordType))
+
+	// Validate common parameters
+	count, ok := parameters["count"].(int)
+	if !ok {
+		return nil, fmt.Errorf("invalid type for count")
+	}
+	start, ok := parameters["start"].(int)
+	if !ok {
+		return nil, fmt.Errorf("invalid type for start")
+	}
+	if _, err := route53TypeFromRecord(recordType, "generic"); err != nil {
+		return nil, err
+	}
+
+	// Optional filters
+	name, _ := parameters["name"].(string)
+	keyword, _ := parameters["keyword"].(string)
+	options, _ := parameters["options"].(map[string]string)
+	hint := options["hint"]
+
+	zones, err := rs.provider.listZones()
+	if err != nil {
+		return nil, err
+	}
+
+	entities := []models.Entity{}
+	for _, zone := range zones {
+		records, err := rs.provider.listRecords(zone)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, record := range records {
+			entity := record.toEntity()
+			absoluteName := entity.Properties["absoluteName"]
+			if entity.Type != recordType ||
+				(name != "" && absoluteName != name) ||
+				(keyword != "" && !strings.Contains(absoluteName, keyword)) ||
+				(hint != "" && !strings.HasPrefix(absoluteName, hint)) {
+				continue
+			}
+			entities = append(entities, entity)
+		}
+	}
+
+	// Apply the requested page
+	if start > len(entities) {
+		start = len(entities)
+	}
+	end := start + count
+	if end > len(entities) {
+		end = len(entities)
+	}
+	page := entities[start:end]
+
+	logger.Info("GetRecordsByType successful", zap.Int("count", len(page)))
+	return &page, nil
+}
+
+func (rs *Route53RecordService) CreateRecord(recordType string, parameters map[string]interface{}, viewId int) (*models.Entity, error) {
+	logger.Info("Route53RecordService CreateRecord started", zap.String("recordType", recordType))
+
+	absoluteName, ok := parameters["absoluteName"].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid type for absoluteName")
+	}
+	absoluteName = strings.TrimSuffix(absoluteName, ".")
+	ttl, ok := parameters["ttl"].(int)
+	if !ok {
+		return nil, fmt.Errorf("invalid type for ttl")
+	}
+	genericType, _ := parameters["type"].(string)
+	rrType, err := route53TypeFromRecord(recordType, genericType)
+	if err != nil {
+		return nil, err
+	}
+
+	zone, err := rs.provider.zoneForName(absoluteName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the entity properties from the parameters so they can be converted to a record set value
+	properties := map[string]string{"absoluteName": absoluteName}
+	for _, key := range []string{"linkedRecordName", "priority", "weight", "port", "txt", "rdata"} {
+		if value, ok := parameters[key]; ok {
+			properties[key] = fmt.Sprintf("%v", value)
+		}
+	}
+
+	existing, err := rs.provider.findRecordSet(zone, absoluteName, rrType)
+	if err != nil {
+		return nil, err
+	}
+
+	rrset := &route53.ResourceRecordSet{
+		Name: aws.String(absoluteName + "."),
+		Type: aws.String(rrType),
+		TTL:  aws.Int64(int64(ttl)),
+	}
+	var value string
+	switch recordType {
+	case types.HOSTRECORD:
+		if existing != nil {
+			return nil, &ErrEntityAlreadyExists{EntityID: absoluteName}
+		}
+		addresses, ok := parameters["addresses"].([]string)
+		if !ok {
+			return nil, fmt.Errorf("invalid type for addresses")
+		}
+		for _, address := range addresses {
+			rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(address)})
+		}
+	case types.CNAMERECORD:
+		if existing != nil {
+			return nil, &ErrEntityAlreadyExists{EntityID: absoluteName}
+		}
+		linkedRecordName, ok := parameters["linkedRecordName"].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid type for linkedRecordName")
+		}
+		rrset.ResourceRecords = []*route53.ResourceRecord{{Value: aws.String(linkedRecordName)}}
+	default:
+		value, err = route53Value(recordType, properties)
+		if err != nil {
+			return nil, err
+		}
+
+		// Add the value to the existing record set, which shares a single TTL
+		if existing != nil {
+			for _, rr := range existing.ResourceRecords {
+				if aws.StringValue(rr.Value) == value {
+					return nil, &ErrEntityAlreadyExists{EntityID: absoluteName}
+				}
+				rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: rr.Value})
+			}
+		}
+		rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(value)})
+	}
+
+	if err := rs.provider.changeRecordSet(zone, route53.ChangeActionUpsert, rrset); err != nil {
+		return nil, err
+	}
+
+	record := route53Record{zone: zone, rrset: rrset, value: value}
+	entity := record.toEntity()
+
+	logger.Info("CreateRecord successful", zap.Int("recordId", entity.ID))
+	return &entity, nil
+}
+
+// UpdateRecord updates a record in its record set. The ID of MX, TXT, SRV and generic records is derived from their
+// value, so the returned record has a new ID when the value changes.
+func (rs *Route53RecordService) UpdateRecord(recordId int, parameters map[string]interface{}) (*models.Entity, error) {
+	logger.Info("Route53RecordService UpdateRecord started", zap.Int("recordId", recordId), zap.Any("parameters", parameters))
+
+	record, err := rs.provider.findRecord(recordId)
+	if err != nil {
+		return nil, err
+	}
+	entity := record.toEntity()
+
+	// Merge new properties and validate the changes the same way as for bluecat records
+	if err := mergeRecordProperties(&entity, parameters); err != nil {
+		return nil, err
+	}
+	switch entity.Type {
+	case types.HOSTRECORD:
+		err = prepUpdateHostRecord(&entity, parameters)
+	case types.CNAMERECORD, types.MXRECORD, types.SRVRECORD:
+		err = prepUpdateLinkedRecord(&entity, parameters)
+	case types.TXTRECORD, types.GENERICRECORD:
+		err = prepUpdateDataRecord(&entity, parameters)
+	default:
+		return nil, &ErrRecordTypeNotSupported{RecordType: entity.Type}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := applyDataFieldUpdates(&entity, parameters); err != nil {
+		return nil, err
+	}
+
+	ttl, err := strconv.ParseInt(entity.Properties["ttl"], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ttl: %v", err)
+	}
+	rrset := &route53.ResourceRecordSet{
+		Name: record.rrset.Name,
+		Type: record.rrset.Type,
+		TTL:  aws.Int64(ttl),
+	}
+
+	var value string
+	switch entity.Type {
+	case types.HOSTRECORD:
+		for _, address := range strings.Split(entity.Properties["addresses"], ",") {
+			rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(address)})
+		}
+	case types.CNAMERECORD:
+		rrset.ResourceRecords = []*route53.ResourceRecord{{Value: aws.String(entity.Properties["linkedRecordName"])}}
+	default:
+		// Replace the old value of the record in the record set
+		value, err = route53Value(entity.Type, entity.Properties)
+		if err != nil {
+			return nil, err
+		}
+		for _, rr := range record.rrset.ResourceRecords {
+			if aws.StringValue(rr.Value) == record.value {
+				rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: aws.String(value)})
+			} else {
+				rrset.ResourceRecords = append(rrset.ResourceRecords, &route53.ResourceRecord{Value: rr.Value})
+			}
+		}
+	}
+
+	if err := rs.provider.changeRecordSet(record.zone, route53.ChangeActionUpsert, rrset); err != nil {
+		return nil, err
+	}
+
+	updated := route53Record{zone: record.zone, rrset: rrset, value: value}
+	updatedEntity := updated.toEntity()
+
+	logger.Info("UpdateRecord successful", zap.Int("recordId", updatedEntity.ID))
+	return &updatedEntity, nil
+}
+
+func (rs *Route53RecordService) DeleteEntity(recordId int) error {
+	logger.Info("Route53RecordService DeleteEntity started", zap.Int("recordId", recordId))
+
+	record, err := rs.provider.findRecord(recordId)
+	if err != nil {
+		return err
+	}
+
+	// Keep the other values of the record set
+	var remaining []*route53.ResourceRecord
+	if record.value != "" {
+		for _, rr := range record.rrset.ResourceRecords {
+			if aws.StringValue(rr.Value) != record.value {
+				remaining = append(remaining, rr)
+			}
+		}
+	}
+
+	if len(remaining) == 0 {
+		err = rs.provider.changeRecordSet(record.zone, route53.ChangeActionDelete, record.rrset)
+	} else {
+		err = rs.provider.changeRecordSet(record.zone, route53.ChangeActionUpsert, &route53.ResourceRecordSet{
+			Name:            record.rrset.Name,
+			Type:            record.rrset.Type,
+			TTL:             record.rrset.TTL,
+			ResourceRecords: remaining,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("DeleteEntity successful", zap.Int("recordId", recordId))
+	return nil
+}
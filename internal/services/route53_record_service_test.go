@@ -0,0 +1,344 @@
+package services
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/mocks"
+	"dns-api-go/internal/models"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"testing"
+)
+
+// newRoute53Stub returns a mock Route 53 client that keeps the record sets of a single hosted zone in memory
+func newRoute53Stub(rrsets []*route53.ResourceRecordSet) *mocks.MockRoute53 {
+	zone := &route53.HostedZone{
+		Id:     aws.String("/hostedzone/Z123"),
+		Name:   aws.String("example.com."),
+		Config: &route53.HostedZoneConfig{PrivateZone: aws.Bool(false)},
+	}
+
+	return &mocks.MockRoute53{
+		ListHostedZonesPagesFunc: func(input *route53.ListHostedZonesInput, fn func(*route53.ListHostedZonesOutput, bool) bool) error {
+			fn(&route53.ListHostedZonesOutput{HostedZones: []*route53.HostedZone{zone}}, true)
+			return nil
+		},
+		ListResourceRecordSetsPagesFunc: func(input *route53.ListResourceRecordSetsInput, fn func(*route53.ListResourceRecordSetsOutput, bool) bool) error {
+			if aws.StringValue(input.HostedZoneId) != aws.StringValue(zone.Id) {
+				return fmt.Errorf("no such hosted zone %s", aws.StringValue(input.HostedZoneId))
+			}
+			fn(&route53.ListResourceRecordSetsOutput{ResourceRecordSets: rrsets}, true)
+			return nil
+		},
+		ChangeResourceRecordSetsFunc: func(input *route53.ChangeResourceRecordSetsInput) (*route53.ChangeResourceRecordSetsOutput, error) {
+			for _, change := range input.ChangeBatch.Changes {
+				rrset := change.ResourceRecordSet
+				index := -1
+				for i, existing := range rrsets {
+					if aws.StringValue(existing.Name) == aws.StringValue(rrset.Name) && aws.StringValue(existing.Type) == aws.StringValue(rrset.Type) {
+						index = i
+					}
+				}
+
+				switch aws.StringValue(change.Action) {
+				case route53.ChangeActionUpsert:
+					if index >= 0 {
+						rrsets[index] = rrset
+					} else {
+						rrsets = append(rrsets, rrset)
+					}
+				case route53.ChangeActionDelete:
+					if index < 0 {
+						return nil, fmt.Errorf("record set not found")
+					}
+					rrsets = append(rrsets[:index], rrsets[index+1:]...)
+				}
+			}
+			return &route53.ChangeResourceRecordSetsOutput{}, nil
+		},
+	}
+}
+
+func TestRoute53CreateRecord(t *testing.T) {
+	stub := newRoute53Stub([]*route53.ResourceRecordSet{
+		{
+			Name:            aws.String("example.com."),
+			Type:            aws.String("MX"),
+			TTL:             aws.Int64(300),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10 mail.example.com.")}},
+		},
+		{
+			Name:            aws.String("www.example.com."),
+			Type:            aws.String("A"),
+			TTL:             aws.Int64(300),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("10.0.0.1")}},
+		},
+		{
+			Name: aws.String("example.com."),
+			Type: aws.String("SOA"),
+			TTL:  aws.Int64(900),
+			ResourceRecords: []*route53.ResourceRecord{
+				{Value: aws.String("ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400")},
+			},
+		},
+		{
+			Name:            aws.String("example.com."),
+			Type:            aws.String("NS"),
+			TTL:             aws.Int64(172800),
+			ResourceRecords: []*route53.ResourceRecord{{Value: aws.String("ns-1.awsdns-01.org.")}},
+		},
+	})
+	recordService := NewRoute53RecordService(stub, nil)
+
+	tests := []struct {
+		name               string
+		recordType         string
+		parameters         map[string]interface{}
+		expectedName       string
+		expectedProperties map[string]string
+		expectedError      error
+	}{
+		{
+			name:       "Create host record",
+			recordType: "HostRecord",
+			parameters: map[string]interface{}{
+				"absoluteName": "app.example.com",
+				"addresses":    []string{"10.0.0.2", "10.0.0.3"},
+				"ttl":          600,
+			},
+			expectedName: "app",
+			expectedProperties: map[string]string{
+				"absoluteName": "app.example.com",
+				"addresses":    "10.0.0.2,10.0.0.3",
+				"ttl":          "600",
+				"hostedZoneId": "Z123",
+			},
+		},
+		{
+			name:       "Host record already exists",
+			recordType: "HostRecord",
+			parameters: map[string]interface{}{
+				"absoluteName": "www.example.com",
+				"addresses":    []string{"10.0.0.2"},
+				"ttl":          300,
+			},
+			expectedError: &ErrEntityAlreadyExists{EntityID: "www.example.com"},
+		},
+		{
+			name:       "Add a second MX record",
+			recordType: "MXRecord",
+			parameters: map[string]interface{}{
+				"absoluteName":     "example.com",
+				"linkedRecordName": "mail2.example.com",
+				"priority":         20,
+				"ttl":              300,
+			},
+			expectedName: "",
+			expectedProperties: map[string]string{
+				"absoluteName":     "example.com",
+				"linkedRecordName": "mail2.example.com",
+				"priority":         "20",
+				"ttl":              "300",
+				"hostedZoneId":     "Z123",
+			},
+		},
+		{
+			name:       "Create TXT record",
+			recordType: "TXTRecord",
+			parameters: map[string]interface{}{
+				"absoluteName": "example.com",
+				"txt":          `v=spf1 include:"mail" -all`,
+				"ttl":          300,
+			},
+			expectedName: "",
+			expectedProperties: map[string]string{
+				"absoluteName": "example.com",
+				"txt":          `v=spf1 include:"mail" -all`,
+				"ttl":          "300",
+				"hostedZoneId": "Z123",
+			},
+		},
+		{
+			name:       "Record outside of the hosted zones",
+			recordType: "HostRecord",
+			parameters: map[string]interface{}{
+				"absoluteName": "www.example.org",
+				"addresses":    []string{"10.0.0.2"},
+				"ttl":          300,
+			},
+			expectedError: &ErrNoMatchingZone{Name: "www.example.org"},
+		},
+		{
+			name:       "External host records are not supported",
+			recordType: "ExternalHostRecord",
+			parameters: map[string]interface{}{
+				"absoluteName": "www.example.com",
+				"ttl":          300,
+			},
+			expectedError: &ErrRecordTypeNotSupported{RecordType: "ExternalHostRecord"},
+		},
+		{
+			name:       "Name servers of the zone are not managed",
+			recordType: "GenericRecord",
+			parameters: map[string]interface{}{
+				"absoluteName": "example.com",
+				"type":         "ns",
+				"rdata":        "ns.example.net.",
+				"ttl":          300,
+			},
+			expectedError: &ErrRecordTypeNotSupported{RecordType: "NS"},
+		},
+		{
+			name:       "Generic record without a type",
+			recordType: "GenericRecord",
+			parameters: map[string]interface{}{
+				"absoluteName": "example.com",
+				"rdata":        "0 issue letsencrypt.org",
+				"ttl":          300,
+			},
+			expectedError: fmt.Errorf("missing type of the generic record"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entity, err := recordService.CreateRecord(tc.recordType, tc.parameters, 0)
+
+			common.CheckError(t, tc.name, tc.expectedError, err)
+			if tc.expectedError != nil {
+				return
+			}
+			common.CheckResponse(t, tc.name, tc.expectedName, entity.Name)
+			common.CheckResponse(t, tc.name, tc.expectedProperties, entity.Properties)
+
+			// The created record can be retrieved by its ID
+			found, err := recordService.GetEntity(entity.ID, true)
+			common.CheckError(t, tc.name, nil, err)
+			common.CheckResponse(t, tc.name, entity, found)
+		})
+	}
+
+	// Both MX records are returned
+	entities, err := recordService.GetRecordsByType("MXRecord", map[string]interface{}{
+		"start": 0,
+		"count": 10,
+		"name":  "example.com",
+	}, 0)
+	common.CheckError(t, "List MX records", nil, err)
+	common.CheckResponse(t, "List MX records", 2, len(*entities))
+
+	// The SOA and NS record sets are neither listed nor found by ID
+	entities, err = recordService.GetRecordsByType("GenericRecord", map[string]interface{}{"start": 0, "count": 10}, 0)
+	common.CheckError(t, "List generic records", nil, err)
+	common.CheckResponse(t, "List generic records", 0, len(*entities))
+	soaId := route53Id("/hostedzone/Z123", "example.com", "SOA",
+		"ns-1.awsdns-01.org. awsdns-hostmaster.amazon.com. 1 7200 900 1209600 86400")
+	_, err = recordService.UpdateRecord(soaId, map[string]interface{}{"ttl": 300})
+	common.CheckError(t, "Update SOA record", &ErrEntityNotFound{}, err)
+	err = recordService.DeleteEntity(soaId)
+	common.CheckError(t, "Delete SOA record", &ErrEntityNotFound{}, err)
+}
+
+func TestRoute53UpdateAndDeleteRecord(t *testing.T) {
+	stub := newRoute53Stub([]*route53.ResourceRecordSet{
+		{
+			Name: aws.String("example.com."),
+			Type: aws.String("MX"),
+			TTL:  aws.Int64(300),
+			ResourceRecords: []*route53.ResourceRecord{
+				{Value: aws.String("10 mail.example.com.")},
+				{Value: aws.String("20 mail2.example.com.")},
+			},
+		},
+	})
+	recordService := NewRoute53RecordService(stub, []string{"Z123"})
+
+	mx := func(target string) *models.Entity {
+		entities, err := recordService.GetRecordsByType("MXRecord", map[string]interface{}{"start": 0, "count": 10}, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entity := range *entities {
+			if entity.Properties["linkedRecordName"] == target {
+				return &entity
+			}
+		}
+		return nil
+	}
+
+	// Update the exchanger of the first MX record, which changes its ID
+	oldId := mx("mail.example.com").ID
+	updated, err := recordService.UpdateRecord(oldId, map[string]interface{}{
+		"linkedRecordName": "mail3.example.com",
+		"ttl":              900,
+	})
+	common.CheckError(t, "Update MX record", nil, err)
+	common.CheckResponse(t, "Update MX record", "mail3.example.com", updated.Properties["linkedRecordName"])
+	common.CheckResponse(t, "Update MX record", "900", updated.Properties["ttl"])
+	if mx("mail.example.com") != nil || mx("mail2.example.com") == nil {
+		t.Errorf("Update MX record: expected only the updated value to change")
+	}
+	if updated.ID == oldId {
+		t.Errorf("Update MX record: expected a new ID for the new value")
+	}
+	_, err = recordService.GetEntity(oldId, false)
+	common.CheckError(t, "Get MX record by its old ID", &ErrEntityNotFound{}, err)
+	found, err := recordService.GetEntity(updated.ID, false)
+	common.CheckError(t, "Get MX record by its new ID", nil, err)
+	common.CheckResponse(t, "Get MX record by its new ID", "mail3.example.com", found.Properties["linkedRecordName"])
+
+	// The data of the record cannot be changed through its properties
+	_, err = recordService.UpdateRecord(updated.ID, map[string]interface{}{
+		"properties": map[string]string{"linkedRecordName": "evil.example.com"},
+	})
+	common.CheckError(t, "Update MX record properties", &ErrInvalidRecordUpdate{RecordType: "MXRecord", Reason: "linkedRecordName cannot be set in properties"}, err)
+
+	// Deleting one MX record keeps the other value of the record set
+	err = recordService.DeleteEntity(mx("mail3.example.com").ID)
+	common.CheckError(t, "Delete MX record", nil, err)
+	if mx("mail3.example.com") != nil || mx("mail2.example.com") == nil {
+		t.Errorf("Delete MX record: expected only the deleted value to be removed")
+	}
+
+	// Deleting the last MX record removes the record set
+	err = recordService.DeleteEntity(mx("mail2.example.com").ID)
+	common.CheckError(t, "Delete last MX record", nil, err)
+
+	err = recordService.DeleteEntity(12345)
+	common.CheckError(t, "Delete unknown record", &ErrEntityNotFound{}, err)
+
+	// Records with the same ID are neither updated nor deleted
+	stub = newRoute53Stub([]*route53.ResourceRecordSet{
+		{
+			Name: aws.String("example.com."),
+			Type: aws.String("TXT"),
+			TTL:  aws.Int64(300),
+			ResourceRecords: []*route53.ResourceRecord{
+				{Value: aws.String(`"v=spf1 -all"`)},
+				{Value: aws.String(`"v=spf1 -all"`)},
+			},
+		},
+	})
+	recordService = NewRoute53RecordService(stub, []string{"Z123"})
+	txtId := route53Id("/hostedzone/Z123", "example.com", "TXT", `"v=spf1 -all"`)
+	_, err = recordService.UpdateRecord(txtId, map[string]interface{}{"txt": "v=spf1 ~all"})
+	common.CheckError(t, "Update ambiguous record", &ErrAmbiguousID{ID: txtId}, err)
+	err = recordService.DeleteEntity(txtId)
+	common.CheckError(t, "Delete ambiguous record", &ErrAmbiguousID{ID: txtId}, err)
+}
+
+func TestRoute53GetZones(t *testing.T) {
+	zoneService := NewRoute53ZoneService(newRoute53Stub(nil), nil)
+
+	zones, err := zoneService.GetEntitiesByHint(0, 10, map[string]string{"hint": "example"})
+	common.CheckError(t, "List zones", nil, err)
+	common.CheckResponse(t, "List zones", 1, len(*zones))
+
+	zone, err := zoneService.GetEntity((*zones)[0].ID, true)
+	common.CheckError(t, "Get zone", nil, err)
+	common.CheckResponse(t, "Get zone", "example.com", zone.Name)
+	common.CheckResponse(t, "Get zone", "Z123", zone.Properties["hostedZoneId"])
+
+	_, err = zoneService.GetEntity(1, true)
+	common.CheckError(t, "Unknown zone", &ErrEntityNotFound{}, err)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"dns-api-go/logger"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/route53"
+	"github.com/aws/aws-sdk-go/service/route53/route53iface"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+)
+
+// Route53ZoneService serves AWS Route 53 hosted zones as zone entities
+type Route53ZoneService struct {
+	provider *route53Provider
+}
+
+// NewRoute53ZoneService Constructor for Route53ZoneService
+// If hostedZoneIds is empty, all hosted zones visible to the client are served.
+func NewRoute53ZoneService(client route53iface.Route53API, hostedZoneIds []string) *Route53ZoneService {
+	return &Route53ZoneService{provider: &route53Provider{client: client, hostedZoneIds: hostedZoneIds}}
+}
+
+// zoneToEntity converts a hosted zone into a zone entity
+func zoneToEntity(zone *route53.HostedZone) models.Entity {
+	properties := map[string]string{
+		"absoluteName": normalizeRoute53Name(aws.StringValue(zone.Name)),
+		"hostedZoneId": strings.TrimPrefix(aws.StringValue(zone.Id), "/hostedzone/"),
+	}
+	if zone.Config != nil {
+		properties["privateZone"] = strconv.FormatBool(aws.BoolValue(zone.Config.PrivateZone))
+	}
+
+	return models.Entity{
+		ID:         route53ZoneId(zone),
+		Name:       normalizeRoute53Name(aws.StringValue(zone.Name)),
+		Type:       types.ZONE,
+		Properties: properties,
+	}
+}
+
+// GetEntitiesByHint Retrieves the hosted zones whose name starts with the hint
+func (zs *Route53ZoneService) GetEntitiesByHint(start int, count int, options map[string]string) (*[]models.Entity, error) {
+	logger.Info("Route53ZoneService GetEntitiesByHint started",
+		zap.Int("start", start),
+		zap.Int("count", count),
+		zap.Any("options", options))
+
+	hostedZones, err := zs.provider.listZones()
+	if err != nil {
+		return nil, err
+	}
+
+	zones := []models.Entity{}
+	for _, zone := range hostedZones {
+		entity := zoneToEntity(zone)
+		if hint := options["hint"]; hint != "" && !strings.HasPrefix(entity.Name, hint) {
+			continue
+		}
+		zones = append(zones, entity)
+	}
+
+	// Apply the requested page
+	if start > len(zones) {
+		start = len(zones)
+	}
+	end := start + count
+	if end > len(zones) {
+		end = len(zones)
+	}
+	page := zones[start:end]
+
+	logger.Info("GetEntitiesByHint successful", zap.Int("count", len(page)))
+	return &page, nil
+}
+
+func (zs *Route53ZoneService) GetEntity(zoneId int, includeHA bool) (*models.Entity, error) {
+	logger.Info("Route53ZoneService GetZone started", zap.Int("zoneId", zoneId))
+
+	hostedZones, err := zs.provider.listZones()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zone := range hostedZones {
+		if route53ZoneId(zone) == zoneId {
+			entity := zoneToEntity(zone)
+			logger.Info("GetZone successful", zap.Int("entityId", entity.ID))
+			return &entity, nil
+		}
+	}
+
+	logger.Info("Zone not found", zap.Int("zoneId", zoneId))
+	return nil, &ErrEntityNotFound{}
+}
+
+// GetZoneRecords Retrieves every record of the hosted zone
+func (zs *Route53ZoneService) GetZoneRecords(zoneId int) (*[]models.Entity, error) {
+	logger.Info("Route53ZoneService GetZoneRecords started", zap.Int("zoneId", zoneId))
+
+	hostedZones, err := zs.provider.listZones()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, zone := range hostedZones {
+		if route53ZoneId(zone) != zoneId {
+			continue
+		}
+
+		records, err := zs.provider.listRecords(zone)
+		if err != nil {
+			return nil, err
+		}
+		entities := make([]models.Entity, 0, len(records))
+		for _, record := range records {
+			entities = append(entities, record.toEntity())
+		}
+
+		logger.Info("GetZoneRecords successful", zap.Int("count", len(entities)))
+		return &entities, nil
+	}
+
+	logger.Info("Zone not found", zap.Int("zoneId", zoneId))
+	return nil, &ErrEntityNotFound{}
+}
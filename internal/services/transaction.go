@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"dns-api-go/logger"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+type RollbackFunc func(ctx context.Context) error
+
+// RollBack executes functions from a stack of rollback functions and returns the errors of the failed tasks
+func RollBack(t *[]RollbackFunc) error {
+	if t == nil {
+		return nil
+	}
+
+	timeout, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		tasks := *t
+		logger.Error("executing rollback of tasks", zap.Int("taskCount", len(tasks)))
+		var errs []error
+		for i := len(tasks) - 1; i >= 0; i-- {
+			f := tasks[i]
+			if funcerr := f(timeout); funcerr != nil {
+				logger.Error("rollback task error, continuing rollback", zap.Error(funcerr))
+				errs = append(errs, funcerr)
+			}
+			logger.Info("executed rollback task", zap.Int("currentTask", len(tasks)-i), zap.Int("totalTasks", len(tasks)))
+		}
+		done <- errors.Join(errs...)
+	}()
+
+	// wait for a done context
+	select {
+	case <-timeout.Done():
+		logger.Error("timeout waiting for successful rollback")
+		return errors.New("timeout waiting for rollback")
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+		logger.Info("successfully rolled back")
+		return nil
+	}
+}
+
+// Transaction tracks the bluecat changes of a multi-step operation so that they can be undone when a later step fails.
+// Every successful mutation registers a compensating action with OnRollback, and Fail runs them in reverse order.
+type Transaction struct {
+	operation string
+	tasks     []RollbackFunc
+}
+
+// NewTransaction starts a transaction for the named operation
+func NewTransaction(operation string) *Transaction {
+	return &Transaction{operation: operation}
+}
+
+// OnRollback registers the compensating action of a change that was made
+func (t *Transaction) OnRollback(task RollbackFunc) {
+	t.tasks = append(t.tasks, task)
+}
+
+// Fail rolls back the changes made so far and returns an error that reports the failure and the outcome of the rollback.
+// The error is returned unchanged when nothing was changed yet.
+func (t *Transaction) Fail(err error) error {
+	if len(t.tasks) == 0 {
+		return err
+	}
+
+	logger.Warn("Rolling back transaction", zap.String("operation", t.operation), zap.Int("changes", len(t.tasks)), zap.Error(err))
+	return &ErrTransactionFailed{
+		Operation:   t.operation,
+		Changes:     len(t.tasks),
+		Err:         err,
+		RollbackErr: RollBack(&t.tasks),
+	}
+}
+
+// RollbackTask adapts a function without a context to a RollbackFunc
+func RollbackTask(f func() error) RollbackFunc {
+	return func(ctx context.Context) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return f()
+	}
+}
+
+// TransactionCause returns the error that made a transaction fail, or the error itself if it is not a transaction error
+func TransactionCause(err error) error {
+	if e, ok := err.(*ErrTransactionFailed); ok {
+		return e.Err
+	}
+	return err
+}
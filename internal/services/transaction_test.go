@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRollback(t *testing.T) {
+	// nil input
+	var rbfuncs []RollbackFunc
+	RollBack(&rbfuncs)
+
+	// empty input
+	rbfuncs = []RollbackFunc{}
+	RollBack(&rbfuncs)
+
+	// test rolling back
+	v := []int{}
+	for i := 0; i < 10; i++ {
+		v = append(v, i)
+		f := func(ctx context.Context) error {
+			if len(v) == 0 {
+				return nil
+			}
+
+			value := v[len(v)-1]
+			index := len(v) - 1
+
+			t.Logf("rolling back value %d with index %d", value, index)
+
+			if value != index {
+				t.Errorf("unexpected value %d for v index %d", value, index)
+				return fmt.Errorf("unexpected value %d for v index %d", value, index)
+			}
+
+			v = v[:len(v)-1]
+			return nil
+		}
+
+		rbfuncs = append(rbfuncs, f)
+	}
+	RollBack(&rbfuncs)
+
+	// return an error
+	f := func(ctx context.Context) error {
+		return errors.New("boom")
+	}
+	rbfuncs = append(rbfuncs, f)
+	RollBack(&rbfuncs)
+}
+
+func TestTransaction(t *testing.T) {
+	cause := &ErrEntityNotFound{}
+
+	// Nothing to roll back
+	tx := NewTransaction("test")
+	if err := tx.Fail(cause); err != cause {
+		t.Errorf("expected the error to be returned unchanged, got %v", err)
+	}
+
+	// Changes are rolled back in reverse order
+	var undone []string
+	tx = NewTransaction("test")
+	for _, name := range []string{"first", "second"} {
+		name := name
+		tx.OnRollback(func(ctx context.Context) error {
+			undone = append(undone, name)
+			return nil
+		})
+	}
+	err := tx.Fail(cause)
+	if _, ok := TransactionCause(err).(*ErrEntityNotFound); !ok {
+		t.Errorf("expected the cause of the transaction error to be kept, got %v", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("expected the transaction error to wrap the cause")
+	}
+	if strings.Join(undone, ",") != "second,first" {
+		t.Errorf("expected changes to be rolled back in reverse order, got %v", undone)
+	}
+	if !strings.HasSuffix(err.Error(), "2 change(s) rolled back") {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+
+	// A failing rollback is reported
+	tx = NewTransaction("test")
+	tx.OnRollback(RollbackTask(func() error { return errors.New("boom") }))
+	err = tx.Fail(cause)
+	if e, ok := err.(*ErrTransactionFailed); !ok || e.RollbackErr == nil {
+		t.Errorf("expected the rollback error to be reported, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "rollback of 1 change(s) failed: boom") {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
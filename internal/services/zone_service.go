@@ -1,6 +1,7 @@
 package services
 
 import (
+	"dns-api-go/internal/bluecat"
 	"dns-api-go/internal/interfaces"
 	"dns-api-go/internal/models"
 	"dns-api-go/internal/types"
@@ -11,6 +12,18 @@ import (
 type ZoneEntityService interface {
 	GetEntitiesByHint(start int, count int, options map[string]string) (*[]models.Entity, error)
 	GetEntity(zoneId int, includeHA bool) (*models.Entity, error)
+	GetZoneRecords(zoneId int) (*[]models.Entity, error)
+}
+
+// ZONERECORDS lists the record types returned for the records of a zone
+var ZONERECORDS = []string{
+	types.HOSTRECORD,
+	types.CNAMERECORD,
+	types.MXRECORD,
+	types.TXTRECORD,
+	types.SRVRECORD,
+	types.GENERICRECORD,
+	types.HINFORECORD,
 }
 
 type ZoneService struct {
@@ -30,8 +43,7 @@ func (zs *ZoneService) GetEntitiesByHint(start int, count int, options map[strin
 		zap.Int("count", count),
 		zap.Any("options", options))
 
-	route := "/getZonesByHint"
-	zones, err := GetEntitiesByHintHelper(zs.server, route, start, count, options)
+	zones, err := GetEntitiesByHintHelper(zs.server, types.ZONE, bluecat.API.GetZonesByHint, start, count, options)
 	if err != nil {
 		return nil, err
 	}
@@ -54,3 +66,32 @@ func (zs *ZoneService) GetEntity(zoneId int, includeHA bool) (*models.Entity, er
 		zap.String("entityType", entity.Type))
 	return entity, nil
 }
+
+// GetZoneRecords Retrieves every record of the types in ZONERECORDS directly under the zone
+func (zs *ZoneService) GetZoneRecords(zoneId int) (*[]models.Entity, error) {
+	logger.Info("GetZoneRecords started", zap.Int("zoneId", zoneId))
+
+	// Make sure the zone exists
+	if _, err := zs.GetEntity(zoneId, false); err != nil {
+		return nil, err
+	}
+
+	// Page through the records of each type, bluecat returns at most 10 entities per request
+	const count = 10
+	records := []models.Entity{}
+	for _, recordType := range ZONERECORDS {
+		for start := 0; ; start += count {
+			entities, err := GetEntities(zs.server, start, count, zoneId, recordType, false)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, *entities...)
+			if len(*entities) < count {
+				break
+			}
+		}
+	}
+
+	logger.Info("GetZoneRecords successful", zap.Int("count", len(records)))
+	return &records, nil
+}
@@ -0,0 +1,598 @@
+package simulator
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// routes maps the legacy REST routes to their handlers
+var routes = map[string]routeHandler{
+	"/login":                         login,
+	"/logout":                        logout,
+	"/getSystemInfo":                 getSystemInfo,
+	"/getEntityById":                 getEntityById,
+	"/getEntities":                   getEntities,
+	"/getEntityByName":               getEntityByName,
+	"/getParent":                     getParent,
+	"/delete":                        deleteEntity,
+	"/update":                        updateEntity,
+	"/customSearch":                  customSearch,
+	"/searchObjectByTypes":           searchObjectByTypes,
+	"/getZonesByHint":                hintHandler(types.ZONE, "absoluteName"),
+	"/getIP4NetworksByHint":          hintHandler(types.IP4NETWORK, "CIDR"),
+	"/getHostRecordsByHint":          hintHandler(types.HOSTRECORD, "absoluteName"),
+	"/getAliasesByHint":              hintHandler(types.CNAMERECORD, "absoluteName"),
+	"/addHostRecord":                 addRecordHandler(types.HOSTRECORD, "addresses"),
+	"/addAliasRecord":                addRecordHandler(types.CNAMERECORD, "linkedRecordName"),
+	"/addMXRecord":                   addRecordHandler(types.MXRECORD, "linkedRecordName", "priority"),
+	"/addTXTRecord":                  addRecordHandler(types.TXTRECORD, "txt"),
+	"/addSRVRecord":                  addRecordHandler(types.SRVRECORD, "linkedRecordName", "priority", "weight", "port"),
+	"/addGenericRecord":              addRecordHandler(types.GENERICRECORD, "type", "rdata"),
+	"/addHINFORecord":                addRecordHandler(types.HINFORECORD, "cpu", "os"),
+	"/addExternalHostRecord":         addExternalHostRecord,
+	"/getIP4Address":                 getIP4Address,
+	"/assignNextAvailableIP4Address": assignNextAvailableIP4Address,
+	"/getMACAddress":                 getMACAddress,
+	"/addMACAddress":                 addMACAddress,
+	"/associateMACAddressWithPool":   associateMACAddressWithPool,
+}
+
+// emptyEntity is returned by BAM when an entity cannot be found
+var emptyEntity = models.BluecatEntity{}
+
+func login(s *Simulator, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	username := query.Get("username")
+	if username != s.username || query.Get("password") != s.password {
+		return nil, &apiError{http.StatusUnauthorized, "Invalid username or password"}
+	}
+
+	// Keep the session of a client that is already logged in
+	if s.token == "" {
+		token, err := newToken()
+		if err != nil {
+			return nil, err
+		}
+		s.token = token
+	}
+
+	return fmt.Sprintf("Session Token-> %s <- for User : %s", s.token, username), nil
+}
+
+func logout(s *Simulator, _ *http.Request) (interface{}, error) {
+	s.token = ""
+	return "Successfully logged out", nil
+}
+
+func getSystemInfo(_ *Simulator, _ *http.Request) (interface{}, error) {
+	return "hostName=bam-simulator|version=9.5.0|address=127.0.0.1|clusterRole=PRIMARY", nil
+}
+
+func getEntityById(s *Simulator, r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "id", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	e, ok := s.entities[id]
+	if !ok {
+		return emptyEntity, nil
+	}
+	return e.toBluecatEntity(), nil
+}
+
+func getEntities(s *Simulator, r *http.Request) (interface{}, error) {
+	parentId, start, count, err := pageParams(r, "parentId")
+	if err != nil {
+		return nil, err
+	}
+
+	entityType := r.URL.Query().Get("type")
+	if entityType == "" {
+		return nil, &apiError{http.StatusInternalServerError, "Object type is required"}
+	}
+	return toBluecatEntities(s.children(parentId, entityType), start, count), nil
+}
+
+func getEntityByName(s *Simulator, r *http.Request) (interface{}, error) {
+	parentId, err := intParam(r, "parentId", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	e := s.child(parentId, query.Get("name"), query.Get("type"))
+	if e == nil {
+		return emptyEntity, nil
+	}
+	return e.toBluecatEntity(), nil
+}
+
+func getParent(s *Simulator, r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "entityId", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	e, ok := s.entities[id]
+	if !ok {
+		return nil, &apiError{http.StatusInternalServerError, "Object was not found"}
+	}
+	parent, ok := s.entities[e.parentId]
+	if !ok {
+		return emptyEntity, nil
+	}
+	return parent.toBluecatEntity(), nil
+}
+
+func deleteEntity(s *Simulator, r *http.Request) (interface{}, error) {
+	id, err := intParam(r, "objectId", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := s.entities[id]; !ok {
+		return nil, &apiError{http.StatusInternalServerError, "Object was not found"}
+	}
+	s.remove(id)
+	return nil, nil
+}
+
+func updateEntity(s *Simulator, r *http.Request) (interface{}, error) {
+	var update models.BluecatEntity
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		return nil, &apiError{http.StatusBadRequest, fmt.Sprintf("Invalid entity: %s", err)}
+	}
+
+	e, ok := s.entities[update.ID]
+	if !ok {
+		return nil, &apiError{http.StatusInternalServerError, "Object was not found"}
+	}
+	if update.Type != nil && *update.Type != e.entityType {
+		return nil, &apiError{http.StatusInternalServerError, "Object type cannot be changed"}
+	}
+
+	// The properties of the entity are replaced, not merged
+	if update.Name != nil {
+		e.name = *update.Name
+	}
+	e.properties = map[string]string{}
+	if update.Properties != nil {
+		for key, value := range common.ConvertToMap(*update.Properties, "|") {
+			if value != "" {
+				e.properties[key] = value
+			}
+		}
+	}
+	return nil, nil
+}
+
+func customSearch(s *Simulator, r *http.Request) (interface{}, error) {
+	_, start, count, err := pageParams(r, "")
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	entityType := query.Get("type")
+	if entityType == "" {
+		return nil, &apiError{http.StatusInternalServerError, "Object type is required"}
+	}
+
+	// Every filter must match the name or a property of the entity
+	var matches []*entity
+	for _, e := range s.ofTypes([]string{entityType}) {
+		matched := true
+		for _, filter := range query["filters"] {
+			key, value, _ := strings.Cut(filter, "=")
+			actual, ok := e.properties[key]
+			if key == "name" {
+				actual, ok = e.name, true
+			}
+			if !ok || !strings.EqualFold(actual, value) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, e)
+		}
+	}
+	return toBluecatEntities(matches, start, count), nil
+}
+
+func searchObjectByTypes(s *Simulator, r *http.Request) (interface{}, error) {
+	_, start, count, err := pageParams(r, "")
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.URL.Query()
+	pattern, err := hintPattern(query.Get("keyword"))
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*entity
+	for _, e := range s.ofTypes(strings.Split(query.Get("types"), ",")) {
+		if pattern.MatchString(e.name) || pattern.MatchString(e.properties["absoluteName"]) {
+			matches = append(matches, e)
+		}
+	}
+	return toBluecatEntities(matches, start, count), nil
+}
+
+// hintHandler returns a handler for the getXByHint routes, which match the hint against a property of the entities
+func hintHandler(entityType, property string) routeHandler {
+	return func(s *Simulator, r *http.Request) (interface{}, error) {
+		_, start, count, err := pageParams(r, "")
+		if err != nil {
+			return nil, err
+		}
+
+		options := common.ConvertToMap(r.URL.Query().Get("options"), "|")
+		pattern, err := hintPattern(options["hint"])
+		if err != nil {
+			return nil, err
+		}
+
+		var matches []*entity
+		for _, e := range s.ofTypes([]string{entityType}) {
+			if pattern.MatchString(e.properties[property]) {
+				matches = append(matches, e)
+			}
+		}
+		return toBluecatEntities(matches, start, count), nil
+	}
+}
+
+// addRecordHandler returns a handler for the addXRecord routes.
+// The record is created in the deepest zone of the view that contains its absolute name.
+func addRecordHandler(recordType string, required ...string) routeHandler {
+	return func(s *Simulator, r *http.Request) (interface{}, error) {
+		query := r.URL.Query()
+		viewId, err := intParam(r, "viewId", 0)
+		if err != nil {
+			return nil, err
+		}
+		if view, ok := s.entities[viewId]; !ok || view.entityType != types.VIEW {
+			return nil, &apiError{http.StatusInternalServerError, fmt.Sprintf("View %d was not found", viewId)}
+		}
+
+		absoluteName := strings.ToLower(strings.Trim(query.Get("absoluteName"), "."))
+		zone, name, err := s.zoneFor(viewId, absoluteName)
+		if err != nil {
+			return nil, err
+		}
+
+		properties := common.ConvertToMap(query.Get("properties"), "|")
+		properties["absoluteName"] = absoluteName
+		for _, key := range required {
+			value := query.Get(key)
+			if value == "" {
+				return nil, &apiError{http.StatusInternalServerError, fmt.Sprintf("%s is required", key)}
+			}
+			properties[key] = value
+		}
+		if ttl := query.Get("ttl"); ttl != "" && ttl != "-1" {
+			properties["ttl"] = ttl
+		}
+
+		if recordType == types.HOSTRECORD {
+			for _, address := range strings.Split(properties["addresses"], ",") {
+				if ip := net.ParseIP(address); ip == nil || ip.To4() == nil {
+					return nil, &apiError{http.StatusInternalServerError, fmt.Sprintf("Invalid IPv4 address %s", address)}
+				}
+			}
+		}
+
+		// Host and alias records must have unique names, the other types must have unique data
+		for _, existing := range s.children(zone.id, "") {
+			if existing.properties["absoluteName"] != absoluteName {
+				continue
+			}
+			if existing.entityType == types.CNAMERECORD || recordType == types.CNAMERECORD {
+				return nil, &apiError{http.StatusInternalServerError, "Duplicate of another item"}
+			}
+			if existing.entityType == recordType && (recordType == types.HOSTRECORD || sameData(existing, properties, required)) {
+				return nil, &apiError{http.StatusInternalServerError, "Duplicate of another item"}
+			}
+		}
+
+		return s.add(zone.id, name, recordType, properties).id, nil
+	}
+}
+
+func addExternalHostRecord(s *Simulator, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	viewId, err := intParam(r, "viewId", 0)
+	if err != nil {
+		return nil, err
+	}
+	if view, ok := s.entities[viewId]; !ok || view.entityType != types.VIEW {
+		return nil, &apiError{http.StatusInternalServerError, fmt.Sprintf("View %d was not found", viewId)}
+	}
+
+	name := strings.ToLower(strings.Trim(query.Get("name"), "."))
+	if name == "" {
+		return nil, &apiError{http.StatusInternalServerError, "name is required"}
+	}
+	if s.child(viewId, name, types.EXTERNALHOST) != nil {
+		return nil, &apiError{http.StatusInternalServerError, "Duplicate of another item"}
+	}
+
+	properties := common.ConvertToMap(query.Get("properties"), "|")
+	return s.add(viewId, name, types.EXTERNALHOST, properties).id, nil
+}
+
+func getIP4Address(s *Simulator, r *http.Request) (interface{}, error) {
+	containerId, err := intParam(r, "containerId", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	address := r.URL.Query().Get("address")
+	for _, e := range s.descendants(containerId, types.IP4ADDRESS) {
+		if e.properties["address"] == address {
+			return e.toBluecatEntity(), nil
+		}
+	}
+	return emptyEntity, nil
+}
+
+func assignNextAvailableIP4Address(s *Simulator, r *http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	configId, err := intParam(r, "configurationId", 0)
+	if err != nil {
+		return nil, err
+	}
+	parentId, err := intParam(r, "parentId", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	state, ok := map[string]string{
+		"MAKE_STATIC":        "STATIC",
+		"MAKE_RESERVED":      "RESERVED",
+		"MAKE_DHCP_RESERVED": "DHCP_RESERVED",
+	}[query.Get("action")]
+	if !ok {
+		return nil, &apiError{http.StatusInternalServerError, fmt.Sprintf("Invalid action %s", query.Get("action"))}
+	}
+
+	network, ok := s.entities[parentId]
+	if !ok || network.entityType != types.IP4NETWORK {
+		return nil, &apiError{http.StatusInternalServerError, fmt.Sprintf("Network %d was not found", parentId)}
+	}
+
+	macAddress := query.Get("macAddress")
+	if macAddress != "" {
+		if macAddress, err = normalizeMac(macAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	// hostInfo is "hostname,viewId,reverseFlag,sameAsZoneFlag"
+	var hostname string
+	var viewId int
+	if hostInfo := strings.Split(query.Get("hostInfo"), ","); hostInfo[0] != "" {
+		hostname = strings.ToLower(strings.Trim(hostInfo[0], "."))
+		if len(hostInfo) > 1 {
+			viewId, _ = strconv.Atoi(hostInfo[1])
+		}
+		if _, _, err := s.zoneFor(viewId, hostname); err != nil {
+			return nil, err
+		}
+	}
+
+	address, err := s.nextAvailableAddress(network)
+	if err != nil {
+		return nil, err
+	}
+
+	properties := common.ConvertToMap(query.Get("properties"), "|")
+	properties["address"] = address
+	properties["state"] = state
+	properties["macAddress"] = macAddress
+	ip := s.add(network.id, properties["name"], types.IP4ADDRESS, properties)
+
+	// BAM creates the MAC address and the linked host record along with the address
+	if macAddress != "" && s.findMac(configId, macAddress) == nil {
+		s.add(configId, "", types.MACADDRESS, map[string]string{"address": macAddress})
+	}
+	if hostname != "" {
+		zone, name, _ := s.zoneFor(viewId, hostname)
+		s.add(zone.id, name, types.HOSTRECORD, map[string]string{
+			"absoluteName": hostname,
+			"addresses":    address,
+		})
+	}
+
+	return ip.toBluecatEntity(), nil
+}
+
+func getMACAddress(s *Simulator, r *http.Request) (interface{}, error) {
+	configId, err := intParam(r, "configurationId", 0)
+	if err != nil {
+		return nil, err
+	}
+	address, err := normalizeMac(r.URL.Query().Get("macAddress"))
+	if err != nil {
+		return nil, err
+	}
+
+	mac := s.findMac(configId, address)
+	if mac == nil {
+		return emptyEntity, nil
+	}
+	return mac.toBluecatEntity(), nil
+}
+
+func addMACAddress(s *Simulator, r *http.Request) (interface{}, error) {
+	configId, err := intParam(r, "configurationId", 0)
+	if err != nil {
+		return nil, err
+	}
+	address, err := normalizeMac(r.URL.Query().Get("macAddress"))
+	if err != nil {
+		return nil, err
+	}
+	if s.findMac(configId, address) != nil {
+		return nil, &apiError{http.StatusInternalServerError, "Duplicate of another item"}
+	}
+
+	properties := common.ConvertToMap(r.URL.Query().Get("properties"), "|")
+	properties["address"] = address
+	return s.add(configId, properties["name"], types.MACADDRESS, properties).id, nil
+}
+
+func associateMACAddressWithPool(s *Simulator, r *http.Request) (interface{}, error) {
+	configId, err := intParam(r, "configurationId", 0)
+	if err != nil {
+		return nil, err
+	}
+	poolId, err := intParam(r, "poolId", 0)
+	if err != nil {
+		return nil, err
+	}
+	address, err := normalizeMac(r.URL.Query().Get("macAddress"))
+	if err != nil {
+		return nil, err
+	}
+
+	pool, ok := s.entities[poolId]
+	if !ok || pool.entityType != types.MACPOOL {
+		return nil, &apiError{http.StatusInternalServerError, fmt.Sprintf("MAC pool %d was not found", poolId)}
+	}
+
+	// The MAC address is created if it does not exist yet
+	mac := s.findMac(configId, address)
+	if mac == nil {
+		mac = s.add(configId, "", types.MACADDRESS, map[string]string{"address": address})
+	}
+	mac.properties["macPool"] = pool.name
+	return nil, nil
+}
+
+// findMac returns the MAC address entity of the configuration with the given normalized address
+func (s *Simulator) findMac(configId int, address string) *entity {
+	for _, e := range s.children(configId, types.MACADDRESS) {
+		if e.properties["address"] == address {
+			return e
+		}
+	}
+	return nil
+}
+
+// nextAvailableAddress returns the lowest host address of the network that is not in use or reserved
+func (s *Simulator) nextAvailableAddress(network *entity) (string, error) {
+	_, ipNet, err := net.ParseCIDR(network.properties["CIDR"])
+	if err != nil {
+		return "", &apiError{http.StatusInternalServerError, fmt.Sprintf("Invalid network %s", network.properties["CIDR"])}
+	}
+
+	used := map[string]bool{network.properties["gateway"]: true}
+	for _, e := range s.children(network.id, types.IP4ADDRESS) {
+		used[e.properties["address"]] = true
+	}
+
+	// Skip the network and broadcast addresses
+	ip := nextIp(ipNet.IP.To4())
+	for ipNet.Contains(nextIp(ip)) {
+		if !used[ip.String()] {
+			return ip.String(), nil
+		}
+		ip = nextIp(ip)
+	}
+	return "", &apiError{http.StatusInternalServerError, "No available IP address in the network"}
+}
+
+// sameData checks whether a record has the same values for the given properties
+func sameData(e *entity, properties map[string]string, keys []string) bool {
+	for _, key := range keys {
+		if !strings.EqualFold(e.properties[key], properties[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hintPattern converts a BAM hint or keyword to a case-insensitive regular expression.
+// "^" and "$" anchor the hint to the start and end of the value, and "*" matches any characters.
+func hintPattern(hint string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?i)")
+	if strings.HasPrefix(hint, "^") {
+		b.WriteString("^")
+		hint = strings.TrimPrefix(hint, "^")
+	}
+	anchorEnd := strings.HasSuffix(hint, "$")
+	hint = strings.TrimSuffix(hint, "$")
+	b.WriteString(strings.ReplaceAll(regexp.QuoteMeta(hint), `\*`, ".*"))
+	if anchorEnd {
+		b.WriteString("$")
+	}
+
+	pattern, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, &apiError{http.StatusInternalServerError, fmt.Sprintf("Invalid hint %s", hint)}
+	}
+	return pattern, nil
+}
+
+// normalizeMac converts a MAC address to the format stored by BAM, e.g. "AA-BB-CC-DD-EE-FF"
+func normalizeMac(address string) (string, error) {
+	hexDigits := strings.NewReplacer(":", "", "-", "", ".", "").Replace(address)
+	if _, err := strconv.ParseUint(hexDigits, 16, 64); err != nil || len(hexDigits) != 12 {
+		return "", &apiError{http.StatusInternalServerError, fmt.Sprintf("Invalid MAC address %s", address)}
+	}
+
+	hexDigits = strings.ToUpper(hexDigits)
+	pairs := make([]string, 0, 6)
+	for i := 0; i < len(hexDigits); i += 2 {
+		pairs = append(pairs, hexDigits[i:i+2])
+	}
+	return strings.Join(pairs, "-"), nil
+}
+
+// intParam returns an integer query parameter, or the default value if it is not set
+func intParam(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, &apiError{http.StatusInternalServerError, fmt.Sprintf("Invalid value for %s: %s", key, value)}
+	}
+	return i, nil
+}
+
+// pageParams returns the optional parent ID parameter along with the start and count parameters
+func pageParams(r *http.Request, parentKey string) (int, int, int, error) {
+	var parentId int
+	var err error
+	if parentKey != "" {
+		if parentId, err = intParam(r, parentKey, 0); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	start, err := intParam(r, "start", 0)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	count, err := intParam(r, "count", 10)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return parentId, start, count, nil
+}
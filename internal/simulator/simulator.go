@@ -0,0 +1,358 @@
+// Package simulator provides an in-memory BlueCat Address Manager that serves the legacy REST API.
+// It keeps a real object tree (configuration, view, zones, records, networks, addresses and MAC addresses)
+// so the API can be run end-to-end locally and in integration tests without a live BAM.
+package simulator
+
+import (
+	"bytes"
+	"crypto/rand"
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"dns-api-go/logger"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go.uber.org/zap"
+	"net"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Simulator is a stateful fake of the BlueCat Address Manager legacy REST API
+type Simulator struct {
+	mu       sync.Mutex
+	username string
+	password string
+	token    string
+	nextId   int
+	entities map[int]*entity
+	configId int
+	viewId   int
+}
+
+// entity is a node of the object tree
+type entity struct {
+	id         int
+	parentId   int
+	name       string
+	entityType string
+	properties map[string]string
+}
+
+// apiError is returned by route handlers and written to the client with the given status code
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+// routeHandler handles a single REST route and returns the value to encode as the response body
+type routeHandler func(s *Simulator, r *http.Request) (interface{}, error)
+
+// New creates a simulator with a single configuration and DNS view.
+// Clients must log in with the given username and password.
+func New(username, password string) *Simulator {
+	s := &Simulator{
+		username: username,
+		password: password,
+		nextId:   100000,
+		entities: make(map[int]*entity),
+	}
+	s.configId = s.add(0, "default", types.CONFIGURATION, nil).id
+	s.viewId = s.add(s.configId, "default", types.VIEW, nil).id
+	return s
+}
+
+// ConfigurationId returns the ID of the configuration
+func (s *Simulator) ConfigurationId() int {
+	return s.configId
+}
+
+// ViewId returns the ID of the DNS view
+func (s *Simulator) ViewId() int {
+	return s.viewId
+}
+
+// AddZone adds a zone and any missing parent zones to the view, returning the ID of the zone
+func (s *Simulator) AddZone(absoluteName string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	absoluteName = strings.ToLower(strings.Trim(absoluteName, "."))
+	if absoluteName == "" {
+		return 0, fmt.Errorf("zone name cannot be empty")
+	}
+
+	// Zones are nested by label, e.g. example.com is the zone "example" in the zone "com"
+	labels := strings.Split(absoluteName, ".")
+	parent := s.entities[s.viewId]
+	for i := len(labels) - 1; i >= 0; i-- {
+		child := s.child(parent.id, labels[i], types.ZONE)
+		if child == nil {
+			child = s.add(parent.id, labels[i], types.ZONE, map[string]string{
+				"absoluteName": strings.Join(labels[i:], "."),
+				"deployable":   "true",
+			})
+		}
+		parent = child
+	}
+
+	return parent.id, nil
+}
+
+// AddNetwork adds an IPv4 network to the configuration, returning the ID of the network.
+// The first host address of the network is used as the gateway.
+func (s *Simulator) AddNetwork(name, cidr string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ip, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil || ip.To4() == nil {
+		return 0, fmt.Errorf("invalid IPv4 network '%s'", cidr)
+	}
+	gateway := nextIp(ipNet.IP.To4())
+
+	network := s.add(s.configId, name, types.IP4NETWORK, map[string]string{
+		"CIDR":    ipNet.String(),
+		"gateway": gateway.String(),
+	})
+	return network.id, nil
+}
+
+// AddMACPool adds a MAC pool to the configuration, returning the ID of the pool
+func (s *Simulator) AddMACPool(name string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.add(s.configId, name, types.MACPOOL, nil).id, nil
+}
+
+// ServeHTTP serves the legacy REST routes.
+// Only the last path element is used to select the route, so the simulator can be mounted under any base URL,
+// e.g. http://localhost:8081/Services/REST/v1.
+func (s *Simulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	route := "/" + path.Base(r.URL.Path)
+	logger.Debug("simulator request", zap.String("method", r.Method), zap.String("route", route))
+
+	if route != "/login" && (s.token == "" || r.Header.Get("Authorization") != s.token) {
+		http.Error(w, "Not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	handler, ok := routes[route]
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown route %s", route), http.StatusNotFound)
+		return
+	}
+
+	resp, err := handler(s, r)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if e, ok := err.(*apiError); ok {
+			status = e.status
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	// Routes such as /delete and /update return an empty body
+	if resp == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	body, err := encode(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// encode marshals v like BAM does, without escaping HTML characters and without a trailing newline
+func encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// newToken generates a random session token
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "BAMAuthToken: " + hex.EncodeToString(b), nil
+}
+
+// add creates a new entity under the parent
+func (s *Simulator) add(parentId int, name, entityType string, properties map[string]string) *entity {
+	s.nextId++
+	e := &entity{
+		id:         s.nextId,
+		parentId:   parentId,
+		name:       name,
+		entityType: entityType,
+		properties: make(map[string]string),
+	}
+	for key, value := range properties {
+		if value != "" {
+			e.properties[key] = value
+		}
+	}
+	s.entities[e.id] = e
+	return e
+}
+
+// remove deletes an entity and all of its descendants
+func (s *Simulator) remove(id int) {
+	for _, child := range s.children(id, "") {
+		s.remove(child.id)
+	}
+	delete(s.entities, id)
+}
+
+// children returns the direct children of an entity ordered by ID, optionally filtered by type
+func (s *Simulator) children(parentId int, entityType string) []*entity {
+	var children []*entity
+	for _, e := range s.entities {
+		if e.parentId == parentId && (entityType == "" || e.entityType == entityType) {
+			children = append(children, e)
+		}
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].id < children[j].id })
+	return children
+}
+
+// child returns the direct child with the given name and type, or nil if there is none
+func (s *Simulator) child(parentId int, name, entityType string) *entity {
+	for _, e := range s.children(parentId, entityType) {
+		if strings.EqualFold(e.name, name) {
+			return e
+		}
+	}
+	return nil
+}
+
+// descendants returns all entities below an entity ordered by ID, optionally filtered by type
+func (s *Simulator) descendants(id int, entityType string) []*entity {
+	var result []*entity
+	for _, child := range s.children(id, "") {
+		if entityType == "" || child.entityType == entityType {
+			result = append(result, child)
+		}
+		result = append(result, s.descendants(child.id, entityType)...)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
+}
+
+// ofTypes returns all entities of the given types ordered by ID
+func (s *Simulator) ofTypes(entityTypes []string) []*entity {
+	var result []*entity
+	for _, e := range s.entities {
+		for _, entityType := range entityTypes {
+			if e.entityType == entityType {
+				result = append(result, e)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].id < result[j].id })
+	return result
+}
+
+// zoneFor returns the deepest zone in the view that contains absoluteName and the name of the record relative to it
+func (s *Simulator) zoneFor(viewId int, absoluteName string) (*entity, string, error) {
+	absoluteName = strings.ToLower(strings.Trim(absoluteName, "."))
+
+	var zone *entity
+	for _, z := range s.descendants(viewId, types.ZONE) {
+		zoneName := z.properties["absoluteName"]
+		if absoluteName != zoneName && !strings.HasSuffix(absoluteName, "."+zoneName) {
+			continue
+		}
+		if zone == nil || len(zoneName) > len(zone.properties["absoluteName"]) {
+			zone = z
+		}
+	}
+	if zone == nil {
+		return nil, "", &apiError{http.StatusInternalServerError, fmt.Sprintf("Parent zone for %s not found", absoluteName)}
+	}
+
+	name := strings.TrimSuffix(strings.TrimSuffix(absoluteName, zone.properties["absoluteName"]), ".")
+	return zone, name, nil
+}
+
+// toBluecatEntity converts an entity to the representation returned by the API
+func (e *entity) toBluecatEntity() models.BluecatEntity {
+	name, entityType := e.name, e.entityType
+	return models.BluecatEntity{
+		ID:         e.id,
+		Name:       &name,
+		Type:       &entityType,
+		Properties: formatProperties(e.properties),
+	}
+}
+
+// toBluecatEntities converts a page of entities to the representation returned by the API
+func toBluecatEntities(entities []*entity, start, count int) []models.BluecatEntity {
+	result := []models.BluecatEntity{}
+	for i, e := range entities {
+		if i < start {
+			continue
+		}
+		if count >= 0 && len(result) >= count {
+			break
+		}
+		result = append(result, e.toBluecatEntity())
+	}
+	return result
+}
+
+// formatProperties formats properties the way BAM does, e.g. "key1=value1|key2=value2|"
+func formatProperties(properties map[string]string) *string {
+	if len(properties) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s=%s|", key, properties[key])
+	}
+	formatted := b.String()
+	return &formatted
+}
+
+// nextIp returns the address following ip
+func nextIp(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
@@ -0,0 +1,137 @@
+package simulator
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Setup phase: Initialize the logger
+	common.SetupLogger()
+
+	// Run the tests
+	code := m.Run()
+
+	// Exit with the code from m.Run()
+	os.Exit(code)
+}
+
+// call sends a request to the simulator and returns the status code and body
+func call(sim *Simulator, method, target, token string) (int, string) {
+	req := httptest.NewRequest(method, target, nil)
+	req.Header.Set("Authorization", token)
+	rr := httptest.NewRecorder()
+	sim.ServeHTTP(rr, req)
+
+	body, _ := io.ReadAll(rr.Body)
+	return rr.Code, strings.TrimSpace(string(body))
+}
+
+// logIn logs in to the simulator and returns the session token
+func logIn(t *testing.T, sim *Simulator) string {
+	status, body := call(sim, http.MethodGet, "/Services/REST/v1/login?username=user&password=password", "")
+	if status != http.StatusOK {
+		t.Fatalf("login failed with status %d: %s", status, body)
+	}
+	token := strings.TrimPrefix(body, `"Session Token-> `)
+	return strings.TrimSuffix(token, ` <- for User : user"`)
+}
+
+func TestLogin(t *testing.T) {
+	sim := New("user", "password")
+
+	status, _ := call(sim, http.MethodGet, "/login?username=user&password=wrong", "")
+	common.CheckResponse(t, "Invalid password", http.StatusUnauthorized, status)
+
+	status, _ = call(sim, http.MethodGet, "/getSystemInfo", "")
+	common.CheckResponse(t, "Not logged in", http.StatusUnauthorized, status)
+
+	token := logIn(t, sim)
+	if !strings.HasPrefix(token, "BAMAuthToken: ") {
+		t.Errorf("unexpected token %s", token)
+	}
+	status, _ = call(sim, http.MethodGet, "/getSystemInfo", token)
+	common.CheckResponse(t, "Logged in", http.StatusOK, status)
+
+	status, _ = call(sim, http.MethodGet, "/logout", token)
+	common.CheckResponse(t, "Logout", http.StatusOK, status)
+	status, _ = call(sim, http.MethodGet, "/getSystemInfo", token)
+	common.CheckResponse(t, "Logged out", http.StatusUnauthorized, status)
+}
+
+func TestObjectTree(t *testing.T) {
+	sim := New("user", "password")
+	zoneId, err := sim.AddZone("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := logIn(t, sim)
+
+	// Records are created in the deepest zone containing their name
+	status, body := call(sim, http.MethodPost, fmt.Sprintf("/addHostRecord?absoluteName=www.example.com&addresses=10.0.0.1&ttl=300&viewId=%d", sim.ViewId()), token)
+	common.CheckResponse(t, "Add host record", http.StatusOK, status)
+
+	status, body = call(sim, http.MethodGet, "/getParent?entityId="+body, token)
+	common.CheckResponse(t, "Get parent", http.StatusOK, status)
+	var parent models.BluecatEntity
+	if err := json.Unmarshal([]byte(body), &parent); err != nil {
+		t.Fatal(err)
+	}
+	common.CheckResponse(t, "Get parent", zoneId, parent.ID)
+
+	status, _ = call(sim, http.MethodPost, fmt.Sprintf("/addHostRecord?absoluteName=www.example.com&addresses=10.0.0.2&viewId=%d", sim.ViewId()), token)
+	common.CheckResponse(t, "Duplicate host record", http.StatusInternalServerError, status)
+
+	status, _ = call(sim, http.MethodPost, fmt.Sprintf("/addHostRecord?absoluteName=www.example.org&addresses=10.0.0.1&viewId=%d", sim.ViewId()), token)
+	common.CheckResponse(t, "Host record without a zone", http.StatusInternalServerError, status)
+
+	// Deleting a zone removes its records
+	status, _ = call(sim, http.MethodDelete, fmt.Sprintf("/delete?objectId=%d", zoneId), token)
+	common.CheckResponse(t, "Delete zone", http.StatusOK, status)
+	status, body = call(sim, http.MethodGet, "/searchObjectByTypes?keyword=www&types=HostRecord&start=0&count=10", token)
+	common.CheckResponse(t, "Search deleted records", http.StatusOK, status)
+	common.CheckResponse(t, "Search deleted records", "[]", body)
+}
+
+func TestHintPattern(t *testing.T) {
+	tests := []struct {
+		hint     string
+		value    string
+		expected bool
+	}{
+		{"example", "www.example.com", true},
+		{"^www", "www.example.com", true},
+		{"^example", "www.example.com", false},
+		{"com$", "www.example.com", true},
+		{"w*.com", "www.example.com", true},
+		{"*", "anything", true},
+		{"EXAMPLE", "www.example.com", true},
+	}
+
+	for _, tc := range tests {
+		pattern, err := hintPattern(tc.hint)
+		common.CheckError(t, tc.hint, nil, err)
+		common.CheckResponse(t, tc.hint, tc.expected, pattern.MatchString(tc.value))
+	}
+}
+
+func TestNormalizeMac(t *testing.T) {
+	for _, address := range []string{"00:11:22:aa:bb:cc", "00-11-22-AA-BB-CC", "001122aabbcc", "0011.22aa.bbcc"} {
+		normalized, err := normalizeMac(address)
+		common.CheckError(t, address, nil, err)
+		common.CheckResponse(t, address, "00-11-22-AA-BB-CC", normalized)
+	}
+
+	_, err := normalizeMac("00:11:22")
+	if err == nil {
+		t.Errorf("expected an error for an invalid MAC address")
+	}
+}
@@ -0,0 +1,340 @@
+package zonefile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// token is a word of a zone file entry
+type token struct {
+	text   string
+	quoted bool
+}
+
+// Parse reads resource records in master file syntax.
+// Relative names are completed with origin until an $ORIGIN directive changes it. Records without a TTL use the
+// value of the last $TTL directive, or -1 if there is none. $INCLUDE and classes other than IN are not supported.
+func Parse(r io.Reader, origin string) ([]Record, error) {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "."))
+	defaultTTL := -1
+	previousOwner := ""
+
+	var records []Record
+	var tokens []token
+	var depth, startLine int
+	var blankOwner bool
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := scanner.Text()
+
+		// An entry starts on the first line that is not a continuation of parentheses
+		if depth == 0 {
+			startLine = lineNumber
+			blankOwner = len(line) > 0 && (line[0] == ' ' || line[0] == '\t')
+		}
+
+		lineTokens, err := tokenize(line, &depth)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		tokens = append(tokens, lineTokens...)
+		if depth > 0 || len(tokens) == 0 {
+			continue
+		}
+
+		entry := tokens
+		tokens = nil
+
+		// Control entries
+		switch strings.ToUpper(entry[0].text) {
+		case "$ORIGIN":
+			if len(entry) != 2 {
+				return nil, fmt.Errorf("line %d: invalid $ORIGIN", startLine)
+			}
+			origin = absoluteName(entry[1].text, origin)
+			continue
+		case "$TTL":
+			if len(entry) != 2 {
+				return nil, fmt.Errorf("line %d: invalid $TTL", startLine)
+			}
+			if defaultTTL, err = parseTTL(entry[1].text); err != nil {
+				return nil, fmt.Errorf("line %d: %v", startLine, err)
+			}
+			continue
+		case "$INCLUDE", "$GENERATE":
+			return nil, fmt.Errorf("line %d: %s is not supported", startLine, entry[0].text)
+		}
+
+		// The owner is omitted when the entry starts with a blank
+		owner := previousOwner
+		if !blankOwner {
+			owner = absoluteName(entry[0].text, origin)
+			entry = entry[1:]
+		}
+		if owner == "" {
+			return nil, fmt.Errorf("line %d: missing owner name", startLine)
+		}
+		previousOwner = owner
+
+		record, err := parseRecord(owner, defaultTTL, entry, origin)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", startLine, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if depth > 0 {
+		return nil, fmt.Errorf("line %d: unbalanced parentheses", startLine)
+	}
+
+	return records, nil
+}
+
+// parseRecord parses the TTL, class, type and data of an entry
+func parseRecord(owner string, ttl int, entry []token, origin string) (Record, error) {
+	record := Record{Name: owner, TTL: ttl}
+
+	// TTL and class can appear in either order before the type
+	for len(entry) > 0 && record.Type == "" {
+		word := strings.ToUpper(entry[0].text)
+		entry = entry[1:]
+		switch {
+		case word == "IN":
+		case word == "CH" || word == "HS" || word == "CS":
+			return record, fmt.Errorf("class %s is not supported", word)
+		case word[0] >= '0' && word[0] <= '9':
+			parsed, err := parseTTL(word)
+			if err != nil {
+				return record, err
+			}
+			record.TTL = parsed
+		default:
+			record.Type = word
+		}
+	}
+	if record.Type == "" {
+		return record, fmt.Errorf("missing record type")
+	}
+
+	data, err := parseData(record.Type, entry, origin)
+	if err != nil {
+		return record, fmt.Errorf("invalid %s record %s: %v", record.Type, owner, err)
+	}
+	record.Data = data
+	return record, nil
+}
+
+// parseData converts the data of a record to the canonical form used by FromEntity, so parsed records
+// can be compared with the records of existing entities
+func parseData(recordType string, fields []token, origin string) (string, error) {
+	expectFields := func(n int) error {
+		if len(fields) != n {
+			return fmt.Errorf("expected %d fields, got %d", n, len(fields))
+		}
+		return nil
+	}
+	expectNumber := func(value string) error {
+		if _, err := strconv.ParseUint(value, 10, 16); err != nil {
+			return fmt.Errorf("invalid number '%s'", value)
+		}
+		return nil
+	}
+
+	switch recordType {
+	case "A", "AAAA":
+		if err := expectFields(1); err != nil {
+			return "", err
+		}
+		ip := net.ParseIP(fields[0].text)
+		if ip == nil || (recordType == "A") != (ip.To4() != nil) {
+			return "", fmt.Errorf("invalid address '%s'", fields[0].text)
+		}
+		return ip.String(), nil
+	case "CNAME":
+		if err := expectFields(1); err != nil {
+			return "", err
+		}
+		return fqdn(absoluteName(fields[0].text, origin)), nil
+	case "MX":
+		if err := expectFields(2); err != nil {
+			return "", err
+		}
+		if err := expectNumber(fields[0].text); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s", fields[0].text, fqdn(absoluteName(fields[1].text, origin))), nil
+	case "SRV":
+		if err := expectFields(4); err != nil {
+			return "", err
+		}
+		for _, field := range fields[:3] {
+			if err := expectNumber(field.text); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("%s %s %s %s", fields[0].text, fields[1].text, fields[2].text,
+			fqdn(absoluteName(fields[3].text, origin))), nil
+	case "TXT":
+		if len(fields) == 0 {
+			return "", fmt.Errorf("missing text")
+		}
+		// Character strings are joined, as bluecat stores a single text value
+		var text strings.Builder
+		for _, field := range fields {
+			text.WriteString(field.text)
+		}
+		return quoteTxt(text.String()), nil
+	case "HINFO":
+		if err := expectFields(2); err != nil {
+			return "", err
+		}
+		return quoteTxt(fields[0].text) + " " + quoteTxt(fields[1].text), nil
+	default:
+		if len(fields) == 0 {
+			return "", fmt.Errorf("missing data")
+		}
+		words := make([]string, len(fields))
+		for i, field := range fields {
+			words[i] = field.text
+			if field.quoted {
+				words[i] = quoteTxt(field.text)
+			}
+		}
+		return strings.Join(words, " "), nil
+	}
+}
+
+// tokenize splits a line into words, dropping comments and tracking the depth of parentheses across lines
+func tokenize(line string, depth *int) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == ';':
+			return tokens, nil
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '(':
+			*depth++
+			i++
+		case c == ')':
+			if *depth == 0 {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+			*depth--
+			i++
+		case c == '"':
+			text, n, err := readQuoted(line[i+1:])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i += n + 2
+		default:
+			start := i
+			for i < len(line) && !strings.ContainsRune(" \t\r;()\"", rune(line[i])) {
+				if line[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i > len(line) {
+				i = len(line)
+			}
+			tokens = append(tokens, token{text: line[start:i]})
+		}
+	}
+	return tokens, nil
+}
+
+// readQuoted reads a quoted character string up to the closing quote, returning the unescaped text
+// and the number of bytes consumed before the closing quote
+func readQuoted(s string) (string, int, error) {
+	var text strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return text.String(), i, nil
+		case '\\':
+			if i+1 >= len(s) {
+				return "", 0, fmt.Errorf("unterminated escape")
+			}
+			// \DDD is a decimal byte value, any other escaped character stands for itself
+			if i+3 < len(s) && isDigits(s[i+1:i+4]) {
+				value, _ := strconv.Atoi(s[i+1 : i+4])
+				if value > 255 {
+					return "", 0, fmt.Errorf("invalid escape \\%s", s[i+1:i+4])
+				}
+				text.WriteByte(byte(value))
+				i += 3
+			} else {
+				text.WriteByte(s[i+1])
+				i++
+			}
+		default:
+			text.WriteByte(s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated quoted string")
+}
+
+// isDigits checks whether s only contains decimal digits
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTTL parses a TTL in seconds, or with the BIND units s, m, h, d and w, e.g. "1h30m"
+func parseTTL(value string) (int, error) {
+	units := map[byte]int{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+
+	total, current := 0, -1
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= '0' && c <= '9':
+			if current < 0 {
+				current = 0
+			}
+			current = current*10 + int(c-'0')
+		case units[c|0x20] > 0 && current >= 0:
+			total += current * units[c|0x20]
+			current = -1
+		default:
+			return 0, fmt.Errorf("invalid ttl '%s'", value)
+		}
+		if total > 2147483647 || current > 2147483647 {
+			return 0, fmt.Errorf("invalid ttl '%s'", value)
+		}
+	}
+	if current >= 0 {
+		total += current
+	}
+	return total, nil
+}
+
+// absoluteName completes a name relative to the origin, returning it in lower case without the trailing dot
+func absoluteName(name, origin string) string {
+	name = strings.ToLower(name)
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return strings.TrimSuffix(name, ".")
+	case origin == "":
+		return name
+	default:
+		return name + "." + origin
+	}
+}
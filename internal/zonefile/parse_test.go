@@ -0,0 +1,145 @@
+package zonefile
+
+import (
+	"dns-api-go/internal/common"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name            string
+		zoneFile        string
+		expectedRecords []Record
+		expectedError   string
+	}{
+		{
+			name: "Relative names, $TTL and inherited owner",
+			zoneFile: `$TTL 1h
+@        IN  MX    10 mail
+         300 TXT   "v=spf1 -all" ; comment
+www      IN  600 A 10.0.0.1
+alias        CNAME www
+ext          CNAME www.example.org.
+`,
+			expectedRecords: []Record{
+				{Name: "example.com", TTL: 3600, Type: "MX", Data: "10 mail.example.com."},
+				{Name: "example.com", TTL: 300, Type: "TXT", Data: `"v=spf1 -all"`},
+				{Name: "www.example.com", TTL: 600, Type: "A", Data: "10.0.0.1"},
+				{Name: "alias.example.com", TTL: 3600, Type: "CNAME", Data: "www.example.com."},
+				{Name: "ext.example.com", TTL: 3600, Type: "CNAME", Data: "www.example.org."},
+			},
+		},
+		{
+			name: "$ORIGIN and parentheses",
+			zoneFile: `$ORIGIN sub.example.com.
+@ 3600 IN SOA ns1.example.com. admin.example.com. (
+        2024010101 ; serial
+        3600 900 604800 300 )
+_sip._tcp IN SRV 10 5 5060 sip
+host IN AAAA 2001:DB8::1
+`,
+			expectedRecords: []Record{
+				{Name: "sub.example.com", TTL: 3600, Type: "SOA", Data: "ns1.example.com. admin.example.com. 2024010101 3600 900 604800 300"},
+				{Name: "_sip._tcp.sub.example.com", TTL: -1, Type: "SRV", Data: "10 5 5060 sip.sub.example.com."},
+				{Name: "host.sub.example.com", TTL: -1, Type: "AAAA", Data: "2001:db8::1"},
+			},
+		},
+		{
+			name:     "TXT character strings are joined",
+			zoneFile: `txt IN TXT "part one;" " part \"two\"" three`,
+			expectedRecords: []Record{
+				{Name: "txt.example.com", TTL: -1, Type: "TXT", Data: `"part one; part \"two\"three"`},
+			},
+		},
+		{
+			name:     "HINFO record",
+			zoneFile: `host IN HINFO "Intel Xeon" Linux`,
+			expectedRecords: []Record{
+				{Name: "host.example.com", TTL: -1, Type: "HINFO", Data: `"Intel Xeon" "Linux"`},
+			},
+		},
+		{
+			name:     "Generic record",
+			zoneFile: `@ IN CAA 0 issue "letsencrypt.org"`,
+			expectedRecords: []Record{
+				{Name: "example.com", TTL: -1, Type: "CAA", Data: `0 issue "letsencrypt.org"`},
+			},
+		},
+		{
+			name:          "Invalid address",
+			zoneFile:      "www IN A 10.0.0.300",
+			expectedError: "line 1: invalid A record www.example.com: invalid address '10.0.0.300'",
+		},
+		{
+			name:          "IPv6 address in an A record",
+			zoneFile:      "www IN A 2001:db8::1",
+			expectedError: "line 1: invalid A record www.example.com: invalid address '2001:db8::1'",
+		},
+		{
+			name:          "Missing MX preference",
+			zoneFile:      "\n@ IN MX mail",
+			expectedError: "line 2: invalid MX record example.com: expected 2 fields, got 1",
+		},
+		{
+			name:          "Unbalanced parentheses",
+			zoneFile:      "@ IN SOA ns1 admin ( 1 2 3 4 5",
+			expectedError: "line 1: unbalanced parentheses",
+		},
+		{
+			name:          "Unsupported class",
+			zoneFile:      "www CH A 10.0.0.1",
+			expectedError: "line 1: class CH is not supported",
+		},
+		{
+			name:          "Include is not supported",
+			zoneFile:      "$INCLUDE other.zone",
+			expectedError: "line 1: $INCLUDE is not supported",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := Parse(strings.NewReader(tc.zoneFile), "example.com.")
+			if tc.expectedError != "" {
+				if err == nil || err.Error() != tc.expectedError {
+					t.Errorf("%s: expected error %q, got %v", tc.name, tc.expectedError, err)
+				}
+				return
+			}
+			common.CheckError(t, tc.name, nil, err)
+			common.CheckResponse(t, tc.name, tc.expectedRecords, records)
+		})
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	for value, expected := range map[string]int{"300": 300, "1h": 3600, "1h30m": 5400, "1W": 604800, "2d1s": 172801} {
+		ttl, err := parseTTL(value)
+		common.CheckError(t, value, nil, err)
+		common.CheckResponse(t, value, expected, ttl)
+	}
+
+	for _, value := range []string{"h", "1x", "99999999999"} {
+		if _, err := parseTTL(value); err == nil {
+			t.Errorf("expected an error for ttl %s", value)
+		}
+	}
+}
+
+func TestRenderRoundTrip(t *testing.T) {
+	records := []Record{
+		{Name: "example.com", TTL: 300, Type: "TXT", Data: quoteTxt(strings.Repeat("a", 300))},
+		{Name: "www.example.com", TTL: 300, Type: "A", Data: "10.0.0.1"},
+		{Name: "alias.example.com", TTL: 3600, Type: "CNAME", Data: "www.example.com."},
+	}
+
+	var buf strings.Builder
+	err := Render(&buf, "example.com", records)
+	common.CheckError(t, "Render", nil, err)
+
+	parsed, err := Parse(strings.NewReader(buf.String()), "ignored.org")
+	common.CheckError(t, "Parse", nil, err)
+	common.CheckResponse(t, "Round trip", 3, len(parsed))
+	common.CheckResponse(t, "Round trip", records[0], parsed[0])
+}
@@ -0,0 +1,304 @@
+package zonefile
+
+import (
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Actions of the changes of an import plan
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// Plan is the set of changes that makes the records of a zone match a zone file
+type Plan struct {
+	Zone    string   `json:"zone"`
+	Changes []Change `json:"changes"`
+	Skipped []Record `json:"skipped,omitempty"`
+	Applied bool     `json:"applied"`
+}
+
+// Change creates, updates or deletes a single record entity.
+// Host records group all the A and AAAA records of a name, the other types hold a single resource record.
+type Change struct {
+	Action     string   `json:"action"`
+	RecordId   int      `json:"id,omitempty"`
+	RecordType string   `json:"type"`
+	Name       string   `json:"name"`
+	Before     []Record `json:"before,omitempty"`
+	After      []Record `json:"after,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// group is the set of resource records of a single record entity
+type group struct {
+	id         int
+	recordType string
+	name       string
+	records    []Record
+}
+
+// NewPlan compares the record entities of the zone origin with the records parsed from a zone file.
+// SOA and NS records are skipped, as they are managed with the zone, and records without a TTL are
+// considered to have the DefaultTTL.
+func NewPlan(origin string, current []models.Entity, desired []Record) (*Plan, error) {
+	origin = strings.ToLower(strings.TrimSuffix(origin, "."))
+	plan := &Plan{Zone: origin, Changes: []Change{}}
+
+	// Group the records of the zone file the way bluecat stores them
+	var desiredGroups []*group
+	hosts := map[string]*group{}
+	for _, record := range desired {
+		if record.Name != origin && !strings.HasSuffix(record.Name, "."+origin) {
+			return nil, fmt.Errorf("record %s is outside of the zone %s", record.Name, origin)
+		}
+		if record.Type == "SOA" || record.Type == "NS" {
+			plan.Skipped = append(plan.Skipped, record)
+			continue
+		}
+
+		recordType := entityType(record.Type)
+		if recordType == types.HOSTRECORD {
+			if host, ok := hosts[record.Name]; ok {
+				host.records = append(host.records, record)
+				continue
+			}
+		}
+		g := &group{recordType: recordType, name: record.Name, records: []Record{record}}
+		if recordType == types.HOSTRECORD {
+			hosts[record.Name] = g
+		}
+		desiredGroups = append(desiredGroups, g)
+	}
+
+	// Index the existing record entities
+	existing := map[string][]*group{}
+	for _, entity := range current {
+		records, err := FromEntity(entity)
+		if err != nil {
+			continue
+		}
+		g := &group{id: entity.ID, recordType: entity.Type, name: records[0].Name, records: records}
+		existing[g.key()] = append(existing[g.key()], g)
+	}
+
+	for _, want := range desiredGroups {
+		matches := existing[want.key()]
+		if len(matches) == 0 {
+			plan.Changes = append(plan.Changes, Change{
+				Action:     ActionCreate,
+				RecordType: want.recordType,
+				Name:       want.name,
+				After:      want.records,
+			})
+			continue
+		}
+
+		have := matches[0]
+		existing[want.key()] = matches[1:]
+		if !sameRecords(have.records, want.records) {
+			plan.Changes = append(plan.Changes, Change{
+				Action:     ActionUpdate,
+				RecordId:   have.id,
+				RecordType: want.recordType,
+				Name:       want.name,
+				Before:     have.records,
+				After:      want.records,
+			})
+		}
+	}
+
+	// Whatever was not matched is not in the zone file
+	for _, groups := range existing {
+		for _, have := range groups {
+			plan.Changes = append(plan.Changes, Change{
+				Action:     ActionDelete,
+				RecordId:   have.id,
+				RecordType: have.recordType,
+				Name:       have.name,
+				Before:     have.records,
+			})
+		}
+	}
+
+	// Deletes run first so records can be replaced by records of another type, e.g. a host record by an alias
+	order := map[string]int{ActionDelete: 0, ActionUpdate: 1, ActionCreate: 2}
+	sort.SliceStable(plan.Changes, func(i, j int) bool {
+		a, b := plan.Changes[i], plan.Changes[j]
+		if a.Action != b.Action {
+			return order[a.Action] < order[b.Action]
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.RecordType != b.RecordType {
+			return a.RecordType < b.RecordType
+		}
+		return a.RecordId < b.RecordId
+	})
+
+	return plan, nil
+}
+
+// key identifies the entity a group of records corresponds to.
+// Host and alias records are identified by name, the other types by name and data.
+func (g *group) key() string {
+	switch g.recordType {
+	case types.HOSTRECORD, types.CNAMERECORD:
+		return g.recordType + " " + g.name
+	default:
+		return g.recordType + " " + g.name + " " + g.records[0].Type + " " + g.records[0].Data
+	}
+}
+
+// sameRecords checks whether two groups of records have the same data and TTL
+func sameRecords(have, want []Record) bool {
+	if len(have) != len(want) || effectiveTTL(have[0].TTL) != effectiveTTL(want[0].TTL) {
+		return false
+	}
+
+	data := func(records []Record) []string {
+		values := make([]string, len(records))
+		for i, record := range records {
+			values[i] = record.Type + " " + record.Data
+		}
+		sort.Strings(values)
+		return values
+	}
+	haveData, wantData := data(have), data(want)
+	for i := range haveData {
+		if haveData[i] != wantData[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// effectiveTTL returns the TTL that applies to a record
+func effectiveTTL(ttl int) int {
+	if ttl < 0 {
+		return DefaultTTL
+	}
+	return ttl
+}
+
+// entityType returns the bluecat record type for a resource record type
+func entityType(rrType string) string {
+	switch rrType {
+	case "A", "AAAA":
+		return types.HOSTRECORD
+	case "CNAME":
+		return types.CNAMERECORD
+	case "MX":
+		return types.MXRECORD
+	case "TXT":
+		return types.TXTRECORD
+	case "SRV":
+		return types.SRVRECORD
+	case "HINFO":
+		return types.HINFORECORD
+	default:
+		return types.GENERICRECORD
+	}
+}
+
+// Parameters returns the parameters of the change for the CreateRecord or UpdateRecord methods of the record services
+func (c *Change) Parameters() (map[string]interface{}, error) {
+	if len(c.After) == 0 {
+		return nil, fmt.Errorf("%s of %s has no records", c.Action, c.Name)
+	}
+	record := c.After[0]
+
+	parameters := map[string]interface{}{
+		"ttl": effectiveTTL(record.TTL),
+	}
+	if c.Action == ActionCreate {
+		parameters["absoluteName"] = c.Name
+		parameters["name"] = c.Name
+		parameters["properties"] = map[string]string{}
+	}
+
+	fields := strings.Fields(record.Data)
+	number := func(i int) (int, error) {
+		if i >= len(fields) {
+			return 0, fmt.Errorf("invalid %s data '%s'", record.Type, record.Data)
+		}
+		return strconv.Atoi(fields[i])
+	}
+
+	switch c.RecordType {
+	case types.HOSTRECORD:
+		addresses := make([]string, len(c.After))
+		for i, host := range c.After {
+			addresses[i] = host.Data
+		}
+		parameters["addresses"] = addresses
+	case types.CNAMERECORD:
+		parameters["linkedRecordName"] = strings.TrimSuffix(record.Data, ".")
+	case types.MXRECORD, types.SRVRECORD:
+		// The data of the other types is only compared, it never changes with an update
+		if c.Action != ActionCreate {
+			break
+		}
+		parameters["linkedRecordName"] = strings.TrimSuffix(fields[len(fields)-1], ".")
+		priority, err := number(0)
+		if err != nil {
+			return nil, err
+		}
+		parameters["priority"] = priority
+		if c.RecordType == types.SRVRECORD {
+			weight, err := number(1)
+			if err != nil {
+				return nil, err
+			}
+			port, err := number(2)
+			if err != nil {
+				return nil, err
+			}
+			parameters["weight"] = weight
+			parameters["port"] = port
+		}
+	case types.TXTRECORD:
+		if c.Action != ActionCreate {
+			break
+		}
+		var depth int
+		tokens, err := tokenize(record.Data, &depth)
+		if err != nil {
+			return nil, err
+		}
+		var text strings.Builder
+		for _, t := range tokens {
+			text.WriteString(t.text)
+		}
+		parameters["txt"] = text.String()
+	case types.GENERICRECORD:
+		if c.Action != ActionCreate {
+			break
+		}
+		parameters["type"] = record.Type
+		parameters["rdata"] = record.Data
+	case types.HINFORECORD:
+		if c.Action != ActionCreate {
+			break
+		}
+		var depth int
+		tokens, err := tokenize(record.Data, &depth)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) != 2 {
+			return nil, fmt.Errorf("invalid HINFO data '%s'", record.Data)
+		}
+		parameters["cpu"] = tokens[0].text
+		parameters["os"] = tokens[1].text
+	}
+
+	return parameters, nil
+}
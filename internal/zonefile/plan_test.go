@@ -0,0 +1,137 @@
+package zonefile
+
+import (
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestNewPlan(t *testing.T) {
+	current := []models.Entity{
+		{ID: 1, Type: "HostRecord", Properties: map[string]string{"absoluteName": "www.example.com", "addresses": "10.0.0.1", "ttl": "300"}},
+		{ID: 2, Type: "HostRecord", Properties: map[string]string{"absoluteName": "db.example.com", "addresses": "10.0.0.5"}},
+		{ID: 3, Type: "AliasRecord", Properties: map[string]string{"absoluteName": "alias.example.com", "linkedRecordName": "www.example.com", "ttl": "300"}},
+		{ID: 4, Type: "MXRecord", Properties: map[string]string{"absoluteName": "example.com", "linkedRecordName": "mail.example.com", "priority": "10", "ttl": "300"}},
+		{ID: 5, Type: "MXRecord", Properties: map[string]string{"absoluteName": "example.com", "linkedRecordName": "old.example.com", "priority": "20", "ttl": "300"}},
+	}
+
+	zoneFile := `$ORIGIN example.com.
+@     3600 IN SOA ns1 admin 1 2 3 4 5
+@     300  IN MX  10 mail
+@     300  IN MX  30 new
+www   300  IN A   10.0.0.1
+www   300  IN A   10.0.0.2
+db         IN A   10.0.0.5
+alias 600  IN CNAME www
+`
+	desired, err := Parse(strings.NewReader(zoneFile), "example.com")
+	common.CheckError(t, "Parse", nil, err)
+
+	plan, err := NewPlan("example.com", current, desired)
+	common.CheckError(t, "NewPlan", nil, err)
+
+	summary := make([]string, len(plan.Changes))
+	for i, change := range plan.Changes {
+		summary[i] = change.Action + " " + change.RecordType + " " + change.Name
+	}
+	common.CheckResponse(t, "Plan changes", []string{
+		"delete MXRecord example.com",
+		"update AliasRecord alias.example.com",
+		"update HostRecord www.example.com",
+		"create MXRecord example.com",
+	}, summary)
+	common.CheckResponse(t, "Deleted record", 5, plan.Changes[0].RecordId)
+	common.CheckResponse(t, "Skipped records", 1, len(plan.Skipped))
+
+	// Host records group the addresses of a name
+	parameters, err := plan.Changes[2].Parameters()
+	common.CheckError(t, "Update parameters", nil, err)
+	common.CheckResponse(t, "Update parameters", map[string]interface{}{
+		"ttl":       300,
+		"addresses": []string{"10.0.0.1", "10.0.0.2"},
+	}, parameters)
+
+	parameters, err = plan.Changes[3].Parameters()
+	common.CheckError(t, "Create parameters", nil, err)
+	common.CheckResponse(t, "Create parameters", map[string]interface{}{
+		"ttl":              300,
+		"absoluteName":     "example.com",
+		"name":             "example.com",
+		"properties":       map[string]string{},
+		"linkedRecordName": "new.example.com",
+		"priority":         30,
+	}, parameters)
+
+	_, err = NewPlan("example.com", current, []Record{{Name: "www.example.org", TTL: 300, Type: "A", Data: "10.0.0.1"}})
+	if err == nil {
+		t.Errorf("expected an error for a record outside of the zone")
+	}
+}
+
+func TestChangeParameters(t *testing.T) {
+	tests := []struct {
+		name               string
+		change             Change
+		expectedParameters map[string]interface{}
+	}{
+		{
+			name: "Create SRV record",
+			change: Change{Action: ActionCreate, RecordType: "SRVRecord", Name: "_sip._tcp.example.com", After: []Record{
+				{Name: "_sip._tcp.example.com", TTL: -1, Type: "SRV", Data: "10 5 5060 sip.example.com."},
+			}},
+			expectedParameters: map[string]interface{}{
+				"ttl":              DefaultTTL,
+				"absoluteName":     "_sip._tcp.example.com",
+				"name":             "_sip._tcp.example.com",
+				"properties":       map[string]string{},
+				"linkedRecordName": "sip.example.com",
+				"priority":         10,
+				"weight":           5,
+				"port":             5060,
+			},
+		},
+		{
+			name: "Create TXT record",
+			change: Change{Action: ActionCreate, RecordType: "TXTRecord", Name: "example.com", After: []Record{
+				{Name: "example.com", TTL: 60, Type: "TXT", Data: `"v=spf1 include:\"mail\"" " -all"`},
+			}},
+			expectedParameters: map[string]interface{}{
+				"ttl":          60,
+				"absoluteName": "example.com",
+				"name":         "example.com",
+				"properties":   map[string]string{},
+				"txt":          `v=spf1 include:"mail" -all`,
+			},
+		},
+		{
+			name: "Create generic record",
+			change: Change{Action: ActionCreate, RecordType: "GenericRecord", Name: "example.com", After: []Record{
+				{Name: "example.com", TTL: 60, Type: "CAA", Data: `0 issue "letsencrypt.org"`},
+			}},
+			expectedParameters: map[string]interface{}{
+				"ttl":          60,
+				"absoluteName": "example.com",
+				"name":         "example.com",
+				"properties":   map[string]string{},
+				"type":         "CAA",
+				"rdata":        `0 issue "letsencrypt.org"`,
+			},
+		},
+		{
+			name: "Update TTL of a TXT record",
+			change: Change{Action: ActionUpdate, RecordId: 7, RecordType: "TXTRecord", Name: "example.com", After: []Record{
+				{Name: "example.com", TTL: 60, Type: "TXT", Data: `"text"`},
+			}},
+			expectedParameters: map[string]interface{}{"ttl": 60},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parameters, err := tc.change.Parameters()
+			common.CheckError(t, tc.name, nil, err)
+			common.CheckResponse(t, tc.name, tc.expectedParameters, parameters)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package zonefile converts DNS record entities to and from RFC 1035 master file (BIND zone file) syntax.
+package zonefile
+
+import (
+	"dns-api-go/internal/models"
+	"dns-api-go/internal/types"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// DefaultTTL is written as the $TTL of an exported zone and applies to records without their own TTL
+const DefaultTTL = 3600
+
+// Record is a single resource record of a zone file.
+// Name is the absolute name of the record without the trailing dot, and TTL is -1 when the record has no TTL of its own.
+type Record struct {
+	Name string `json:"name"`
+	TTL  int    `json:"ttl"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+}
+
+// FromEntity converts a record entity into resource records.
+// Host records produce an A or AAAA record per address, the other supported types produce a single record.
+func FromEntity(entity models.Entity) ([]Record, error) {
+	name := strings.ToLower(strings.TrimSuffix(entity.Properties["absoluteName"], "."))
+	if name == "" {
+		return nil, fmt.Errorf("record %d has no absolute name", entity.ID)
+	}
+
+	ttl := -1
+	if value := entity.Properties["ttl"]; value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ttl '%s' for record %s", value, name)
+		}
+		ttl = parsed
+	}
+
+	record := Record{Name: name, TTL: ttl}
+	switch entity.Type {
+	case types.HOSTRECORD:
+		var records []Record
+		for _, address := range strings.Split(entity.Properties["addresses"], ",") {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid address '%s' for record %s", address, name)
+			}
+			record.Type, record.Data = "A", ip.String()
+			if ip.To4() == nil {
+				record.Type = "AAAA"
+			}
+			records = append(records, record)
+		}
+		return records, nil
+	case types.CNAMERECORD:
+		record.Type = "CNAME"
+		record.Data = fqdn(entity.Properties["linkedRecordName"])
+	case types.MXRECORD:
+		record.Type = "MX"
+		record.Data = fmt.Sprintf("%s %s", entity.Properties["priority"], fqdn(entity.Properties["linkedRecordName"]))
+	case types.TXTRECORD:
+		record.Type = "TXT"
+		record.Data = quoteTxt(entity.Properties["txt"])
+	case types.SRVRECORD:
+		record.Type = "SRV"
+		record.Data = fmt.Sprintf("%s %s %s %s",
+			entity.Properties["priority"],
+			entity.Properties["weight"],
+			entity.Properties["port"],
+			fqdn(entity.Properties["linkedRecordName"]))
+	case types.GENERICRECORD:
+		record.Type = strings.ToUpper(entity.Properties["type"])
+		record.Data = entity.Properties["rdata"]
+	case types.HINFORECORD:
+		record.Type = "HINFO"
+		record.Data = quoteTxt(entity.Properties["cpu"]) + " " + quoteTxt(entity.Properties["os"])
+	default:
+		return nil, fmt.Errorf("record type %s cannot be exported", entity.Type)
+	}
+
+	return []Record{record}, nil
+}
+
+// Render writes the records of the zone origin in master file syntax.
+// Records are sorted by name, type and data so exports of the same zone can be compared with diff.
+func Render(w io.Writer, origin string, records []Record) error {
+	origin = strings.TrimSuffix(origin, ".")
+
+	sorted := make([]Record, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			// Keep the apex first, then order by name
+			if sorted[i].Name == origin || sorted[j].Name == origin {
+				return sorted[i].Name == origin
+			}
+			return sorted[i].Name < sorted[j].Name
+		}
+		if sorted[i].Type != sorted[j].Type {
+			return sorted[i].Type < sorted[j].Type
+		}
+		return sorted[i].Data < sorted[j].Data
+	})
+
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s.\n$TTL %d\n", origin, DefaultTTL); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 1, ' ', 0)
+	for _, record := range sorted {
+		ttl := ""
+		if record.TTL >= 0 {
+			ttl = strconv.Itoa(record.TTL)
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\tIN\t%s\t%s\n", relativeName(record.Name, origin), ttl, record.Type, record.Data); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// relativeName returns the owner name of a record relative to the origin, or its absolute name if it is outside the origin
+func relativeName(name, origin string) string {
+	switch {
+	case name == origin:
+		return "@"
+	case strings.HasSuffix(name, "."+origin):
+		return strings.TrimSuffix(name, "."+origin)
+	default:
+		return fqdn(name)
+	}
+}
+
+// fqdn returns the name in lower case with a trailing dot
+func fqdn(name string) string {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
+
+// quoteTxt quotes TXT data as character strings of at most 255 characters each
+func quoteTxt(txt string) string {
+	var chunks []string
+	for len(txt) > 255 {
+		chunks = append(chunks, txt[:255])
+		txt = txt[255:]
+	}
+	chunks = append(chunks, txt)
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	for i, chunk := range chunks {
+		chunks[i] = `"` + escaper.Replace(chunk) + `"`
+	}
+	return strings.Join(chunks, " ")
+}
@@ -0,0 +1,124 @@
+package zonefile
+
+import (
+	"bytes"
+	"dns-api-go/internal/common"
+	"dns-api-go/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestFromEntity(t *testing.T) {
+	tests := []struct {
+		name            string
+		entity          models.Entity
+		expectedRecords []Record
+		expectedError   bool
+	}{
+		{
+			name: "Host record with two addresses",
+			entity: models.Entity{ID: 1, Type: "HostRecord", Properties: map[string]string{
+				"absoluteName": "www.example.com",
+				"addresses":    "10.0.0.1,2001:db8::1",
+				"ttl":          "300",
+			}},
+			expectedRecords: []Record{
+				{Name: "www.example.com", TTL: 300, Type: "A", Data: "10.0.0.1"},
+				{Name: "www.example.com", TTL: 300, Type: "AAAA", Data: "2001:db8::1"},
+			},
+		},
+		{
+			name: "Alias record without ttl",
+			entity: models.Entity{ID: 2, Type: "AliasRecord", Properties: map[string]string{
+				"absoluteName":     "alias.example.com",
+				"linkedRecordName": "www.example.com",
+			}},
+			expectedRecords: []Record{{Name: "alias.example.com", TTL: -1, Type: "CNAME", Data: "www.example.com."}},
+		},
+		{
+			name: "MX record",
+			entity: models.Entity{ID: 3, Type: "MXRecord", Properties: map[string]string{
+				"absoluteName":     "example.com",
+				"linkedRecordName": "mail.example.com",
+				"priority":         "10",
+			}},
+			expectedRecords: []Record{{Name: "example.com", TTL: -1, Type: "MX", Data: "10 mail.example.com."}},
+		},
+		{
+			name: "TXT record with quotes",
+			entity: models.Entity{ID: 4, Type: "TXTRecord", Properties: map[string]string{
+				"absoluteName": "example.com",
+				"txt":          `v=spf1 include:"mail" -all`,
+			}},
+			expectedRecords: []Record{{Name: "example.com", TTL: -1, Type: "TXT", Data: `"v=spf1 include:\"mail\" -all"`}},
+		},
+		{
+			name: "SRV record",
+			entity: models.Entity{ID: 5, Type: "SRVRecord", Properties: map[string]string{
+				"absoluteName":     "_sip._tcp.example.com",
+				"linkedRecordName": "sip.example.com",
+				"priority":         "10",
+				"weight":           "5",
+				"port":             "5060",
+			}},
+			expectedRecords: []Record{{Name: "_sip._tcp.example.com", TTL: -1, Type: "SRV", Data: "10 5 5060 sip.example.com."}},
+		},
+		{
+			name: "Generic record",
+			entity: models.Entity{ID: 6, Type: "GenericRecord", Properties: map[string]string{
+				"absoluteName": "example.com",
+				"type":         "caa",
+				"rdata":        `0 issue "letsencrypt.org"`,
+			}},
+			expectedRecords: []Record{{Name: "example.com", TTL: -1, Type: "CAA", Data: `0 issue "letsencrypt.org"`}},
+		},
+		{
+			name:          "External host records are not exported",
+			entity:        models.Entity{ID: 7, Type: "ExternalHostRecord", Properties: map[string]string{"absoluteName": "external.example.org"}},
+			expectedError: true,
+		},
+		{
+			name:          "Invalid ttl",
+			entity:        models.Entity{ID: 8, Type: "AliasRecord", Properties: map[string]string{"absoluteName": "a.example.com", "ttl": "soon"}},
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := FromEntity(tc.entity)
+			if tc.expectedError {
+				if err == nil {
+					t.Errorf("%s: expected an error, got nil", tc.name)
+				}
+				return
+			}
+			common.CheckError(t, tc.name, nil, err)
+			common.CheckResponse(t, tc.name, tc.expectedRecords, records)
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	records := []Record{
+		{Name: "www.example.com", TTL: 300, Type: "A", Data: "10.0.0.1"},
+		{Name: "alias.example.com", TTL: -1, Type: "CNAME", Data: "www.example.com."},
+		{Name: "example.com", TTL: 3600, Type: "MX", Data: "10 mail.example.com."},
+		{Name: "other.example.org", TTL: 60, Type: "A", Data: "10.0.0.2"},
+	}
+
+	var buf bytes.Buffer
+	err := Render(&buf, "example.com.", records)
+	common.CheckError(t, "Render", nil, err)
+
+	expected := strings.Join([]string{
+		"$ORIGIN example.com.",
+		"$TTL 3600",
+		"@                  3600 IN MX    10 mail.example.com.",
+		"alias                   IN CNAME www.example.com.",
+		"other.example.org. 60   IN A     10.0.0.2",
+		"www                300  IN A     10.0.0.1",
+		"",
+	}, "\n")
+	common.CheckResponse(t, "Render", expected, buf.String())
+}
@@ -23,11 +23,14 @@ import (
 	"fmt"
 	"go.uber.org/zap"
 	"io"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
 
 	"dns-api-go/internal/api"
 	"dns-api-go/internal/common"
+	"dns-api-go/internal/simulator"
 
 	"dns-api-go/logger"
 )
@@ -44,6 +47,7 @@ var (
 
 	configFileName = flag.String("config", "config/config.json", "Configuration file.")
 	version        = flag.Bool("version", false, "Display version information and exit.")
+	simulate       = flag.Bool("simulate", false, "Serve BlueCat requests from an in-memory simulator for local development.")
 )
 
 func main() {
@@ -99,6 +103,14 @@ func main() {
 	}
 	logger.Debug("loaded configuration", zap.Any("config", config))
 
+	if *simulate {
+		bluecat, err := startSimulator(config.Bluecat)
+		if err != nil {
+			logger.Fatal("Unable to start the BlueCat simulator", zap.Error(err))
+		}
+		config.Bluecat = bluecat
+	}
+
 	if err := api.NewServer(config); err != nil {
 		logger.Fatal("Server initialization failed", zap.Error(err))
 	}
@@ -132,6 +144,43 @@ func configReader() io.Reader {
 	return bytes.NewReader(c)
 }
 
+// startSimulator starts an in-memory BlueCat simulator on a local port and returns the
+// bluecat configuration to reach it. The simulator is seeded with the zone example.com,
+// the network 10.0.0.0/24 and a MAC pool. The account of an existing bluecat configuration is kept.
+func startSimulator(b *common.Bluecat) (*common.Bluecat, error) {
+	sim := simulator.New("simulator", "simulator")
+	if _, err := sim.AddZone("example.com"); err != nil {
+		return nil, err
+	}
+	if _, err := sim.AddNetwork("simulator", "10.0.0.0/24"); err != nil {
+		return nil, err
+	}
+	if _, err := sim.AddMACPool("simulator"); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	go http.Serve(listener, sim)
+
+	account := "simulator"
+	if b != nil && b.Account != "" {
+		account = b.Account
+	}
+
+	baseUrl := "http://" + listener.Addr().String() + "/Services/REST/v1"
+	logger.Info("Started BlueCat simulator", zap.String("baseUrl", baseUrl), zap.String("account", account))
+	return &common.Bluecat{
+		Account:  account,
+		BaseUrl:  baseUrl,
+		Username: "simulator",
+		Password: "simulator",
+		ViewId:   strconv.Itoa(sim.ViewId()),
+	}, nil
+}
+
 func vers() {
 	fmt.Printf("dns-api-go Version: %s\n", Version)
 	os.Exit(0)